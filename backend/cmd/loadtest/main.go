@@ -0,0 +1,227 @@
+// loadtest hammers POST /registrations for a single ticket type from many
+// buyers at once and checks that no more tickets were sold than exist.
+//
+// Start the server, then:
+//
+//	go run ./cmd/loadtest -buyers 200 -tickets 25
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	baseURL     = flag.String("url", "http://localhost:8080", "server to test")
+	buyers      = flag.Int("buyers", 200, "number of buyers trying to register")
+	tickets     = flag.Int("tickets", 25, "tickets available for the ticket type")
+	concurrency = flag.Int("concurrency", 200, "requests sent at the same time")
+)
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func main() {
+	flag.Parse()
+	run := time.Now().UnixNano()
+
+	organizer := signUp(fmt.Sprintf("loadtest-organizer-%d@example.com", run))
+	var becomeOrganizer struct {
+		Token string `json:"token"`
+	}
+	mustCall("POST", "/become-organizer", organizer, nil, http.StatusOK, &becomeOrganizer)
+	// there's no new token when the account was already an organizer
+	if becomeOrganizer.Token != "" {
+		organizer = becomeOrganizer.Token
+	}
+
+	start := time.Now().Add(30 * 24 * time.Hour)
+	var event struct {
+		ID uint `json:"id"`
+	}
+	mustCall("POST", "/events/create", organizer, map[string]interface{}{
+		"title":          fmt.Sprintf("Load test %d", run),
+		"description":    "Concurrent registration load test",
+		"venue":          "Load test venue",
+		"start_datetime": start,
+		"end_datetime":   start.Add(3 * time.Hour),
+	}, http.StatusCreated, &event)
+
+	var ticketType struct {
+		ID uint `json:"id"`
+	}
+	mustCall("POST", fmt.Sprintf("/events/%d/ticket-types", event.ID), organizer, map[string]interface{}{
+		"name":               "General admission",
+		"price":              10,
+		"quantity_available": *tickets,
+	}, http.StatusCreated, &ticketType)
+
+	log.Printf("signing up %d buyers", *buyers)
+	tokens := make([]string, *buyers)
+	for i := range tokens {
+		tokens[i] = signUp(fmt.Sprintf("loadtest-%d-%d@example.com", run, i))
+	}
+
+	// every buyer waits on the barrier so the requests arrive together
+	var (
+		mu            sync.Mutex
+		registrations = map[uint]string{}
+		soldOut       int
+		unexpected    = map[int]int{}
+		wg            sync.WaitGroup
+		barrier       = make(chan struct{})
+		slots         = make(chan struct{}, *concurrency)
+	)
+	body := map[string]interface{}{"event_id": event.ID, "ticket_type_id": ticketType.ID}
+	for _, token := range tokens {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			<-barrier
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			var created struct {
+				Registration struct {
+					ID uint `json:"id"`
+				} `json:"registration"`
+			}
+			status, err := call("POST", "/registrations", token, body, &created)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				log.Printf("request failed: %v", err)
+				unexpected[0]++
+			case status == http.StatusCreated:
+				registrations[created.Registration.ID] = token
+			case status == http.StatusConflict:
+				soldOut++
+			default:
+				unexpected[status]++
+			}
+		}(token)
+	}
+
+	began := time.Now()
+	close(barrier)
+	wg.Wait()
+	log.Printf("%d requests in %s: %d registered, %d sold out, unexpected %v",
+		*buyers, time.Since(began).Round(time.Millisecond), len(registrations), soldOut, unexpected)
+
+	failed := false
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			log.Printf("FAIL: "+format, args...)
+			failed = true
+		}
+	}
+
+	expected := min(*tickets, *buyers)
+	check(len(registrations) <= *tickets, "oversold: %d registrations for %d tickets", len(registrations), *tickets)
+	check(len(registrations) == expected, "%d registrations succeeded, expected %d", len(registrations), expected)
+	check(len(unexpected) == 0, "unexpected responses %v", unexpected)
+	check(soldCount(event.ID, ticketType.ID) == len(registrations), "sold count doesn't match the registrations made")
+
+	// canceling has to hand every ticket back
+	for id, token := range registrations {
+		wg.Add(1)
+		go func(id uint, token string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if status, err := call("PUT", fmt.Sprintf("/registrations/%d/cancel", id), token, nil, nil); err != nil || status != http.StatusOK {
+				log.Printf("canceling registration %d: status %d %v", id, status, err)
+			}
+		}(id, token)
+	}
+	wg.Wait()
+	check(soldCount(event.ID, ticketType.ID) == 0, "tickets weren't released after canceling")
+
+	if failed {
+		os.Exit(1)
+	}
+	log.Printf("OK: no tickets oversold")
+}
+
+// soldCount is the sold quantity the server reports for the ticket type
+func soldCount(eventID, ticketTypeID uint) int {
+	var ticketTypes []struct {
+		ID           uint `json:"id"`
+		SoldQuantity int  `json:"sold_quantity"`
+	}
+	mustCall("GET", fmt.Sprintf("/events/%d/ticket-types", eventID), "", nil, http.StatusOK, &ticketTypes)
+	for _, t := range ticketTypes {
+		if t.ID == ticketTypeID {
+			log.Printf("server reports %d sold", t.SoldQuantity)
+			return t.SoldQuantity
+		}
+	}
+	log.Fatalf("ticket type %d missing from event %d", ticketTypeID, eventID)
+	return 0
+}
+
+// signUp registers a new user and returns their token
+func signUp(email string) string {
+	credentials := map[string]interface{}{"email": email, "password": "loadtest123"}
+	mustCall("POST", "/register", "", map[string]interface{}{
+		"email":      email,
+		"password":   "loadtest123",
+		"first_name": "Load",
+		"last_name":  "Test",
+	}, http.StatusCreated, nil)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	mustCall("POST", "/login", "", credentials, http.StatusOK, &login)
+	return login.Token
+}
+
+func mustCall(method, path, token string, body interface{}, want int, out interface{}) {
+	status, err := call(method, path, token, body, out)
+	if err != nil {
+		log.Fatalf("%s %s: %v", method, path, err)
+	}
+	if status != want {
+		log.Fatalf("%s %s: got status %d, want %d", method, path, status, want)
+	}
+}
+
+func call(method, path, token string, body interface{}, out interface{}) (int, error) {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequest(method, *baseURL+path, &payload)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
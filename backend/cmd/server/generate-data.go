@@ -203,9 +203,9 @@ func generateData() error {
 		numTicketTypes := rand.Intn(3) + 1 // 1-3 ticket types per event
 		
 		for j := 0; j < numTicketTypes; j++ {
-			price := float64(rand.Intn(200) + 10) // $10-$210
+			price := models.Money((rand.Intn(200) + 10) * 100) // $10-$210
 			if j == 0 {
-				price = float64(rand.Intn(50) + 10) // First ticket type is cheaper
+				price = models.Money((rand.Intn(50) + 10) * 100) // First ticket type is cheaper
 			}
 			
 			ticketType := models.TicketType{
@@ -12,15 +12,29 @@ import (
 	"lujke-dunn/314-group-project/backend/internal/database"
 	"lujke-dunn/314-group-project/backend/internal/handlers"
 	"lujke-dunn/314-group-project/backend/internal/middleware"
+	"lujke-dunn/314-group-project/backend/internal/models"
 	"lujke-dunn/314-group-project/backend/internal/services"
+	"lujke-dunn/314-group-project/backend/internal/storage"
 )
 
 func main() {
 	// load configuration
 	cfg := config.LoadConfig()
 
+	// tickets are signed so scanners can check them without the server
+	ticketSigner, err := services.NewTicketSigner(&cfg.Tickets)
+	if err != nil {
+		log.Fatalf("Failed to initialize ticket signing: %v", err)
+	}
+
 	// initialize email service
-	emailService := services.NewEmailService(&cfg.SMTP)
+	emailService := services.NewEmailService(&cfg.SMTP, ticketSigner)
+
+	// initialize file storage for uploaded images
+	store, err := storage.New(&cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
 
 	r := gin.Default()
 
@@ -28,12 +42,12 @@ func main() {
 		AllowOrigins:     []string{"http://localhost:5173"}, // vite dev server
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Link"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 	// initialize database
-	_, err := database.Initialize()
+	_, err = database.Initialize()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -44,14 +58,40 @@ func main() {
 		log.Fatalf("Failed to migrate database schema: %v", err)
 	}
 
+	models.PlatformFees = models.FeeSchedule{Percent: cfg.Fees.PlatformPercent, Fixed: models.MoneyFromFloat(cfg.Fees.PlatformFixed)}
+
+	// release tickets held by registrations that were never paid for and
+	// offer them to the waitlist
+	waitlist := services.NewWaitlist(database.GetDB(), emailService, cfg.App.FrontendURL, &cfg.Waitlist)
+	services.NewHoldSweeper(database.GetDB(), emailService, waitlist, &cfg.Holds).Start()
+
 	// create handlers
 	userHandler := handlers.NewUserHandler()
-	eventHandler := handlers.NewEventHandler(emailService)
-	ticketTypeHandler := handlers.NewTicketTypeHandler()
-	registrationHandler := handlers.NewRegistrationHandler(emailService)
+	eventHandler := handlers.NewEventHandler(emailService, store)
+	ticketTypeHandler := handlers.NewTicketTypeHandler(waitlist)
+	registrationHandler := handlers.NewRegistrationHandler(emailService, waitlist)
 	paymentHandler := handlers.NewPaymentHandler(emailService)
 	feedbackHandler := handlers.NewFeedbackHandler()
 	statisticsHandler := handlers.NewStatisticsHandler()
+	mediaHandler := handlers.NewMediaHandler(store, cfg.Storage.MaxUploadBytes)
+	agendaHandler := handlers.NewAgendaHandler()
+	venueHandler := handlers.NewVenueHandler()
+	seatingHandler := handlers.NewSeatingHandler()
+	eventAccessHandler := handlers.NewEventAccessHandler(emailService, cfg.App.FrontendURL)
+	joinInfoHandler := handlers.NewJoinInfoHandler(emailService)
+	questionHandler := handlers.NewRegistrationQuestionHandler()
+	orderHandler := handlers.NewOrderHandler(waitlist)
+	waitlistHandler := handlers.NewWaitlistHandler(waitlist)
+	promoCodeHandler := handlers.NewPromoCodeHandler()
+	taxRateHandler := handlers.NewTaxRateHandler()
+	addOnHandler := handlers.NewAddOnHandler()
+	bundleHandler := handlers.NewBundleHandler()
+	transferHandler := handlers.NewTransferHandler(emailService, cfg.App.FrontendURL)
+	ticketHandler := handlers.NewTicketHandler(ticketSigner)
+	checkInHandler := handlers.NewCheckInHandler(ticketSigner)
+	eventStaffHandler := handlers.NewEventStaffHandler()
+	badgeHandler := handlers.NewBadgeHandler(ticketSigner)
+	certificateHandler := handlers.NewCertificateHandler(emailService, ticketSigner, cfg.App.APIURL)
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -61,11 +101,35 @@ func main() {
 		})
 	})
 
+	// serve uploaded files when they're stored on local disk
+	if local, ok := store.(*storage.LocalStorage); ok {
+		r.Static("/uploads", local.Dir())
+	}
+
 	// public routes
 	r.POST("/register", userHandler.RegisterUser)
 	r.POST("/login", userHandler.LoginUser)
-	r.GET("/events/:id/ticket-types", ticketTypeHandler.GetTicketTypes)
 	r.GET("/events", eventHandler.ListEvents)
+
+	// public routes that show private and unlisted events to signed in users who can see them
+	optional := r.Group("/")
+	optional.Use(middleware.OptionalAuthMiddleware())
+	{
+		optional.GET("/events/:id/ticket-types", ticketTypeHandler.GetTicketTypes)
+		optional.GET("/events/:id/seat-map", seatingHandler.GetSeatMap)
+		optional.GET("/events/:id/seat-map/availability", seatingHandler.GetSeatAvailability)
+		optional.GET("/events/:id/questions", questionHandler.GetQuestions)
+		optional.GET("/events/:id/add-ons", addOnHandler.GetAddOns)
+		optional.GET("/events/:id/bundles", bundleHandler.GetBundles)
+		optional.POST("/transfers/accept", transferHandler.AcceptTransfer)
+	}
+	r.GET("/transfers/:token", transferHandler.GetTransfer)
+	r.GET("/tickets/public-key", ticketHandler.GetPublicKey)
+	r.GET("/certificates/:code/verify", certificateHandler.VerifyCertificate)
+	r.GET("/venues", venueHandler.ListVenues)
+	r.GET("/venues/autocomplete", venueHandler.AutocompleteVenues)
+	r.GET("/venues/:id", venueHandler.GetVenue)
+	r.GET("/venues/:id/events", venueHandler.GetVenueEvents)
 	// protected routes
 	authorized := r.Group("/")
 	authorized.Use(middleware.AuthMiddleware())
@@ -80,11 +144,41 @@ func main() {
 		authorized.GET("/registrations/:id", registrationHandler.GetRegistrationDetails)
 		authorized.PUT("/registrations/:id/cancel", registrationHandler.CancelRegistration)
 		authorized.GET("/events/:id/registrations", registrationHandler.GetEventRegistrations)
+		authorized.GET("/events/:id/registrations/export", registrationHandler.ExportEventRegistrations)
 		authorized.PUT("/registrations/:id/status", registrationHandler.UpdateRegistrationStatus)
 
 		authorized.POST("/registrations/:id/payments", paymentHandler.ProcessPayment)
 		authorized.GET("/registrations/:id/payments", paymentHandler.GetPayments)
 
+		authorized.GET("/registrations/:id/ticket", ticketHandler.GetTicket)
+		authorized.GET("/registrations/:id/certificate", certificateHandler.GetCertificate)
+		authorized.POST("/registrations/:id/transfer", transferHandler.CreateTransfer)
+		authorized.DELETE("/registrations/:id/transfer", transferHandler.CancelTransfer)
+		authorized.GET("/registrations/:id/transfers", transferHandler.GetTransferHistory)
+
+		// door staff aren't necessarily organizers
+		authorized.POST("/events/:id/check-ins", checkInHandler.CheckIn)
+		authorized.GET("/events/:id/check-ins", checkInHandler.GetCheckIns)
+		authorized.GET("/events/:id/check-ins/search", checkInHandler.SearchAttendees)
+		authorized.GET("/events/:id/check-ins/manifest", checkInHandler.GetManifest)
+		authorized.POST("/events/:id/check-ins/sync", checkInHandler.SyncCheckIns)
+		authorized.DELETE("/events/:id/check-ins/:check_in_id", checkInHandler.UndoCheckIn)
+		authorized.GET("/events/:id/badges", badgeHandler.PrintBadges)
+
+		authorized.POST("/orders", orderHandler.CreateOrder)
+		authorized.GET("/orders", orderHandler.GetUserOrders)
+		authorized.GET("/orders/:id", orderHandler.GetOrder)
+		authorized.PUT("/orders/:id/tickets/:ticket_id", orderHandler.UpdateOrderTicket)
+		authorized.PUT("/orders/:id/cancel", orderHandler.CancelOrder)
+		authorized.POST("/orders/:id/payments", paymentHandler.ProcessOrderPayment)
+
+		authorized.POST("/events/:id/ticket-types/:ticket_id/waitlist", waitlistHandler.JoinWaitlist)
+		authorized.GET("/waitlist", waitlistHandler.GetUserWaitlist)
+		authorized.DELETE("/waitlist/:entry_id", waitlistHandler.LeaveWaitlist)
+		authorized.POST("/waitlist/claim", waitlistHandler.ClaimOffer)
+
+		authorized.POST("/events/:id/promo-codes/validate", promoCodeHandler.ValidatePromoCode)
+
 		authorized.POST("/events/:id/feedback", feedbackHandler.CreateFeedback)
 		authorized.GET("/events/:id/feedback", feedbackHandler.GetEventFeedback)
 		authorized.GET("/feedback", feedbackHandler.GetUserFeedback)
@@ -98,9 +192,17 @@ func main() {
 			admin.GET("/users", userHandler.ListUsers)
 			admin.GET("/users/:id", userHandler.GetUserByID)
 			admin.GET("/stats", statisticsHandler.GetSystemStats)
+			admin.GET("/tax-rates", taxRateHandler.ListTaxRates)
+			admin.POST("/tax-rates", taxRateHandler.CreateTaxRate)
+			admin.PUT("/tax-rates/:rate_id", taxRateHandler.UpdateTaxRate)
+			admin.DELETE("/tax-rates/:rate_id", taxRateHandler.DeleteTaxRate)
 		}
 
 		authorized.GET("/events/:id", eventHandler.GetEvent)
+		authorized.GET("/events/:id/agenda", agendaHandler.GetAgenda)
+		authorized.GET("/events/:id/join-info", joinInfoHandler.GetJoinInfo)
+		authorized.POST("/events/:id/sessions/:session_id/signup", agendaHandler.SignUpForSession)
+		authorized.DELETE("/events/:id/sessions/:session_id/signup", agendaHandler.CancelSessionSignup)
 		authorized.GET("/my-events", eventHandler.GetUserEvents)
 		authorized.POST("/events/create", eventHandler.CreateEvent)
 		authorized.PUT("/events/:id/publish", eventHandler.PublishEvent)
@@ -115,7 +217,66 @@ func main() {
 			organizer.POST("/events/:id/ticket-types", ticketTypeHandler.CreateTicketType)
 			organizer.PUT("/events/:id/ticket-types/:ticket_id", ticketTypeHandler.UpdateTicketType)
 			organizer.DELETE("/events/:id/ticket-types/:ticket_id", ticketTypeHandler.DeleteTicketType)
-			organizer.GET("/events/stats", statisticsHandler.GetEventStats)
+			organizer.PUT("/events/:id/cover", mediaHandler.UploadCoverImage)
+			organizer.DELETE("/events/:id/cover", mediaHandler.DeleteCoverImage)
+			organizer.POST("/events/:id/images", mediaHandler.UploadGalleryImages)
+			organizer.DELETE("/events/:id/images/:image_id", mediaHandler.DeleteGalleryImage)
+			organizer.PUT("/events/:id/seat-map", seatingHandler.SaveSeatMap)
+			organizer.DELETE("/events/:id/seat-map", seatingHandler.DeleteSeatMap)
+			organizer.GET("/events/:id/share-link", eventAccessHandler.GetShareLink)
+			organizer.POST("/events/:id/share-link", eventAccessHandler.RegenerateShareLink)
+			organizer.POST("/events/:id/invites", eventAccessHandler.AddInvites)
+			organizer.GET("/events/:id/invites", eventAccessHandler.ListInvites)
+			organizer.DELETE("/events/:id/invites/:invite_id", eventAccessHandler.DeleteInvite)
+			organizer.POST("/events/:id/access-codes", eventAccessHandler.CreateAccessCode)
+			organizer.GET("/events/:id/access-codes", eventAccessHandler.ListAccessCodes)
+			organizer.DELETE("/events/:id/access-codes/:code_id", eventAccessHandler.DeleteAccessCode)
+			organizer.PUT("/events/:id/join-info", joinInfoHandler.SaveJoinInfo)
+			organizer.POST("/events/:id/join-info/rotate", joinInfoHandler.RotateJoinInfo)
+			organizer.POST("/events/:id/reminders", joinInfoHandler.SendReminders)
+			organizer.POST("/events/:id/questions", questionHandler.CreateQuestion)
+			organizer.PUT("/events/:id/questions/:question_id", questionHandler.UpdateQuestion)
+			organizer.DELETE("/events/:id/questions/:question_id", questionHandler.DeleteQuestion)
+			organizer.GET("/events/:id/waitlist", waitlistHandler.GetEventWaitlist)
+			organizer.POST("/events/:id/waitlist/:entry_id/promote", waitlistHandler.PromoteEntry)
+			organizer.DELETE("/events/:id/waitlist", waitlistHandler.ClearWaitlist)
+			organizer.POST("/events/:id/promo-codes", promoCodeHandler.CreatePromoCode)
+			organizer.POST("/events/:id/promo-codes/bulk", promoCodeHandler.BulkCreatePromoCodes)
+			organizer.GET("/events/:id/promo-codes", promoCodeHandler.ListPromoCodes)
+			organizer.PUT("/events/:id/promo-codes/:code_id", promoCodeHandler.UpdatePromoCode)
+			organizer.DELETE("/events/:id/promo-codes/:code_id", promoCodeHandler.DeletePromoCode)
+			organizer.POST("/events/:id/add-ons", addOnHandler.CreateAddOn)
+			organizer.PUT("/events/:id/add-ons/:add_on_id", addOnHandler.UpdateAddOn)
+			organizer.DELETE("/events/:id/add-ons/:add_on_id", addOnHandler.DeleteAddOn)
+			organizer.POST("/events/:id/bundles", bundleHandler.CreateBundle)
+			organizer.PUT("/events/:id/bundles/:bundle_id", bundleHandler.UpdateBundle)
+			organizer.DELETE("/events/:id/bundles/:bundle_id", bundleHandler.DeleteBundle)
+			organizer.GET("/events/:id/transfers", transferHandler.GetEventTransfers)
+			organizer.POST("/events/:id/staff", eventStaffHandler.AddStaff)
+			organizer.GET("/events/:id/staff", eventStaffHandler.GetStaff)
+			organizer.DELETE("/events/:id/staff/:user_id", eventStaffHandler.RemoveStaff)
+			organizer.GET("/events/:id/badge-templates", badgeHandler.GetTemplates)
+			organizer.POST("/events/:id/badge-templates", badgeHandler.CreateTemplate)
+			organizer.PUT("/events/:id/badge-templates/:template_id", badgeHandler.UpdateTemplate)
+			organizer.DELETE("/events/:id/badge-templates/:template_id", badgeHandler.DeleteTemplate)
+			organizer.POST("/events/:id/certificates", certificateHandler.IssueCertificates)
+			organizer.GET("/events/:id/certificates", certificateHandler.GetCertificates)
+			organizer.POST("/events/:id/certificates/send", certificateHandler.SendCertificates)
+			organizer.DELETE("/events/:id/certificates/:certificate_id", certificateHandler.RevokeCertificate)
+			organizer.POST("/events/:id/speakers", agendaHandler.CreateSpeaker)
+			organizer.PUT("/events/:id/speakers/:speaker_id", agendaHandler.UpdateSpeaker)
+			organizer.DELETE("/events/:id/speakers/:speaker_id", agendaHandler.DeleteSpeaker)
+			organizer.POST("/events/:id/sessions", agendaHandler.CreateSession)
+			organizer.PUT("/events/:id/sessions/:session_id", agendaHandler.UpdateSession)
+			organizer.DELETE("/events/:id/sessions/:session_id", agendaHandler.DeleteSession)
+			organizer.GET("/events/:id/sessions/:session_id/signups", agendaHandler.GetSessionSignups)
+			organizer.GET("/events/:id/stats", statisticsHandler.GetEventStats)
+			organizer.POST("/venues", venueHandler.CreateVenue)
+			organizer.PUT("/venues/:id", venueHandler.UpdateVenue)
+			organizer.DELETE("/venues/:id", venueHandler.DeleteVenue)
+			organizer.POST("/venues/:id/rooms", venueHandler.CreateRoom)
+			organizer.PUT("/venues/:id/rooms/:room_id", venueHandler.UpdateRoom)
+			organizer.DELETE("/venues/:id/rooms/:room_id", venueHandler.DeleteRoom)
 		}
 	}
 
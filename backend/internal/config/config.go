@@ -3,10 +3,22 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
-	SMTP SMTPConfig
+	App      AppConfig
+	SMTP     SMTPConfig
+	Storage  StorageConfig
+	Holds    HoldConfig
+	Waitlist WaitlistConfig
+	Fees     FeeConfig
+	Tickets  TicketConfig
+}
+
+type AppConfig struct {
+	FrontendURL string // used to build links in emails
+	APIURL      string // where this API is reached, for links straight to it
 }
 
 type SMTPConfig struct {
@@ -17,8 +29,50 @@ type SMTPConfig struct {
 	From     string
 }
 
+type StorageConfig struct {
+	Driver         string // "local" or "s3"
+	LocalDir       string
+	PublicBaseURL  string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	MaxUploadBytes int64
+}
+
+type HoldConfig struct {
+	SweepInterval time.Duration // how often lapsed holds are released
+	WarnBefore    time.Duration // how long before a hold lapses the buyer is warned
+}
+
+type WaitlistConfig struct {
+	ClaimWindow time.Duration // how long a user has to claim a ticket offered from the waitlist
+}
+
+type FeeConfig struct {
+	PlatformPercent float64 // percent of each paid ticket's price the platform takes
+	PlatformFixed   float64 // flat amount on top, per paid ticket
+}
+
+type TicketConfig struct {
+	SigningKey string // base64 Ed25519 seed tickets are signed with
+	KeyFile    string // where a generated key is kept when SigningKey isn't set
+}
+
 func LoadConfig() *Config {
+	storageDriver := getEnv("STORAGE_DRIVER", "local")
+	// s3 falls back to the bucket's own url when no public url is set
+	publicBaseURL := "http://localhost:8080/uploads"
+	if storageDriver == "s3" {
+		publicBaseURL = ""
+	}
+
 	return &Config{
+		App: AppConfig{
+			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
+			APIURL:      getEnv("API_URL", "http://localhost:8080"),
+		},
 		SMTP: SMTPConfig{
 			Host:     getEnv("SMTP_HOST", "smtp.gmail.com"),
 			Port:     getEnvAsInt("SMTP_PORT", 587),
@@ -26,6 +80,32 @@ func LoadConfig() *Config {
 			Password: getEnv("SMTP_PASSWORD", ""),
 			From:     getEnv("SMTP_FROM", "noreply@eventmanagement.com"),
 		},
+		Storage: StorageConfig{
+			Driver:         storageDriver,
+			LocalDir:       getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+			PublicBaseURL:  getEnv("STORAGE_PUBLIC_URL", publicBaseURL),
+			S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+			S3Region:       getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:       getEnv("S3_BUCKET", ""),
+			S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+			MaxUploadBytes: int64(getEnvAsInt("MAX_UPLOAD_MB", 5)) << 20,
+		},
+		Holds: HoldConfig{
+			SweepInterval: time.Duration(getEnvAsPositiveInt("HOLD_SWEEP_SECONDS", 60)) * time.Second,
+			WarnBefore:    time.Duration(getEnvAsPositiveInt("HOLD_WARN_MINUTES", 10)) * time.Minute,
+		},
+		Waitlist: WaitlistConfig{
+			ClaimWindow: time.Duration(getEnvAsInt("WAITLIST_CLAIM_HOURS", 24)) * time.Hour,
+		},
+		Fees: FeeConfig{
+			PlatformPercent: getEnvAsFloat("PLATFORM_FEE_PERCENT", 0),
+			PlatformFixed:   getEnvAsFloat("PLATFORM_FEE_FIXED", 0),
+		},
+		Tickets: TicketConfig{
+			SigningKey: getEnv("TICKET_SIGNING_KEY", ""),
+			KeyFile:    getEnv("TICKET_KEY_FILE", "./ticket_signing.key"),
+		},
 	}
 }
 
@@ -43,3 +123,20 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsPositiveInt is getEnvAsInt for settings that can't be zero or
+// negative, like the sweeper's ticker interval
+func getEnvAsPositiveInt(key string, defaultValue int) int {
+	if value := getEnvAsInt(key, defaultValue); value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
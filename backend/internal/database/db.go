@@ -28,9 +28,13 @@ func Initialize() (*gorm.DB, error) {
 	)
 
 	// Create SQLite database connection
-	dbPath := "./event_management.db"
+	// concurrent writers wait for the lock instead of failing straight away,
+	// and transactions take it up front so they can't deadlock upgrading to it
+	dbPath := "./event_management.db?_busy_timeout=5000&_txlock=immediate"
 	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
 		Logger: newLogger,
+		// unique index violations come back as gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 
 	if err != nil {
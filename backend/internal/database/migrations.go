@@ -3,6 +3,9 @@ package database
 import (
 	"fmt"
 	"lujke-dunn/314-group-project/backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 func MigrateSchema() error {
@@ -20,10 +23,18 @@ func MigrateSchema() error {
 		return fmt.Errorf("failed to migrate event cat model %w", err)
 	}
 
+	if err := DB.AutoMigrate(&models.Venue{}, &models.VenueRoom{}); err != nil {
+		return fmt.Errorf("failed to migrate venue models %w", err)
+	}
+
 	if err := DB.AutoMigrate(&models.Event{}); err != nil {
 		return fmt.Errorf("failed to migrate event model %w", err)
 	}
 
+	if err := DB.AutoMigrate(&models.EventImage{}); err != nil {
+		return fmt.Errorf("failed to migrate event image model %w", err)
+	}
+
 	err := DB.Exec(`
 		CREATE TABLE IF NOT EXISTS ticket_types (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -33,7 +44,7 @@ func MigrateSchema() error {
 			event_id INTEGER NOT NULL,
 			name VARCHAR(100) NOT NULL,
 			description TEXT,
-			price NUMERIC(10,2) NOT NULL,
+			price INTEGER NOT NULL,
 			quantity_available INTEGER NOT NULL,
 			is_vip BOOLEAN DEFAULT 0,
 			sale_start_date DATETIME,
@@ -57,7 +68,7 @@ func MigrateSchema() error {
             user_id INTEGER NOT NULL,
             event_id INTEGER NOT NULL,
             ticket_type_id INTEGER NOT NULL,
-            total_price DECIMAL(10,2) NOT NULL,
+            total_price INTEGER NOT NULL,
             status VARCHAR(20) DEFAULT 'pending',
             FOREIGN KEY (user_id) REFERENCES users(id),
             FOREIGN KEY (event_id) REFERENCES events(id),
@@ -77,7 +88,7 @@ func MigrateSchema() error {
             updated_at DATETIME,
             deleted_at DATETIME,
             registration_id INTEGER NOT NULL,
-            amount DECIMAL(10,2) NOT NULL,
+            amount INTEGER NOT NULL,
             status VARCHAR(20) DEFAULT 'pending',
             method VARCHAR(20),
             transaction_id VARCHAR(255),
@@ -110,5 +121,212 @@ func MigrateSchema() error {
 		return fmt.Errorf("failed to create event feedback table: %w", err)
 	}
 
+	// columns added to the tables above after they were first created
+	countSold := !DB.Migrator().HasColumn(&models.TicketType{}, "QuantitySold")
+	setAmountDue := !DB.Migrator().HasColumn(&models.Registration{}, "AmountDue")
+	if err := addMissingColumns(&models.TicketType{}, "IsHidden", "MinPerUser", "MaxPerUser", "QuantitySold"); err != nil {
+		return fmt.Errorf("failed to update ticket_types table: %w", err)
+	}
+
+	if err := addMissingColumns(&models.Registration{}, "AccessCodeID", "PromoCodeID", "DiscountAmount", "PriceTierID", "PriceTierName", "FeeAmount", "AbsorbedFee", "TaxAmount", "TaxName", "TaxInclusive", "AmountDue", "Currency", "BundleID", "AddOnAmount", "OrderID", "AttendeeName", "AttendeeEmail", "ExpiresAt", "HoldReminderSentAt", "TicketCode"); err != nil {
+		return fmt.Errorf("failed to update registrations table: %w", err)
+	}
+
+	if err := addMissingColumns(&models.Payment{}, "OrderID", "Subtotal", "FeeAmount", "TaxAmount", "Currency", "AddOnAmount"); err != nil {
+		return fmt.Errorf("failed to update payments table: %w", err)
+	}
+
+	// the sold count used to be worked out from the registrations each time
+	if countSold {
+		err = DB.Exec(`
+			UPDATE ticket_types SET quantity_sold = (
+				SELECT COUNT(*) FROM registrations
+				WHERE registrations.ticket_type_id = ticket_types.id
+				AND registrations.status != 'canceled' AND registrations.deleted_at IS NULL
+			)
+		`).Error
+		if err != nil {
+			return fmt.Errorf("failed to count sold tickets: %w", err)
+		}
+	}
+
+	// registrations made before fees and tax were added owe just their price
+	if setAmountDue {
+		if err := DB.Exec("UPDATE registrations SET amount_due = total_price").Error; err != nil {
+			return fmt.Errorf("failed to set registration amounts due: %w", err)
+		}
+	}
+
+	if err := DB.AutoMigrate(&models.Speaker{}, &models.EventSession{}, &models.SessionSignup{}); err != nil {
+		return fmt.Errorf("failed to migrate agenda models: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.SeatMap{}, &models.SeatSection{}, &models.PriceZone{}, &models.Seat{}, &models.SeatAssignment{}); err != nil {
+		return fmt.Errorf("failed to migrate seat map models: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.EventInvite{}, &models.AccessCode{}, &models.AccessCodeTicketType{}); err != nil {
+		return fmt.Errorf("failed to migrate event access models: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.EventJoinInfo{}); err != nil {
+		return fmt.Errorf("failed to migrate event join info model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.RegistrationQuestion{}, &models.RegistrationAnswer{}); err != nil {
+		return fmt.Errorf("failed to migrate registration question models: %w", err)
+	}
+
+	setOrderAmountDue := DB.Migrator().HasTable(&models.Order{}) && !DB.Migrator().HasColumn(&models.Order{}, "AmountDue")
+	if err := DB.AutoMigrate(&models.Order{}); err != nil {
+		return fmt.Errorf("failed to migrate order model: %w", err)
+	}
+	if setOrderAmountDue {
+		if err := DB.Exec("UPDATE orders SET amount_due = total_price").Error; err != nil {
+			return fmt.Errorf("failed to set order amounts due: %w", err)
+		}
+	}
+
+	if err := DB.AutoMigrate(&models.TaxRate{}); err != nil {
+		return fmt.Errorf("failed to migrate tax rate model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.PriceTier{}); err != nil {
+		return fmt.Errorf("failed to migrate price tier model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.PromoCode{}, &models.PromoCodeTicketType{}); err != nil {
+		return fmt.Errorf("failed to migrate promo code models: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.WaitlistEntry{}); err != nil {
+		return fmt.Errorf("failed to migrate waitlist model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.Notification{}); err != nil {
+		return fmt.Errorf("failed to migrate notification model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.AddOn{}, &models.AddOnTicketType{}, &models.RegistrationAddOn{}); err != nil {
+		return fmt.Errorf("failed to migrate add-on models: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.Bundle{}, &models.BundleItem{}); err != nil {
+		return fmt.Errorf("failed to migrate bundle models: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.TicketTransfer{}); err != nil {
+		return fmt.Errorf("failed to migrate ticket transfer model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.EventStaff{}, &models.CheckIn{}); err != nil {
+		return fmt.Errorf("failed to migrate check-in models: %w", err)
+	}
+	// a ticket can only be checked in once until that check-in is undone
+	if err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_check_ins_active ON check_ins(registration_id) WHERE undone_at IS NULL AND deleted_at IS NULL`).Error; err != nil {
+		return fmt.Errorf("failed to create check-in index: %w", err)
+	}
+	// an offline scan is only recorded once, scan IDs are unique per device
+	if err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_check_ins_scan ON check_ins(event_id, device_id, scan_id) WHERE scan_id != '' AND deleted_at IS NULL`).Error; err != nil {
+		return fmt.Errorf("failed to create check-in scan index: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.BadgeTemplate{}); err != nil {
+		return fmt.Errorf("failed to migrate badge template model: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&models.Certificate{}); err != nil {
+		return fmt.Errorf("failed to migrate certificate model: %w", err)
+	}
+
+	if err := runOnce("money_minor_units", convertMoneyToMinorUnits); err != nil {
+		return fmt.Errorf("failed to convert amounts to cents: %w", err)
+	}
+
+	if err := addTicketCodes(); err != nil {
+		return fmt.Errorf("failed to add ticket codes: %w", err)
+	}
+
+	return nil
+}
+
+// addTicketCodes gives registrations made before tickets had codes one
+func addTicketCodes() error {
+	var ids []uint
+	if err := DB.Model(&models.Registration{}).Where("ticket_code IS NULL OR ticket_code = ''").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		code, err := models.NewTicketCode()
+		if err != nil {
+			return err
+		}
+		if err := DB.Exec("UPDATE registrations SET ticket_code = ? WHERE id = ?", code, id).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moneyColumns are the columns holding models.Money amounts
+var moneyColumns = map[string][]string{
+	"ticket_types":  {"price"},
+	"price_tiers":   {"price"},
+	"events":        {"booking_fee"},
+	"registrations": {"total_price", "discount_amount", "fee_amount", "absorbed_fee", "tax_amount", "amount_due"},
+	"orders":        {"total_price", "discount_amount", "fee_amount", "tax_amount", "amount_due"},
+	"payments":      {"amount", "subtotal", "fee_amount", "tax_amount"},
+}
+
+// amounts used to be stored as dollars in float columns, they're now whole
+// cents. Every existing event was priced in the default currency, which the
+// new currency columns already default to.
+func convertMoneyToMinorUnits(tx *gorm.DB) error {
+	for table, columns := range moneyColumns {
+		for _, column := range columns {
+			sql := fmt.Sprintf("UPDATE %s SET %s = CAST(ROUND(%s * 100) AS INTEGER)", table, column, column)
+			if err := tx.Exec(sql).Error; err != nil {
+				return fmt.Errorf("%s.%s: %w", table, column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runOnce applies a data migration the first time the schema is migrated
+// after it was added. A fresh database has nothing to convert, so it's just
+// recorded as done.
+func runOnce(name string, migrate func(tx *gorm.DB) error) error {
+	err := DB.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (name VARCHAR(100) PRIMARY KEY, applied_at DATETIME)").Error
+	if err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var applied int64
+		if err := tx.Table("schema_migrations").Where("name = ?", name).Count(&applied).Error; err != nil {
+			return err
+		}
+		if applied > 0 {
+			return nil
+		}
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO schema_migrations (name, applied_at) VALUES (?, ?)", name, time.Now()).Error
+	})
+}
+
+// addMissingColumns adds struct fields to a table created with raw SQL, since
+// AutoMigrate can't be run on those tables
+func addMissingColumns(model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if DB.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := DB.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
 	return nil
 }
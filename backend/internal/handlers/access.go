@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findManagedEvent loads the event named by the :id param and checks the
+// current user organizes it or is an admin. On failure the error response has
+// already been written and ok is false.
+func findManagedEvent(c *gin.Context, db *gorm.DB, forbiddenMessage string) (*models.Event, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+
+	var event models.Event
+	if err := db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+
+	if event.UserID != userID.(uint) {
+		isAdmin, exists := c.Get("isAdmin")
+		if !exists || !isAdmin.(bool) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMessage})
+			return nil, false
+		}
+	}
+
+	return &event, true
+}
+
+// findStaffedEvent is findManagedEvent that also lets in the event's door staff
+func findStaffedEvent(c *gin.Context, db *gorm.DB, forbiddenMessage string) (*models.Event, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+
+	var event models.Event
+	if err := db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+
+	if !isEventManager(c, &event) && !models.IsEventStaff(db, event.ID, userID.(uint)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMessage})
+		return nil, false
+	}
+
+	return &event, true
+}
+
+// isEventManager reports whether the current user organizes the event or is an admin
+func isEventManager(c *gin.Context, event *models.Event) bool {
+	if userID, exists := c.Get("userID"); exists && userID.(uint) == event.UserID {
+		return true
+	}
+	isAdmin, exists := c.Get("isAdmin")
+	return exists && isAdmin.(bool)
+}
+
+// visibleTicketTypes scopes a ticket type query to the types the caller can
+// see: all of them for the event's managers, otherwise the ones that aren't
+// hidden or that accessCode unlocks
+func visibleTicketTypes(c *gin.Context, event *models.Event, accessCode *models.AccessCode) func(*gorm.DB) *gorm.DB {
+	if isEventManager(c, event) {
+		return func(db *gorm.DB) *gorm.DB { return db }
+	}
+	unlocked := []uint{0}
+	if accessCode != nil {
+		unlocked = append(unlocked, accessCode.TicketTypeIDs...)
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("ticket_types.is_hidden = ? OR ticket_types.id IN ?", false, unlocked)
+	}
+}
+
+// checkEventAccess decides whether the current user may see the event.
+// Unpublished events are limited to their organizer and admins, unlisted
+// events also need the share key from their link (or an invite or
+// registration) and private events need an invite or registration. On
+// failure the error response has already been written.
+func checkEventAccess(c *gin.Context, db *gorm.DB, event *models.Event, key string) bool {
+	if isEventManager(c, event) {
+		return true
+	}
+
+	if !event.IsPublished {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Event not published"})
+		return false
+	}
+
+	if event.Visibility == models.EventVisibilityPublic || event.Visibility == "" {
+		return true
+	}
+
+	if event.Visibility == models.EventVisibilityUnlisted && key != "" &&
+		subtle.ConstantTimeCompare([]byte(key), []byte(event.ShareToken)) == 1 {
+		return true
+	}
+
+	if userID, exists := c.Get("userID"); exists {
+		if models.HasActiveRegistration(db, event.ID, userID.(uint)) {
+			return true
+		}
+		if user, err := models.FindUserByID(db, userID.(uint)); err == nil && models.IsInvited(db, event.ID, user.Email) {
+			return true
+		}
+	}
+
+	if event.Visibility == models.EventVisibilityUnlisted {
+		// don't confirm the event exists to someone without the link
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "This event is invite only"})
+	return false
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AddOnHandler struct {
+	db *gorm.DB
+}
+
+func NewAddOnHandler() *AddOnHandler {
+	return &AddOnHandler{
+		db: database.GetDB(),
+	}
+}
+
+// addOnInput is an add-on a buyer wants with their tickets
+type addOnInput struct {
+	AddOnID  uint `json:"add_on_id" binding:"required"`
+	Quantity int  `json:"quantity"`
+}
+
+// findAddOns loads the add-ons a buyer asked for, one of each unless they
+// said how many
+func findAddOns(db *gorm.DB, eventID uint, inputs []addOnInput) ([]models.AddOnLine, error) {
+	lines := make([]models.AddOnLine, 0, len(inputs))
+	seen := make(map[uint]bool, len(inputs))
+	for _, input := range inputs {
+		if seen[input.AddOnID] {
+			return nil, errors.New("Each add-on can only be listed once")
+		}
+		seen[input.AddOnID] = true
+
+		quantity := input.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		if quantity < 1 {
+			return nil, errors.New("Add-on quantity must be at least 1")
+		}
+
+		addOns := make([]models.AddOn, 1)
+		if err := db.Where("id = ? AND event_id = ?", input.AddOnID, eventID).First(&addOns[0]).Error; err != nil {
+			return nil, errors.New("Add-on not found for this event")
+		}
+		if err := models.LoadAddOnTicketTypes(db, addOns); err != nil {
+			return nil, err
+		}
+		lines = append(lines, models.AddOnLine{AddOn: &addOns[0], Quantity: quantity})
+	}
+	return lines, nil
+}
+
+// GetAddOns lists what can be bought alongside the event's tickets
+func (h *AddOnHandler) GetAddOns(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return
+	}
+
+	addOns, err := models.FindAddOnsByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch add-ons"})
+		return
+	}
+
+	type AddOnWithAvailability struct {
+		models.AddOn
+		// nil when there's no limit
+		AvailableQuantity *int `json:"available_quantity"`
+	}
+
+	response := make([]AddOnWithAvailability, 0, len(addOns))
+	for _, addOn := range addOns {
+		item := AddOnWithAvailability{AddOn: addOn}
+		if available := addOn.Available(); available >= 0 {
+			item.AvailableQuantity = &available
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// addOnSettings is everything an organizer sets on an add-on. Saving replaces
+// all of them, leaving out quantity_available removes the limit.
+type addOnSettings struct {
+	Name              string       `json:"name" binding:"required"`
+	Description       string       `json:"description"`
+	Price             models.Money `json:"price"`
+	QuantityAvailable *int         `json:"quantity_available"`
+	TicketTypeIDs     []uint       `json:"ticket_type_ids"`
+}
+
+// apply checks the settings and copies them onto addOn
+func (s *addOnSettings) apply(c *gin.Context, db *gorm.DB, event *models.Event, addOn *models.AddOn) bool {
+	addOn.EventID = event.ID
+	addOn.Name = strings.TrimSpace(s.Name)
+	addOn.Description = s.Description
+	addOn.Price = s.Price
+	addOn.QuantityAvailable = s.QuantityAvailable
+	if err := addOn.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if addOn.QuantityAvailable != nil && *addOn.QuantityAvailable < addOn.QuantitySold {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d have already been sold", addOn.QuantitySold)})
+		return false
+	}
+
+	addOn.TicketTypeIDs = make([]uint, 0, len(s.TicketTypeIDs))
+	for id := range uniqueIDs(s.TicketTypeIDs) {
+		addOn.TicketTypeIDs = append(addOn.TicketTypeIDs, id)
+	}
+	if len(addOn.TicketTypeIDs) > 0 {
+		var count int64
+		db.Model(&models.TicketType{}).Where("event_id = ? AND id IN ?", event.ID, addOn.TicketTypeIDs).Count(&count)
+		if int(count) != len(addOn.TicketTypeIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All ticket types must belong to this event"})
+			return false
+		}
+	}
+	return true
+}
+
+func (h *AddOnHandler) CreateAddOn(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage add-ons for this event")
+	if !ok {
+		return
+	}
+
+	var input addOnSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var addOn models.AddOn
+	if !input.apply(c, h.db, event, &addOn) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&addOn).Error; err != nil {
+			return err
+		}
+		return models.ReplaceAddOnTicketTypes(tx, &addOn)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create add-on"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, addOn)
+}
+
+func (h *AddOnHandler) UpdateAddOn(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage add-ons for this event")
+	if !ok {
+		return
+	}
+
+	addOn, ok := h.findAddOn(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var input addOnSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !input.apply(c, h.db, event, addOn) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(addOn).Error; err != nil {
+			return err
+		}
+		return models.ReplaceAddOnTicketTypes(tx, addOn)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update add-on"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addOn)
+}
+
+// DeleteAddOn stops the add-on being sold, tickets it was bought with keep it
+func (h *AddOnHandler) DeleteAddOn(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage add-ons for this event")
+	if !ok {
+		return
+	}
+
+	addOn, ok := h.findAddOn(c, event.ID)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("add_on_id = ?", addOn.ID).Delete(&models.AddOnTicketType{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(addOn).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete add-on"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Add-on deleted successfully"})
+}
+
+func (h *AddOnHandler) findAddOn(c *gin.Context, eventID uint) (*models.AddOn, bool) {
+	addOnID, err := strconv.ParseUint(c.Param("add_on_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid add-on ID"})
+		return nil, false
+	}
+
+	addOns := make([]models.AddOn, 1)
+	if err := h.db.Where("id = ? AND event_id = ?", addOnID, eventID).First(&addOns[0]).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Add-on not found"})
+		return nil, false
+	}
+	if err := models.LoadAddOnTicketTypes(h.db, addOns); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch add-on"})
+		return nil, false
+	}
+	return &addOns[0], true
+}
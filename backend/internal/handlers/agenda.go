@@ -0,0 +1,535 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errSpeakerNotInEvent = errors.New("speaker does not belong to this event")
+
+type AgendaHandler struct {
+	db *gorm.DB
+}
+
+func NewAgendaHandler() *AgendaHandler {
+	return &AgendaHandler{
+		db: database.GetDB(),
+	}
+}
+
+func (h *AgendaHandler) CreateSpeaker(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage speakers for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name     string `json:"name" binding:"required"`
+		JobTitle string `json:"job_title"`
+		Company  string `json:"company"`
+		Bio      string `json:"bio"`
+		PhotoURL string `json:"photo_url"`
+		Website  string `json:"website"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	speaker := models.Speaker{
+		EventID:  event.ID,
+		Name:     input.Name,
+		JobTitle: input.JobTitle,
+		Company:  input.Company,
+		Bio:      input.Bio,
+		PhotoURL: input.PhotoURL,
+		Website:  input.Website,
+	}
+
+	if err := h.db.Create(&speaker).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create speaker"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, speaker)
+}
+
+func (h *AgendaHandler) UpdateSpeaker(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage speakers for this event")
+	if !ok {
+		return
+	}
+
+	speakerID, err := strconv.ParseUint(c.Param("speaker_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid speaker ID"})
+		return
+	}
+
+	var speaker models.Speaker
+	if err := h.db.Where("id = ? AND event_id = ?", speakerID, event.ID).First(&speaker).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Speaker not found for this event"})
+		return
+	}
+
+	var input struct {
+		Name     string  `json:"name"`
+		JobTitle *string `json:"job_title"`
+		Company  *string `json:"company"`
+		Bio      *string `json:"bio"`
+		PhotoURL *string `json:"photo_url"`
+		Website  *string `json:"website"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != "" {
+		speaker.Name = input.Name
+	}
+
+	if input.JobTitle != nil {
+		speaker.JobTitle = *input.JobTitle
+	}
+
+	if input.Company != nil {
+		speaker.Company = *input.Company
+	}
+
+	if input.Bio != nil {
+		speaker.Bio = *input.Bio
+	}
+
+	if input.PhotoURL != nil {
+		speaker.PhotoURL = *input.PhotoURL
+	}
+
+	if input.Website != nil {
+		speaker.Website = *input.Website
+	}
+
+	if err := h.db.Save(&speaker).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update speaker"})
+		return
+	}
+
+	c.JSON(http.StatusOK, speaker)
+}
+
+func (h *AgendaHandler) DeleteSpeaker(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage speakers for this event")
+	if !ok {
+		return
+	}
+
+	speakerID, err := strconv.ParseUint(c.Param("speaker_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid speaker ID"})
+		return
+	}
+
+	var speaker models.Speaker
+	if err := h.db.Where("id = ? AND event_id = ?", speakerID, event.ID).First(&speaker).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Speaker not found for this event"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&speaker).Association("Sessions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&speaker).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete speaker"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Speaker deleted successfully"})
+}
+
+func (h *AgendaHandler) CreateSession(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the agenda for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title       string    `json:"title" binding:"required"`
+		Description string    `json:"description"`
+		StartTime   time.Time `json:"start_time" binding:"required"`
+		EndTime     time.Time `json:"end_time" binding:"required"`
+		Room        string    `json:"room"`
+		Track       string    `json:"track"`
+		Capacity    *int      `json:"capacity"`
+		SpeakerIDs  []uint    `json:"speaker_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session := models.EventSession{
+		EventID:     event.ID,
+		Title:       input.Title,
+		Description: input.Description,
+		StartTime:   input.StartTime,
+		EndTime:     input.EndTime,
+		Room:        input.Room,
+		Track:       input.Track,
+		Capacity:    input.Capacity,
+	}
+
+	if err := session.Validate(event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	speakers, err := models.FindSpeakersForEvent(h.db, event.ID, input.SpeakerIDs)
+	if err != nil || len(speakers) != len(uniqueIDs(input.SpeakerIDs)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Speakers must belong to this event"})
+		return
+	}
+	session.Speakers = speakers
+
+	if err := h.db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func (h *AgendaHandler) UpdateSession(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the agenda for this event")
+	if !ok {
+		return
+	}
+
+	session, ok := h.findSession(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title         string     `json:"title"`
+		Description   *string    `json:"description"`
+		StartTime     *time.Time `json:"start_time"`
+		EndTime       *time.Time `json:"end_time"`
+		Room          *string    `json:"room"`
+		Track         *string    `json:"track"`
+		Capacity      *int       `json:"capacity"`
+		ClearCapacity bool       `json:"clear_capacity"`
+		SpeakerIDs    *[]uint    `json:"speaker_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Title != "" {
+		session.Title = input.Title
+	}
+
+	if input.Description != nil {
+		session.Description = *input.Description
+	}
+
+	if input.StartTime != nil {
+		session.StartTime = *input.StartTime
+	}
+
+	if input.EndTime != nil {
+		session.EndTime = *input.EndTime
+	}
+
+	if input.Room != nil {
+		session.Room = *input.Room
+	}
+
+	if input.Track != nil {
+		session.Track = *input.Track
+	}
+
+	if input.ClearCapacity {
+		session.Capacity = nil
+	} else if input.Capacity != nil {
+		var signedUp int64
+		h.db.Model(&models.SessionSignup{}).Where("session_id = ?", session.ID).Count(&signedUp)
+		if int64(*input.Capacity) < signedUp {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Capacity cannot be lower than the number of attendees already signed up"})
+			return
+		}
+		session.Capacity = input.Capacity
+	}
+
+	if err := session.Validate(event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Speakers").Save(session).Error; err != nil {
+			return err
+		}
+		if input.SpeakerIDs == nil {
+			return nil
+		}
+
+		speakers, err := models.FindSpeakersForEvent(tx, event.ID, *input.SpeakerIDs)
+		if err != nil {
+			return err
+		}
+		if len(speakers) != len(uniqueIDs(*input.SpeakerIDs)) {
+			return errSpeakerNotInEvent
+		}
+		return tx.Model(session).Association("Speakers").Replace(speakers)
+	})
+	if errors.Is(err, errSpeakerNotInEvent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Speakers must belong to this event"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+		return
+	}
+
+	h.db.Preload("Speakers").First(session, session.ID)
+	updated := []models.EventSession{*session}
+	models.CountSessionSignups(h.db, updated)
+
+	c.JSON(http.StatusOK, updated[0])
+}
+
+func (h *AgendaHandler) DeleteSession(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the agenda for this event")
+	if !ok {
+		return
+	}
+
+	session, ok := h.findSession(c, event.ID)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(session).Association("Speakers").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("session_id = ?", session.ID).Delete(&models.SessionSignup{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(session).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully"})
+}
+
+func (h *AgendaHandler) GetSessionSignups(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view sign-ups for this event")
+	if !ok {
+		return
+	}
+
+	session, ok := h.findSession(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var signups []models.SessionSignup
+	if err := h.db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, first_name, last_name, email")
+	}).Where("session_id = ?", session.ID).Order("created_at").Find(&signups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sign-ups"})
+		return
+	}
+
+	attendees := make([]gin.H, 0, len(signups))
+	for _, signup := range signups {
+		attendees = append(attendees, gin.H{
+			"registration_id": signup.RegistrationID,
+			"signed_up_at":    signup.CreatedAt,
+			"user": gin.H{
+				"id":         signup.User.ID,
+				"first_name": signup.User.FirstName,
+				"last_name":  signup.User.LastName,
+				"email":      signup.User.Email,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": session.ID,
+		"capacity":   session.Capacity,
+		"signups":    attendees,
+	})
+}
+
+func (h *AgendaHandler) GetAgenda(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return
+	}
+
+	sessions, err := models.FindSessionsByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch agenda"})
+		return
+	}
+
+	speakers, err := models.FindSpeakersByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch speakers"})
+		return
+	}
+
+	var mySessions []uint
+	if userID, exists := c.Get("userID"); exists {
+		h.db.Model(&models.SessionSignup{}).
+			Joins("JOIN event_sessions ON event_sessions.id = session_signups.session_id").
+			Where("session_signups.user_id = ? AND event_sessions.event_id = ?", userID, event.ID).
+			Pluck("session_signups.session_id", &mySessions)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event_id":       event.ID,
+		"sessions":       sessions,
+		"speakers":       speakers,
+		"my_session_ids": mySessions,
+	})
+}
+
+func (h *AgendaHandler) SignUpForSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	session, ok := h.findSession(c, uint(eventID))
+	if !ok {
+		return
+	}
+
+	if !session.RequiresSignup() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This session is open to all attendees and doesn't need a sign-up"})
+		return
+	}
+
+	if !session.StartTime.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This session has already started"})
+		return
+	}
+
+	var registration models.Registration
+	if err := h.db.Where("user_id = ? AND event_id = ? AND status = ?", userID, eventID, models.RegistrationStatusConfirmed).
+		First(&registration).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You need a confirmed registration for this event to sign up for sessions"})
+		return
+	}
+
+	signup, err := models.SignUpForSession(h.db, session, userID.(uint), registration.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrSessionFull) || errors.Is(err, models.ErrAlreadySignedUp) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to sign up for session %d: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign up for session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Signed up for session successfully",
+		"signup":  signup,
+	})
+}
+
+func (h *AgendaHandler) CancelSessionSignup(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	session, ok := h.findSession(c, uint(eventID))
+	if !ok {
+		return
+	}
+
+	result := h.db.Unscoped().Where("session_id = ? AND user_id = ?", session.ID, userID).Delete(&models.SessionSignup{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel session sign-up"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not signed up for this session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session sign-up canceled successfully"})
+}
+
+func (h *AgendaHandler) findSession(c *gin.Context, eventID uint) (*models.EventSession, bool) {
+	sessionID, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return nil, false
+	}
+
+	var session models.EventSession
+	if err := h.db.Where("id = ? AND event_id = ?", sessionID, eventID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found for this event"})
+		return nil, false
+	}
+
+	return &session, true
+}
+
+func uniqueIDs(ids []uint) map[uint]struct{} {
+	unique := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	return unique
+}
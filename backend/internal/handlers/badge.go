@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BadgeHandler struct {
+	db      *gorm.DB
+	printer *services.Printer
+}
+
+func NewBadgeHandler(tickets *services.TicketSigner) *BadgeHandler {
+	return &BadgeHandler{
+		db:      database.GetDB(),
+		printer: services.NewPrinter(tickets),
+	}
+}
+
+type badgeTemplateInput struct {
+	Name           *string `json:"name"`
+	Layout         *string `json:"layout"`
+	Heading        *string `json:"heading"`
+	Footer         *string `json:"footer"`
+	AccentColor    *string `json:"accent_color"`
+	ShowTicketType *bool   `json:"show_ticket_type"`
+	ShowQRCode     *bool   `json:"show_qr_code"`
+	IsDefault      *bool   `json:"is_default"`
+}
+
+// apply copies the fields that were sent onto the template
+func (input *badgeTemplateInput) apply(t *models.BadgeTemplate) {
+	if input.Name != nil {
+		t.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Layout != nil {
+		t.Layout = *input.Layout
+	}
+	if input.Heading != nil {
+		t.Heading = strings.TrimSpace(*input.Heading)
+	}
+	if input.Footer != nil {
+		t.Footer = strings.TrimSpace(*input.Footer)
+	}
+	if input.AccentColor != nil {
+		t.AccentColor = *input.AccentColor
+	}
+	if input.ShowTicketType != nil {
+		t.ShowTicketType = *input.ShowTicketType
+	}
+	if input.ShowQRCode != nil {
+		t.ShowQRCode = *input.ShowQRCode
+	}
+	if input.IsDefault != nil {
+		t.IsDefault = *input.IsDefault
+	}
+}
+
+// GetTemplates lists the event's badge templates along with the layouts they
+// can use
+func (h *BadgeHandler) GetTemplates(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage badges for this event")
+	if !ok {
+		return
+	}
+
+	var templates []models.BadgeTemplate
+	if err := h.db.Where("event_id = ?", event.ID).Order("id").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badge templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"layouts":   models.BadgeLayouts,
+	})
+}
+
+func (h *BadgeHandler) CreateTemplate(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage badges for this event")
+	if !ok {
+		return
+	}
+
+	var input badgeTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := models.DefaultBadgeTemplate(event.ID)
+	template.Name = ""
+	input.apply(template)
+	if err := template.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.saveTemplate(template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create badge template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func (h *BadgeHandler) UpdateTemplate(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage badges for this event")
+	if !ok {
+		return
+	}
+
+	template, ok := h.findTemplate(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var input badgeTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.apply(template)
+	if err := template.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.saveTemplate(template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *BadgeHandler) DeleteTemplate(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage badges for this event")
+	if !ok {
+		return
+	}
+
+	template, ok := h.findTemplate(c, event.ID)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete badge template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Badge template deleted successfully"})
+}
+
+// PrintBadges renders name badges for the event's confirmed attendees as a
+// PDF, with ?template_id= or the event's default template. ?ticket_type_id=
+// prints just one ticket type's badges.
+func (h *BadgeHandler) PrintBadges(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to print badges for this event")
+	if !ok {
+		return
+	}
+
+	var templateID uint64
+	if raw := c.Query("template_id"); raw != "" {
+		var err error
+		if templateID, err = strconv.ParseUint(raw, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+			return
+		}
+	}
+	template, err := models.FindBadgeTemplate(h.db, event.ID, uint(templateID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge template not found for this event"})
+		return
+	}
+
+	query := h.db.Table("registrations").
+		Select(`registrations.id, registrations.event_id, registrations.ticket_type_id, registrations.ticket_code, registrations.attendee_name,
+			COALESCE(NULLIF(registrations.attendee_name, ''), TRIM(users.first_name || ' ' || users.last_name)) AS name,
+			ticket_types.name AS ticket_type`).
+		Joins("JOIN users ON users.id = registrations.user_id").
+		Joins("JOIN ticket_types ON ticket_types.id = registrations.ticket_type_id").
+		Where("registrations.event_id = ? AND registrations.status = ? AND registrations.deleted_at IS NULL", event.ID, models.RegistrationStatusConfirmed).
+		Order("name, registrations.id")
+	if raw := c.Query("ticket_type_id"); raw != "" {
+		ticketTypeID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+			return
+		}
+		query = query.Where("registrations.ticket_type_id = ?", ticketTypeID)
+	}
+
+	var rows []struct {
+		ID           uint
+		EventID      uint
+		TicketTypeID uint
+		TicketCode   string
+		AttendeeName string
+		Name         string
+		TicketType   string
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attendees"})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "There are no confirmed attendees to print badges for"})
+		return
+	}
+
+	badges := make([]services.Badge, 0, len(rows))
+	for _, row := range rows {
+		registration := &models.Registration{
+			EventID:      row.EventID,
+			TicketTypeID: row.TicketTypeID,
+			TicketCode:   row.TicketCode,
+			AttendeeName: row.AttendeeName,
+		}
+		registration.ID = row.ID
+		badges = append(badges, services.Badge{
+			Registration: registration,
+			Name:         row.Name,
+			TicketType:   row.TicketType,
+		})
+	}
+
+	pdf, err := h.printer.BadgesPDF(event, template, badges)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to print badges"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="badges-%d.pdf"`, event.ID))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+func (h *BadgeHandler) findTemplate(c *gin.Context, eventID uint) (*models.BadgeTemplate, bool) {
+	templateID, err := strconv.ParseUint(c.Param("template_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return nil, false
+	}
+
+	var template models.BadgeTemplate
+	if err := h.db.Where("id = ? AND event_id = ?", templateID, eventID).First(&template).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge template not found for this event"})
+		return nil, false
+	}
+
+	return &template, true
+}
+
+// saveTemplate saves the template, making it the only default if it's the
+// event's default now
+func (h *BadgeHandler) saveTemplate(template *models.BadgeTemplate) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		if template.IsDefault {
+			err := tx.Model(&models.BadgeTemplate{}).
+				Where("event_id = ? AND id <> ?", template.EventID, template.ID).
+				Update("is_default", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Save(template).Error
+	})
+}
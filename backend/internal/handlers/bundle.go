@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BundleHandler struct {
+	db *gorm.DB
+}
+
+func NewBundleHandler() *BundleHandler {
+	return &BundleHandler{
+		db: database.GetDB(),
+	}
+}
+
+// GetBundles lists the event's bundles with what their tickets would cost
+// bought separately and how many can still be bought
+func (h *BundleHandler) GetBundles(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return
+	}
+
+	bundles, err := models.FindBundlesByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundles"})
+		return
+	}
+
+	var ticketTypes []models.TicketType
+	if err := h.db.Where("event_id = ?", event.ID).Find(&ticketTypes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ticket types"})
+		return
+	}
+	byID := make(map[uint]*models.TicketType, len(ticketTypes))
+	for i := range ticketTypes {
+		byID[ticketTypes[i].ID] = &ticketTypes[i]
+	}
+
+	type BundleWithAvailability struct {
+		models.Bundle
+		SeparatePrice     models.Money `json:"separate_price"`
+		AvailableQuantity int          `json:"available_quantity"`
+	}
+
+	manager := isEventManager(c, &event)
+	response := make([]BundleWithAvailability, 0, len(bundles))
+	for _, bundle := range bundles {
+		item := BundleWithAvailability{Bundle: bundle, AvailableQuantity: -1}
+		hidden := false
+		for _, bundleItem := range bundle.Items {
+			ticketType, ok := byID[bundleItem.TicketTypeID]
+			if !ok {
+				item.AvailableQuantity = 0
+				continue
+			}
+			hidden = hidden || ticketType.IsHidden
+			item.SeparatePrice += ticketType.Price * models.Money(bundleItem.Quantity)
+			available := max(ticketType.QuantityAvailable-ticketType.QuantitySold, 0) / bundleItem.Quantity
+			if item.AvailableQuantity < 0 || available < item.AvailableQuantity {
+				item.AvailableQuantity = available
+			}
+		}
+		// bundles with hidden tickets in them aren't listed publicly
+		if hidden && !manager {
+			continue
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// bundleSettings is everything an organizer sets on a bundle, saving
+// replaces all of it
+type bundleSettings struct {
+	Name        string              `json:"name" binding:"required"`
+	Description string              `json:"description"`
+	Price       models.Money        `json:"price"`
+	Items       []models.BundleItem `json:"items" binding:"required"`
+}
+
+// apply checks the settings and copies them onto bundle
+func (s *bundleSettings) apply(c *gin.Context, db *gorm.DB, event *models.Event, bundle *models.Bundle) bool {
+	bundle.EventID = event.ID
+	bundle.Name = strings.TrimSpace(s.Name)
+	bundle.Description = s.Description
+	bundle.Price = s.Price
+	bundle.Items = s.Items
+	if err := bundle.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	ids := make([]uint, len(bundle.Items))
+	for i := range bundle.Items {
+		ids[i] = bundle.Items[i].TicketTypeID
+	}
+	var count int64
+	db.Model(&models.TicketType{}).Where("event_id = ? AND id IN ?", event.ID, ids).Count(&count)
+	if int(count) != len(ids) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "All ticket types must belong to this event"})
+		return false
+	}
+	return true
+}
+
+func (h *BundleHandler) CreateBundle(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage bundles for this event")
+	if !ok {
+		return
+	}
+
+	var input bundleSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bundle models.Bundle
+	if !input.apply(c, h.db, event, &bundle) {
+		return
+	}
+
+	if err := h.db.Create(&bundle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bundle"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, bundle)
+}
+
+// UpdateBundle changes the bundle for future purchases, tickets already
+// bought in it keep their price
+func (h *BundleHandler) UpdateBundle(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage bundles for this event")
+	if !ok {
+		return
+	}
+
+	bundle, ok := h.findBundle(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var input bundleSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !input.apply(c, h.db, event, bundle) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleItem{}).Error; err != nil {
+			return err
+		}
+		for i := range bundle.Items {
+			bundle.Items[i].BundleID = bundle.ID
+		}
+		if err := tx.Create(&bundle.Items).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Items").Save(bundle).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+func (h *BundleHandler) DeleteBundle(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage bundles for this event")
+	if !ok {
+		return
+	}
+
+	bundle, ok := h.findBundle(c, event.ID)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Select("Items").Delete(bundle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bundle deleted successfully"})
+}
+
+func (h *BundleHandler) findBundle(c *gin.Context, eventID uint) (*models.Bundle, bool) {
+	bundleID, err := strconv.ParseUint(c.Param("bundle_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle ID"})
+		return nil, false
+	}
+
+	bundle, err := models.FindBundle(h.db, eventID, uint(bundleID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return nil, false
+	}
+	return bundle, true
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CertificateHandler struct {
+	db           *gorm.DB
+	emailService *services.EmailService
+	printer      *services.Printer
+	apiURL       string
+}
+
+func NewCertificateHandler(emailService *services.EmailService, tickets *services.TicketSigner, apiURL string) *CertificateHandler {
+	return &CertificateHandler{
+		db:           database.GetDB(),
+		emailService: emailService,
+		printer:      services.NewPrinter(tickets),
+		apiURL:       strings.TrimRight(apiURL, "/"),
+	}
+}
+
+// IssueCertificates gives a certificate to each attendee who was checked in,
+// once the event has ended. Running it again only issues certificates to
+// attendees who don't have one yet, such as ones checked in late.
+func (h *CertificateHandler) IssueCertificates(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to issue certificates for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		// defaults to the event's length
+		CPDHours *float64 `json:"cpd_hours"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cpdHours := models.EventCPDHours(event)
+	if input.CPDHours != nil {
+		if *input.CPDHours < 0 || *input.CPDHours > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CPD hours must be between 0 and 1000"})
+			return
+		}
+		cpdHours = *input.CPDHours
+	}
+
+	certificates, err := models.IssueCertificates(h.db, event, cpdHours)
+	if err != nil {
+		if errors.Is(err, models.ErrEventNotEnded) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue certificates"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      fmt.Sprintf("Issued %d certificates", len(certificates)),
+		"issued":       len(certificates),
+		"certificates": certificates,
+	})
+}
+
+// GetCertificates lists the certificates issued for the event
+func (h *CertificateHandler) GetCertificates(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view certificates for this event")
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.Certificate{}).Where("event_id = ?", event.ID)
+	certificates, page, err := paginate[models.Certificate](c, query, "certificates", sortKey{Name: "name", Expr: "certificates.recipient_name"}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch certificates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"certificates": certificates,
+		"pagination":   page,
+	})
+}
+
+// SendCertificates emails certificates to their attendees. Only ones that
+// haven't been sent yet go out unless resend is set.
+func (h *CertificateHandler) SendCertificates(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to send certificates for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Resend bool `json:"resend"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.db.Where("event_id = ? AND revoked_at IS NULL", event.ID)
+	if !input.Resend {
+		query = query.Where("emailed_at IS NULL")
+	}
+	var certificates []models.Certificate
+	if err := query.Order("id").Find(&certificates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch certificates"})
+		return
+	}
+
+	message := fmt.Sprintf("Your certificate of attendance for '%s' is ready.", event.Title)
+	for _, certificate := range certificates {
+		models.CreateNotification(h.db, certificate.UserID, &event.ID, "Certificate Ready", message, models.NotificationTypeCertificate)
+	}
+
+	if h.emailService != nil {
+		organizer := h.organizerName(event)
+		go func() {
+			for i := range certificates {
+				certificate := &certificates[i]
+				verifyURL := h.verifyURL(certificate)
+				pdf, err := h.printer.CertificatePDF(services.CertificateDocument{
+					Event:       event,
+					Certificate: certificate,
+					Organizer:   organizer,
+					VerifyURL:   verifyURL,
+				})
+				if err != nil {
+					log.Printf("Failed to render certificate %d: %v", certificate.ID, err)
+					continue
+				}
+				if err := h.emailService.SendCertificate(certificate, event, pdf, verifyURL); err != nil {
+					log.Printf("Failed to send certificate to %s: %v", certificate.RecipientEmail, err)
+					continue
+				}
+				h.db.Model(certificate).Update("emailed_at", time.Now())
+			}
+		}()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Certificates sent",
+		"recipients": len(certificates),
+	})
+}
+
+// RevokeCertificate withdraws a certificate issued by mistake. Verifying it
+// afterwards says it was revoked.
+func (h *CertificateHandler) RevokeCertificate(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to revoke certificates for this event")
+	if !ok {
+		return
+	}
+
+	certificateID, err := strconv.ParseUint(c.Param("certificate_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	var certificate models.Certificate
+	if err := h.db.Where("id = ? AND event_id = ?", certificateID, event.ID).First(&certificate).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found for this event"})
+		return
+	}
+	if certificate.IsRevoked() {
+		c.JSON(http.StatusConflict, gin.H{"error": "This certificate has already been revoked"})
+		return
+	}
+
+	now := time.Now()
+	certificate.RevokedAt = &now
+	if err := h.db.Save(&certificate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke certificate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, certificate)
+}
+
+// GetCertificate downloads the certificate for a registration, for the
+// attendee it was issued to or the event's organizer
+func (h *CertificateHandler) GetCertificate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	registrationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid registration ID"})
+		return
+	}
+
+	var certificate models.Certificate
+	if err := h.db.Where("registration_id = ?", registrationID).First(&certificate).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No certificate has been issued for this registration"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, certificate.EventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if certificate.UserID != userID.(uint) && !isEventManager(c, &event) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view this certificate"})
+		return
+	}
+	if certificate.IsRevoked() {
+		c.JSON(http.StatusGone, gin.H{"error": "This certificate has been revoked"})
+		return
+	}
+
+	pdf, err := h.printer.CertificatePDF(services.CertificateDocument{
+		Event:       &event,
+		Certificate: &certificate,
+		Organizer:   h.organizerName(&event),
+		VerifyURL:   h.verifyURL(&certificate),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate certificate"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%s.pdf"`, certificate.Code))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// VerifyCertificate lets anyone check a certificate's code, such as an
+// employer or professional body recording CPD
+func (h *CertificateHandler) VerifyCertificate(c *gin.Context) {
+	certificate, err := models.FindCertificateByCode(h.db, c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": "Certificate not found"})
+		return
+	}
+
+	var event models.Event
+	h.db.Select("id, title, start_datetime, end_datetime").First(&event, certificate.EventID)
+
+	response := gin.H{
+		"valid":          !certificate.IsRevoked(),
+		"code":           certificate.Code,
+		"recipient_name": certificate.RecipientName,
+		"event_id":       event.ID,
+		"event_title":    event.Title,
+		"event_start":    event.StartDatetime,
+		"event_end":      event.EndDatetime,
+		"cpd_hours":      certificate.CPDHours,
+		"issued_at":      certificate.IssuedAt,
+	}
+	if certificate.IsRevoked() {
+		response["revoked_at"] = certificate.RevokedAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *CertificateHandler) organizerName(event *models.Event) string {
+	organizer, err := models.FindUserByID(h.db, event.UserID)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(organizer.FirstName + " " + organizer.LastName)
+}
+
+// verifyURL is the public VerifyCertificate endpoint for the certificate
+func (h *CertificateHandler) verifyURL(certificate *models.Certificate) string {
+	return fmt.Sprintf("%s/certificates/%s/verify", h.apiURL, url.PathEscape(certificate.Code))
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CheckInHandler struct {
+	db      *gorm.DB
+	tickets *services.TicketSigner
+}
+
+func NewCheckInHandler(tickets *services.TicketSigner) *CheckInHandler {
+	return &CheckInHandler{
+		db:      database.GetDB(),
+		tickets: tickets,
+	}
+}
+
+// checkInRejected writes why a ticket was turned away, with a reason scanner
+// apps can act on
+func checkInRejected(c *gin.Context, checkInErr *models.CheckInError) {
+	status := http.StatusConflict
+	if checkInErr.Reason == models.CheckInRejectedInvalid {
+		status = http.StatusNotFound
+	}
+	response := gin.H{"error": checkInErr.Message, "reason": checkInErr.Reason}
+	if checkInErr.CheckIn != nil {
+		response["check_in"] = checkInErr.CheckIn
+	}
+	c.JSON(status, response)
+}
+
+// findTicket looks up the ticket a scanner read, either a signed credential
+// from a QR code or a bare ticket code typed in
+func (h *CheckInHandler) findTicket(eventID uint, code string) (*models.Registration, error) {
+	code = strings.TrimSpace(code)
+	notFound := &models.CheckInError{Reason: models.CheckInRejectedInvalid, Message: "Ticket not recognised"}
+
+	var registration models.Registration
+	if strings.HasPrefix(code, "T1.") {
+		claims, err := h.tickets.Verify(code)
+		if err != nil {
+			return nil, notFound
+		}
+		if claims.EventID != eventID {
+			return nil, &models.CheckInError{Reason: models.CheckInRejectedWrongEvent, Message: "This ticket is for a different event"}
+		}
+		if err := h.db.First(&registration, claims.RegistrationID).Error; err != nil {
+			return nil, notFound
+		}
+		// the ticket was transferred since this QR code was issued
+		if registration.TicketCode != claims.Code {
+			return nil, &models.CheckInError{Reason: models.CheckInRejectedReplaced, Message: "This ticket has been replaced, ask the holder for their latest QR code"}
+		}
+		return &registration, nil
+	}
+
+	if code == "" {
+		return nil, notFound
+	}
+	if err := h.db.Where("ticket_code = ?", strings.ToLower(code)).First(&registration).Error; err != nil {
+		return nil, notFound
+	}
+	if registration.EventID != eventID {
+		return nil, &models.CheckInError{Reason: models.CheckInRejectedWrongEvent, Message: "This ticket is for a different event"}
+	}
+	return &registration, nil
+}
+
+// CheckIn lets a ticket in, by the code scanned from it or by the
+// registration door staff found searching by name
+func (h *CheckInHandler) CheckIn(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to check attendees in to this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Code           string `json:"code"`
+		RegistrationID uint   `json:"registration_id"`
+		Gate           string `json:"gate"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (input.Code == "") == (input.RegistrationID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either the scanned ticket code or a registration ID"})
+		return
+	}
+
+	var registration *models.Registration
+	method := models.CheckInMethodScan
+	if input.Code != "" {
+		var err error
+		registration, err = h.findTicket(event.ID, input.Code)
+		if checkInErr, ok := models.AsCheckInError(err); ok {
+			checkInRejected(c, checkInErr)
+			return
+		}
+	} else {
+		method = models.CheckInMethodSearch
+		registration = &models.Registration{}
+		if err := h.db.Where("id = ? AND event_id = ?", input.RegistrationID, event.ID).First(registration).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found for this event"})
+			return
+		}
+	}
+
+	checkIn, err := models.CheckInTicket(h.db, registration, &models.CheckIn{
+		StaffID: c.MustGet("userID").(uint),
+		Gate:    strings.TrimSpace(input.Gate),
+		Method:  method,
+	})
+	if err != nil {
+		if checkInErr, ok := models.AsCheckInError(err); ok {
+			checkInRejected(c, checkInErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		return
+	}
+
+	attendee, _ := h.attendee(registration)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Checked in",
+		"check_in": checkIn,
+		"attendee": attendee,
+	})
+}
+
+// checkInAttendee is who door staff see for a ticket
+type checkInAttendee struct {
+	RegistrationID uint                      `json:"registration_id"`
+	Name           string                    `json:"name"`
+	Email          string                    `json:"email"`
+	TicketTypeID   uint                      `json:"ticket_type_id"`
+	TicketType     string                    `json:"ticket_type"`
+	Status         models.RegistrationStatus `json:"status"`
+	CheckInID      *uint                     `json:"check_in_id"`
+	CheckedInAt    *time.Time                `json:"checked_in_at"`
+	Gate           *string                   `json:"gate"`
+}
+
+// attendeeQuery lists the event's tickets with the name and email of who
+// they're for, falling back to the buyer when no attendee was given
+func (h *CheckInHandler) attendeeQuery() *gorm.DB {
+	return h.db.Table("registrations").
+		Select(`registrations.id AS registration_id,
+			COALESCE(NULLIF(registrations.attendee_name, ''), TRIM(users.first_name || ' ' || users.last_name)) AS name,
+			COALESCE(NULLIF(registrations.attendee_email, ''), users.email) AS email,
+			registrations.ticket_type_id, ticket_types.name AS ticket_type, registrations.status,
+			check_ins.id AS check_in_id, check_ins.checked_in_at, check_ins.gate`).
+		Joins("JOIN users ON users.id = registrations.user_id").
+		Joins("JOIN ticket_types ON ticket_types.id = registrations.ticket_type_id").
+		Joins("LEFT JOIN check_ins ON check_ins.registration_id = registrations.id AND check_ins.undone_at IS NULL AND check_ins.deleted_at IS NULL").
+		Where("registrations.deleted_at IS NULL")
+}
+
+func (h *CheckInHandler) attendee(registration *models.Registration) (*checkInAttendee, error) {
+	var attendee checkInAttendee
+	err := h.attendeeQuery().Where("registrations.id = ?", registration.ID).Scan(&attendee).Error
+	return &attendee, err
+}
+
+// SearchAttendees finds tickets by attendee or buyer name or email, for
+// checking in someone without their QR code
+func (h *CheckInHandler) SearchAttendees(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to check attendees in to this event")
+	if !ok {
+		return
+	}
+
+	q := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	if len(q) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search must be at least 2 characters"})
+		return
+	}
+	pattern := "%" + q + "%"
+
+	attendees := []checkInAttendee{}
+	err := h.attendeeQuery().
+		Where("registrations.event_id = ?", event.ID).
+		Where(`LOWER(COALESCE(NULLIF(registrations.attendee_name, ''), users.first_name || ' ' || users.last_name)) LIKE ?
+			OR LOWER(COALESCE(NULLIF(registrations.attendee_email, ''), users.email)) LIKE ?`, pattern, pattern).
+		Order("name").
+		Limit(20).
+		Scan(&attendees).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search attendees"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attendees)
+}
+
+// GetCheckIns lists the event's check-ins newest first, with how many of
+// the confirmed tickets are in
+func (h *CheckInHandler) GetCheckIns(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to view check-ins for this event")
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.CheckIn{}).Where("event_id = ? AND undone_at IS NULL", event.ID)
+	if gate := c.Query("gate"); gate != "" {
+		query = query.Where("gate = ?", gate)
+	}
+
+	checkIns, page, err := paginate[models.CheckIn](c, query, "check_ins", sortKey{Name: "checked_in", Expr: "check_ins.checked_in_at", Desc: true}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch check-ins")
+		return
+	}
+
+	var checkedIn, confirmed int64
+	h.db.Model(&models.CheckIn{}).Where("event_id = ? AND undone_at IS NULL", event.ID).Count(&checkedIn)
+	h.db.Model(&models.Registration{}).Where("event_id = ? AND status = ?", event.ID, models.RegistrationStatusConfirmed).Count(&confirmed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"check_ins":  checkIns,
+		"checked_in": checkedIn,
+		"confirmed":  confirmed,
+		"pagination": page,
+	})
+}
+
+// UndoCheckIn takes back a check-in made by mistake
+func (h *CheckInHandler) UndoCheckIn(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to check attendees in to this event")
+	if !ok {
+		return
+	}
+
+	checkInID, err := strconv.ParseUint(c.Param("check_in_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check-in ID"})
+		return
+	}
+
+	var checkIn models.CheckIn
+	if err := h.db.Where("id = ? AND event_id = ?", checkInID, event.ID).First(&checkIn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Check-in not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch check-in"})
+		return
+	}
+
+	if checkIn.UndoneAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This check-in has already been undone"})
+		return
+	}
+
+	if err := checkIn.Undo(h.db, c.MustGet("userID").(uint)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo check-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Check-in undone",
+		"check_in": checkIn,
+	})
+}
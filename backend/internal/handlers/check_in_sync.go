@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// most scans one sync can upload, a scanner with more sends them in batches
+const maxSyncScans = 500
+
+// GetManifest downloads the event's tickets for a scanner to check offline.
+// The manifest is sent as the exact JSON text that was signed so a scanner
+// can check the signature before trusting it.
+func (h *CheckInHandler) GetManifest(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to check attendees in to this event")
+	if !ok {
+		return
+	}
+
+	manifest, err := models.BuildCheckInManifest(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build manifest"})
+		return
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build manifest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"manifest":  string(data),
+		"signature": h.tickets.SignData(data),
+		"key_id":    h.tickets.KeyID(),
+	})
+}
+
+// syncScan is a ticket a scanner let in while offline
+type syncScan struct {
+	ScanID         string    `json:"scan_id"`
+	Code           string    `json:"code"`
+	RegistrationID uint      `json:"registration_id"`
+	Gate           string    `json:"gate"`
+	ScannedAt      time.Time `json:"scanned_at"`
+}
+
+type syncResult struct {
+	ScanID         string          `json:"scan_id"`
+	Result         string          `json:"result"`
+	RegistrationID uint            `json:"registration_id,omitempty"`
+	Reason         string          `json:"reason,omitempty"`
+	Message        string          `json:"message,omitempty"`
+	CheckIn        *models.CheckIn `json:"check_in,omitempty"`
+}
+
+// SyncCheckIns uploads the scans a scanner made offline. Each scan comes back
+// as checked_in, duplicate when the ticket was already let in (the earliest
+// scan keeps the check-in whichever gate uploaded first) or rejected when the
+// ticket turns out not to be valid, such as one canceled after the manifest
+// was taken. Scan IDs are the device's own, so device_id is required.
+// Uploading the same scan again gives the same check-in back, or rejects it
+// as undone if staff have undone that check-in since.
+func (h *CheckInHandler) SyncCheckIns(c *gin.Context) {
+	event, ok := findStaffedEvent(c, h.db, "You don't have permission to check attendees in to this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DeviceID string     `json:"device_id" binding:"required"`
+		Scans    []syncScan `json:"scans" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deviceID := strings.TrimSpace(input.DeviceID)
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A device ID is needed to sync scans"})
+		return
+	}
+	if len(input.Scans) > maxSyncScans {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d scans can be synced at once", maxSyncScans)})
+		return
+	}
+	for i, scan := range input.Scans {
+		if strings.TrimSpace(scan.ScanID) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Scan %d has no scan_id", i+1)})
+			return
+		}
+		if (scan.Code == "") == (scan.RegistrationID == 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Scan %d needs either a code or a registration ID", i+1)})
+			return
+		}
+	}
+
+	// scans are applied in the order they were made so the first one into a
+	// ticket gets the check-in, a scanner's clock ahead of ours is taken as now
+	now := time.Now()
+	order := make([]int, len(input.Scans))
+	for i := range input.Scans {
+		order[i] = i
+		scannedAt := &input.Scans[i].ScannedAt
+		if scannedAt.IsZero() || scannedAt.After(now) {
+			*scannedAt = now
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return input.Scans[order[a]].ScannedAt.Before(input.Scans[order[b]].ScannedAt)
+	})
+
+	staffID := c.MustGet("userID").(uint)
+	results := make([]syncResult, len(input.Scans))
+	counts := map[string]int{"checked_in": 0, "duplicate": 0, "rejected": 0}
+	for _, i := range order {
+		results[i] = h.syncScan(event.ID, staffID, deviceID, now, input.Scans[i])
+		counts[results[i].Result]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":    results,
+		"checked_in": counts["checked_in"],
+		"duplicates": counts["duplicate"],
+		"rejected":   counts["rejected"],
+		"synced_at":  now,
+	})
+}
+
+func (h *CheckInHandler) syncScan(eventID, staffID uint, deviceID string, syncedAt time.Time, scan syncScan) syncResult {
+	if existing, err := models.FindCheckInByScan(h.db, eventID, deviceID, scan.ScanID); err == nil {
+		return scanAlreadySynced(scan, existing)
+	}
+	result := syncResult{ScanID: scan.ScanID}
+
+	var registration *models.Registration
+	var err error
+	if scan.Code != "" {
+		registration, err = h.findTicket(eventID, scan.Code)
+	} else {
+		registration = &models.Registration{}
+		if h.db.Where("id = ? AND event_id = ?", scan.RegistrationID, eventID).First(registration).Error != nil {
+			err = &models.CheckInError{Reason: models.CheckInRejectedInvalid, Message: "Ticket not recognised"}
+		}
+	}
+	if err == nil {
+		result.RegistrationID = registration.ID
+		method := models.CheckInMethodScan
+		if scan.Code == "" {
+			method = models.CheckInMethodSearch
+		}
+		result.CheckIn, err = models.CheckInTicket(h.db, registration, &models.CheckIn{
+			StaffID:     staffID,
+			Gate:        strings.TrimSpace(scan.Gate),
+			Method:      method,
+			CheckedInAt: scan.ScannedAt,
+			ScanID:      scan.ScanID,
+			DeviceID:    deviceID,
+			SyncedAt:    &syncedAt,
+		})
+	}
+
+	if err == nil {
+		result.Result = "checked_in"
+		return result
+	}
+	// the same scan uploaded twice at once, the unique index lets one in
+	if existing, findErr := models.FindCheckInByScan(h.db, eventID, deviceID, scan.ScanID); findErr == nil {
+		return scanAlreadySynced(scan, existing)
+	}
+	checkInErr, ok := models.AsCheckInError(err)
+	if !ok {
+		result.Result = "rejected"
+		result.Message = "Failed to check in"
+		return result
+	}
+	result.Result = "rejected"
+	if checkInErr.Reason == models.CheckInRejectedDuplicate {
+		result.Result = "duplicate"
+	}
+	result.Reason = checkInErr.Reason
+	result.Message = checkInErr.Message
+	result.CheckIn = checkInErr.CheckIn
+	return result
+}
+
+// scanAlreadySynced is the result for a scan uploaded before, which gets its
+// check-in back unless that was undone since
+func scanAlreadySynced(scan syncScan, existing *models.CheckIn) syncResult {
+	result := syncResult{
+		ScanID:         scan.ScanID,
+		Result:         "checked_in",
+		RegistrationID: existing.RegistrationID,
+		CheckIn:        existing,
+	}
+	if existing.UndoneAt != nil {
+		result.Result = "rejected"
+		result.Reason = models.CheckInRejectedUndone
+		result.Message = "This scan's check-in was undone"
+	}
+	return result
+}
@@ -6,6 +6,7 @@ import (
 	"lujke-dunn/314-group-project/backend/internal/database"
 	"lujke-dunn/314-group-project/backend/internal/models"
 	"lujke-dunn/314-group-project/backend/internal/services"
+	"lujke-dunn/314-group-project/backend/internal/storage"
 	"net/http"
 	"strconv"
 	"time"
@@ -17,15 +18,20 @@ import (
 type EventHandler struct {
 	db           *gorm.DB
 	emailService *services.EmailService
+	store        storage.Storage
 }
 
-func NewEventHandler(emailService *services.EmailService) *EventHandler {
+func NewEventHandler(emailService *services.EmailService, store storage.Storage) *EventHandler {
 	return &EventHandler{
 		db:           database.GetDB(),
 		emailService: emailService,
+		store:        store,
 	}
 }
 
+// longest an unpaid registration can hold its tickets, one day
+const maxHoldMinutes = 24 * 60
+
 func (h *EventHandler) CreateEvent(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -40,18 +46,30 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 	}
 
 	var input struct {
-		Title         string    `json:"title" binding:"required"`
-		Description   string    `json:"description" binding:"required"`
-		CategoryID    *uint     `json:"category_id"`
-		Venue         string    `json:"venue" binding:"required"`
-		StartDateTime time.Time `json:"start_datetime" binding:"required"`
-		EndDateTime   time.Time `json:"end_datetime" binding:"required"`
-		City          string    `json:"city"`
-		State         string    `json:"state"`
-		Address       string    `json:"address"`
-		ZipCode       string    `json:"zip_code"`
-		Country       string    `json:"country"`
-		IsVirtual     bool      `json:"is_virtual"`
+		Title         string       `json:"title" binding:"required"`
+		Description   string       `json:"description" binding:"required"`
+		CategoryID    *uint        `json:"category_id"`
+		Venue         string       `json:"venue"`
+		VenueID       *uint        `json:"venue_id"`
+		VenueRoomID   *uint        `json:"venue_room_id"`
+		StartDateTime time.Time    `json:"start_datetime" binding:"required"`
+		EndDateTime   time.Time    `json:"end_datetime" binding:"required"`
+		City          string       `json:"city"`
+		State         string       `json:"state"`
+		Address       string       `json:"address"`
+		ZipCode       string       `json:"zip_code"`
+		Country       string       `json:"country"`
+		IsVirtual     bool         `json:"is_virtual"`
+		Visibility    string       `json:"visibility"`
+		HoldMinutes   *int         `json:"hold_minutes"`
+		Currency      string       `json:"currency"`
+		BookingFee    models.Money `json:"booking_fee"`
+		AbsorbFees    bool         `json:"absorb_fees"`
+		// per buyer ticket limits, 0 means no limit
+		MinTicketsPerUser int  `json:"min_tickets_per_user"`
+		MaxTicketsPerUser int  `json:"max_tickets_per_user"`
+		LimitByContact    bool `json:"limit_by_contact"`
+		TransfersDisabled bool `json:"transfers_disabled"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -65,6 +83,49 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 		return
 	}
 
+	if input.Venue == "" && input.VenueID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either venue or venue_id is required"})
+		return
+	}
+
+	// left out it's the default of 30
+	holdMinutes := 0
+	if input.HoldMinutes != nil {
+		if *input.HoldMinutes < 1 || *input.HoldMinutes > maxHoldMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Hold minutes must be between 1 and %d", maxHoldMinutes)})
+			return
+		}
+		holdMinutes = *input.HoldMinutes
+	}
+
+	if input.BookingFee < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking fee cannot be negative"})
+		return
+	}
+
+	if err := models.CheckPurchaseLimitSettings(input.MinTicketsPerUser, input.MaxTicketsPerUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency := models.DefaultCurrency
+	if input.Currency != "" {
+		currency = models.NormalizeCurrency(input.Currency)
+		if !models.ValidCurrency(currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Currency is not supported"})
+			return
+		}
+	}
+
+	visibility := models.EventVisibilityPublic
+	if input.Visibility != "" {
+		visibility = models.EventVisibility(input.Visibility)
+		if !visibility.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Visibility must be 'public', 'unlisted' or 'private'"})
+			return
+		}
+	}
+
 	event := models.Event{
 		UserID:        userID.(uint),
 		CategoryID:    input.CategoryID,
@@ -81,6 +142,21 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 		IsVirtual:     input.IsVirtual,
 		IsPublished:   true,
 		IsCanceled:    false,
+		Visibility:    visibility,
+		HoldMinutes:   holdMinutes,
+		Currency:      currency,
+		BookingFee:    input.BookingFee,
+		AbsorbFees:    input.AbsorbFees,
+
+		MinTicketsPerUser: input.MinTicketsPerUser,
+		MaxTicketsPerUser: input.MaxTicketsPerUser,
+		LimitByContact:    input.LimitByContact,
+		TransfersDisabled: input.TransfersDisabled,
+	}
+
+	if err := linkEventVenue(h.db, &event, input.VenueID, input.VenueRoomID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if err := h.db.Create(&event).Error; err != nil {
@@ -125,109 +201,70 @@ func (h *EventHandler) GetEvent(c *gin.Context) {
 		return
 	}
 
-	if !event.IsPublished {
-		userID, exists := c.Get("userID")
-		if !exists || userID.(uint) != event.UserID {
-			isAdmin, exists := c.Get("isAdmin")
-			if !exists || !isAdmin.(bool) {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Event not published"})
-				return
-			}
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return
+	}
+
+	// hidden ticket types only show with an access code that unlocks them
+	var accessCode *models.AccessCode
+	if code := c.Query("access_code"); code != "" {
+		if found, err := models.FindAccessCode(h.db, event.ID, code); err == nil && found.CheckUsable(h.db) == nil {
+			accessCode = found
 		}
 	}
 
 	h.db.Preload("User", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, first_name, last_name, email")
-	}).Preload("TicketTypes").First(&event, id)
+	}).Preload("TicketTypes", visibleTicketTypes(c, &event, accessCode)).Preload("VenueDetails.Rooms").Preload("Room").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("kind, position, id")
+	}).Preload("Sessions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_time, id")
+	}).Preload("Sessions.Speakers").Preload("Speakers").First(&event, id)
+
+	attachImageURLs(h.store, event.Images)
+	models.CountSessionSignups(h.db, event.Sessions)
 
 	c.JSON(http.StatusOK, event)
 }
 
 func (h *EventHandler) ListEvents(c *gin.Context) {
-	var events []models.Event
-	query := h.db.Where("is_published = ? AND is_canceled = ?", true, false).
-		Preload("TicketTypes")
-
-	if categoryID, err := strconv.ParseUint(c.Query("category_id"), 10, 32); err == nil {
-		query = query.Where("category_id = ?", categoryID)
-	}
-
-	if city := c.Query("city"); city != "" {
-		query = query.Where("city LIKE ?", "%"+city+"%")
+	sort := c.DefaultQuery("sort", "date")
+	key, ok := eventSortKeys[sort]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort option"})
+		return
 	}
 
-	const string_date_and_time_format = time.RFC3339
-	if startDate := c.Query("start_date"); startDate != "" {
-		if date, err := time.Parse(string_date_and_time_format, startDate); err == nil {
-			query = query.Where("start_datetime >= ?", date)
-		}
+	filters := parseEventFilters(c)
+	listable := func() *gorm.DB {
+		return h.db.Model(&models.Event{}).Where("events.is_published = ? AND events.is_canceled = ? AND events.visibility = ?", true, false, models.EventVisibilityPublic)
 	}
 
-	if endDate := c.Query("end_date"); endDate != "" {
-		if date, err := time.Parse(string_date_and_time_format, endDate); err == nil {
-			query = query.Where("start_datetime <= ?", date)
-		}
+	facets, err := eventFacets(listable, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute event facets"})
+		return
 	}
 
-	if searchQuery := c.Query("query"); searchQuery != "" {
-		query = query.Where("title LIKE ? OR DESCRIPTION LIKE ?",
-			"%"+searchQuery+"%", "%"+searchQuery+"%")
-	}
+	query := filters.apply(listable(), "").
+		Preload("TicketTypes", "is_hidden = ?", false).
+		Preload("Images", "kind = ?", models.ImageKindCover)
 
-	// Handle event type filter (virtual/physical)
-	if eventType := c.Query("event_type"); eventType != "" {
-		if eventType == "virtual" {
-			query = query.Where("is_virtual = ?", true)
-		} else if eventType == "physical" {
-			query = query.Where("is_virtual = ?", false)
-		}
+	events, page, err := paginate[models.Event](c, query, "events", key, 10)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch events")
+		return
 	}
 
-	// Handle price range filters
-	minPriceStr := c.Query("min_price")
-	maxPriceStr := c.Query("max_price")
-	
-	if minPriceStr != "" || maxPriceStr != "" {
-		subQuery := h.db.Table("ticket_types").
-			Select("DISTINCT event_id").
-			Where("ticket_types.deleted_at IS NULL")
-		
-		if minPriceStr != "" {
-			if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
-				subQuery = subQuery.Where("price >= ?", minPrice)
-			}
-		}
-		
-		if maxPriceStr != "" {
-			if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
-				subQuery = subQuery.Where("price <= ?", maxPrice)
-			}
-		}
-		
-		query = query.Where("id IN (?)", subQuery)
-	}
-
-	query = query.Order("start_datetime")
-
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
-	offset := (page - 1) * perPage
-
-	var total int64
-	query.Model(&models.Event{}).Count(&total)
-
-	result := query.Limit(perPage).Offset(offset).Find(&events)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
-		return
+	for i := range events {
+		attachImageURLs(h.store, events[i].Images)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"events":      events,
-		"total":       total,
-		"page":        page,
-		"per_page":    perPage,
-		"total_pages": (int(total) + perPage - 1) / perPage,
+		"events":     events,
+		"pagination": page,
+		"sort":       sort,
+		"facets":     facets,
 	})
 }
 
@@ -259,18 +296,31 @@ func (h *EventHandler) UpdateEvent(c *gin.Context) {
 	}
 
 	var input struct {
-		Title         string     `json:"title"`
-		Description   string     `json:"description"`
-		CategoryID    *uint      `json:"category_id"`
-		Venue         string     `json:"venue"`
-		StartDatetime *time.Time `json:"start_datetime"`
-		EndDatetime   *time.Time `json:"end_datetime"`
-		City          string     `json:"city"`
-		State         string     `json:"state"`
-		Address       string     `json:"address"`
-		ZipCode       string     `json:"zip_code"`
-		Country       string     `json:"country"`
-		IsVirtual     *bool      `json:"is_virtual"`
+		Title         string        `json:"title"`
+		Description   string        `json:"description"`
+		CategoryID    *uint         `json:"category_id"`
+		Venue         string        `json:"venue"`
+		StartDatetime *time.Time    `json:"start_datetime"`
+		EndDatetime   *time.Time    `json:"end_datetime"`
+		City          string        `json:"city"`
+		State         string        `json:"state"`
+		Address       string        `json:"address"`
+		ZipCode       string        `json:"zip_code"`
+		Country       string        `json:"country"`
+		IsVirtual     *bool         `json:"is_virtual"`
+		VenueID       *uint         `json:"venue_id"`
+		VenueRoomID   *uint         `json:"venue_room_id"`
+		ClearVenue    bool          `json:"clear_venue"`
+		Visibility    string        `json:"visibility"`
+		HoldMinutes   *int          `json:"hold_minutes"`
+		Currency      string        `json:"currency"`
+		BookingFee    *models.Money `json:"booking_fee"`
+		AbsorbFees    *bool         `json:"absorb_fees"`
+		// per buyer ticket limits, 0 means no limit
+		MinTicketsPerUser *int  `json:"min_tickets_per_user"`
+		MaxTicketsPerUser *int  `json:"max_tickets_per_user"`
+		LimitByContact    *bool `json:"limit_by_contact"`
+		TransfersDisabled *bool `json:"transfers_disabled"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -318,15 +368,113 @@ func (h *EventHandler) UpdateEvent(c *gin.Context) {
 		event.ZipCode = input.ZipCode
 	}
 
+	if input.Country != "" {
+		event.Country = input.Country
+	}
+
 	if input.IsVirtual != nil {
 		event.IsVirtual = *input.IsVirtual
 	}
 
+	if input.Visibility != "" {
+		event.Visibility = models.EventVisibility(input.Visibility)
+		if !event.Visibility.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Visibility must be 'public', 'unlisted' or 'private'"})
+			return
+		}
+	}
+
+	// only applies to registrations made from now on
+	if input.HoldMinutes != nil {
+		if *input.HoldMinutes < 1 || *input.HoldMinutes > maxHoldMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Hold minutes must be between 1 and %d", maxHoldMinutes)})
+			return
+		}
+		event.HoldMinutes = *input.HoldMinutes
+	}
+
+	// tickets already sold were charged in the old currency
+	if input.Currency != "" && models.NormalizeCurrency(input.Currency) != event.Currency {
+		currency := models.NormalizeCurrency(input.Currency)
+		if !models.ValidCurrency(currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Currency is not supported"})
+			return
+		}
+		var sold int64
+		if err := h.db.Model(&models.Registration{}).Where("event_id = ?", event.ID).Count(&sold).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+			return
+		}
+		if sold > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Currency cannot be changed once tickets have been registered"})
+			return
+		}
+		event.Currency = currency
+	}
+
+	// fees, like holds, only apply to registrations made from now on
+	if input.BookingFee != nil {
+		if *input.BookingFee < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Booking fee cannot be negative"})
+			return
+		}
+		event.BookingFee = *input.BookingFee
+	}
+
+	if input.AbsorbFees != nil {
+		event.AbsorbFees = *input.AbsorbFees
+	}
+
+	// tickets already held are kept even if they're now over the limit
+	if input.MinTicketsPerUser != nil {
+		event.MinTicketsPerUser = *input.MinTicketsPerUser
+	}
+	if input.MaxTicketsPerUser != nil {
+		event.MaxTicketsPerUser = *input.MaxTicketsPerUser
+	}
+	if err := models.CheckPurchaseLimitSettings(event.MinTicketsPerUser, event.MaxTicketsPerUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.LimitByContact != nil {
+		event.LimitByContact = *input.LimitByContact
+	}
+
+	if input.TransfersDisabled != nil {
+		event.TransfersDisabled = *input.TransfersDisabled
+	}
+
 	if event.EndDatetime.Before(event.StartDatetime) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "End datetime must be after start date"})
 		return
 	}
 
+	if input.ClearVenue {
+		event.VenueID = nil
+		event.VenueRoomID = nil
+	} else if input.VenueID != nil || input.VenueRoomID != nil {
+		venueID := input.VenueID
+		if venueID == nil {
+			venueID = event.VenueID
+		}
+		if err := linkEventVenue(h.db, &event, venueID, input.VenueRoomID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// a smaller venue or room must still fit the tickets already on offer
+	msg, err := checkTicketCapacity(h.db, &event, 0, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check venue capacity"})
+		return
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
 	if err := h.db.Save(&event).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
@@ -478,29 +626,16 @@ func (h *EventHandler) GetUserEvents(c *gin.Context) {
 		return
 	}
 
-	var events []models.Event
-	query := h.db.Where("user_id = ?", userID)
-
-	query = query.Order("created_at DESC")
+	query := h.db.Model(&models.Event{}).Where("user_id = ?", userID).Preload("TicketTypes")
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
-	offset := (page - 1) * perPage
-
-	var total int64
-	query.Model(&models.Event{}).Count(&total)
-
-	result := query.Preload("TicketTypes").Limit(perPage).Offset(offset).Find(&events)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+	events, page, err := paginate[models.Event](c, query, "events", sortKey{Name: "created", Expr: "events.created_at", Desc: true}, 10)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch events")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"events": events,
-		"total":  total,
-		"page":   page,
-		"per_page": perPage,
-		"total_pages": (total + int64(perPage) - 1) / int64(perPage),
+		"events":     events,
+		"pagination": page,
 	})
 }
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxInvitesPerUpload = 1000
+	maxInviteFileBytes  = 1 << 20
+)
+
+// EventAccessHandler manages who can see private and unlisted events: share
+// links, invite lists and access codes for hidden ticket types
+type EventAccessHandler struct {
+	db           *gorm.DB
+	emailService *services.EmailService
+	frontendURL  string
+}
+
+func NewEventAccessHandler(emailService *services.EmailService, frontendURL string) *EventAccessHandler {
+	return &EventAccessHandler{
+		db:           database.GetDB(),
+		emailService: emailService,
+		frontendURL:  strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// eventLink builds the frontend link to an event, including the share key
+// when the event is unlisted. Private events only open for invitees, so the
+// key would do nothing there.
+func (h *EventAccessHandler) eventLink(event *models.Event) string {
+	link := fmt.Sprintf("%s/events/%d", h.frontendURL, event.ID)
+	if event.Visibility == models.EventVisibilityUnlisted && event.ShareToken != "" {
+		link += "?key=" + url.QueryEscape(event.ShareToken)
+	}
+	return link
+}
+
+func (h *EventAccessHandler) GetShareLink(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage access to this event")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"visibility": event.Visibility,
+		"key":        event.ShareToken,
+		"link":       h.eventLink(event),
+	})
+}
+
+// RegenerateShareLink replaces the share key so old links stop working
+func (h *EventAccessHandler) RegenerateShareLink(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage access to this event")
+	if !ok {
+		return
+	}
+
+	token, err := models.NewShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share link"})
+		return
+	}
+
+	event.ShareToken = token
+	if err := h.db.Model(event).Update("share_token", token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save share link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"visibility": event.Visibility,
+		"key":        event.ShareToken,
+		"link":       h.eventLink(event),
+	})
+}
+
+type inviteInput struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// AddInvites adds people to the event's invite list. It accepts either JSON
+// ({"invites": [{"email", "name"}], "send_email": true}) or a CSV file upload
+// in the "file" field with an email column and an optional name column.
+func (h *EventAccessHandler) AddInvites(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage invites for this event")
+	if !ok {
+		return
+	}
+
+	var invites []inviteInput
+	var sendEmail bool
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInviteFileBytes)
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required in the 'file' field"})
+			return
+		}
+		invites, err = parseInviteCSV(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		sendEmail, _ = strconv.ParseBool(c.PostForm("send_email"))
+	} else {
+		var input struct {
+			Invites   []inviteInput `json:"invites"`
+			Emails    []string      `json:"emails"`
+			SendEmail bool          `json:"send_email"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		invites = input.Invites
+		for _, email := range input.Emails {
+			invites = append(invites, inviteInput{Email: email})
+		}
+		sendEmail = input.SendEmail
+	}
+
+	if len(invites) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No invites were provided"})
+		return
+	}
+	if len(invites) > maxInvitesPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d invites can be added at once", maxInvitesPerUpload)})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	seen := make(map[string]bool, len(invites))
+	invalid := []string{}
+	var added []models.EventInvite
+	skipped := 0
+
+	for _, in := range invites {
+		address, err := mail.ParseAddress(strings.TrimSpace(in.Email))
+		if err != nil {
+			invalid = append(invalid, in.Email)
+			continue
+		}
+		email := models.NormalizeEmail(address.Address)
+		if seen[email] {
+			skipped++
+			continue
+		}
+		seen[email] = true
+
+		name := strings.TrimSpace(in.Name)
+		if name == "" {
+			name = address.Name
+		}
+		added = append(added, models.EventInvite{
+			EventID:   event.ID,
+			Email:     email,
+			Name:      name,
+			InvitedBy: userID.(uint),
+		})
+	}
+
+	// people already on the list are left as they are
+	if len(added) > 0 {
+		emails := make([]string, len(added))
+		for i, invite := range added {
+			emails[i] = invite.Email
+		}
+		var existing []string
+		h.db.Model(&models.EventInvite{}).Where("event_id = ? AND email IN ?", event.ID, emails).Pluck("email", &existing)
+		existingSet := make(map[string]bool, len(existing))
+		for _, email := range existing {
+			existingSet[email] = true
+		}
+
+		fresh := added[:0]
+		for _, invite := range added {
+			if existingSet[invite.Email] {
+				skipped++
+				continue
+			}
+			fresh = append(fresh, invite)
+		}
+		added = fresh
+	}
+
+	if len(added) > 0 {
+		if err := h.db.CreateInBatches(&added, 200).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save invites"})
+			return
+		}
+	}
+
+	if sendEmail && h.emailService != nil && len(added) > 0 {
+		go h.sendInvitations(*event, added)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("%d invite(s) added", len(added)),
+		"added":   len(added),
+		"skipped": skipped,
+		"invalid": invalid,
+	})
+}
+
+func (h *EventAccessHandler) sendInvitations(event models.Event, invites []models.EventInvite) {
+	link := h.eventLink(&event)
+	for _, invite := range invites {
+		if err := h.emailService.SendEventInvitation(invite.Email, invite.Name, &event, link); err != nil {
+			log.Printf("Failed to send invitation to %s: %v", invite.Email, err)
+			continue
+		}
+		now := time.Now()
+		h.db.Model(&models.EventInvite{}).Where("id = ?", invite.ID).Update("emailed_at", &now)
+	}
+}
+
+// parseInviteCSV reads email and name columns. A header row is optional; when
+// there is none the first column is the email and the second the name.
+func parseInviteCSV(file *multipart.FileHeader) ([]inviteInput, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, errors.New("Failed to read uploaded file")
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	emailCol, nameCol := 0, 1
+	var invites []inviteInput
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV file: %v", err)
+		}
+
+		if line == 0 {
+			header := false
+			for i, col := range record {
+				switch strings.ToLower(strings.TrimSpace(col)) {
+				case "email", "e-mail", "email address":
+					emailCol, header = i, true
+				case "name", "full name":
+					nameCol = i
+				}
+			}
+			if header {
+				continue
+			}
+		}
+
+		if emailCol >= len(record) {
+			continue
+		}
+		invite := inviteInput{Email: record[emailCol]}
+		if nameCol < len(record) && nameCol != emailCol {
+			invite.Name = record[nameCol]
+		}
+		if strings.TrimSpace(invite.Email) != "" {
+			invites = append(invites, invite)
+		}
+	}
+	return invites, nil
+}
+
+func (h *EventAccessHandler) ListInvites(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view invites for this event")
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.EventInvite{}).Where("event_id = ?", event.ID)
+	if q := strings.TrimSpace(c.Query("query")); q != "" {
+		query = query.Where("(email LIKE ? OR name LIKE ?)", "%"+q+"%", "%"+q+"%")
+	}
+
+	invites, page, err := paginate[models.EventInvite](c, query, "event_invites", sortKey{Name: "email", Expr: "event_invites.email"}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch invites")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invites":    invites,
+		"pagination": page,
+	})
+}
+
+func (h *EventAccessHandler) DeleteInvite(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage invites for this event")
+	if !ok {
+		return
+	}
+
+	inviteID, err := strconv.ParseUint(c.Param("invite_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return
+	}
+
+	result := h.db.Unscoped().Where("id = ? AND event_id = ?", inviteID, event.ID).Delete(&models.EventInvite{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete invite"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found for this event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite deleted successfully"})
+}
+
+func (h *EventAccessHandler) CreateAccessCode(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage access codes for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Code          string     `json:"code" binding:"required"`
+		Description   string     `json:"description"`
+		MaxUses       *int       `json:"max_uses"`
+		ExpiresAt     *time.Time `json:"expires_at"`
+		TicketTypeIDs []uint     `json:"ticket_type_ids" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := models.NormalizeAccessCode(input.Code)
+	if len(code) < 4 || len(code) > 50 || strings.ContainsAny(code, " \t") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code must be 4 to 50 characters without spaces"})
+		return
+	}
+
+	if input.MaxUses != nil && *input.MaxUses <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Max uses must be positive"})
+		return
+	}
+
+	ticketTypeIDs := make([]uint, 0, len(input.TicketTypeIDs))
+	for id := range uniqueIDs(input.TicketTypeIDs) {
+		ticketTypeIDs = append(ticketTypeIDs, id)
+	}
+	var count int64
+	h.db.Model(&models.TicketType{}).Where("event_id = ? AND id IN ?", event.ID, ticketTypeIDs).Count(&count)
+	if int(count) != len(ticketTypeIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "All ticket types must belong to this event"})
+		return
+	}
+
+	var existing int64
+	h.db.Model(&models.AccessCode{}).Where("event_id = ? AND code = ?", event.ID, code).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An access code with this code already exists for this event"})
+		return
+	}
+
+	accessCode := models.AccessCode{
+		EventID:     event.ID,
+		Code:        code,
+		Description: input.Description,
+		MaxUses:     input.MaxUses,
+		ExpiresAt:   input.ExpiresAt,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&accessCode).Error; err != nil {
+			return err
+		}
+		links := make([]models.AccessCodeTicketType, len(ticketTypeIDs))
+		for i, id := range ticketTypeIDs {
+			links[i] = models.AccessCodeTicketType{AccessCodeID: accessCode.ID, TicketTypeID: id}
+		}
+		return tx.Create(&links).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access code"})
+		return
+	}
+
+	accessCode.TicketTypeIDs = ticketTypeIDs
+	c.JSON(http.StatusCreated, accessCode)
+}
+
+func (h *EventAccessHandler) ListAccessCodes(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view access codes for this event")
+	if !ok {
+		return
+	}
+
+	var codes []models.AccessCode
+	if err := h.db.Where("event_id = ?", event.ID).Order("code").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access codes"})
+		return
+	}
+
+	ptrs := make([]*models.AccessCode, len(codes))
+	for i := range codes {
+		ptrs[i] = &codes[i]
+	}
+	if err := models.LoadAccessCodeDetails(h.db, ptrs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+func (h *EventAccessHandler) DeleteAccessCode(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage access codes for this event")
+	if !ok {
+		return
+	}
+
+	codeID, err := strconv.ParseUint(c.Param("code_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access code ID"})
+		return
+	}
+
+	var accessCode models.AccessCode
+	if err := h.db.Where("id = ? AND event_id = ?", codeID, event.ID).First(&accessCode).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access code not found for this event"})
+		return
+	}
+
+	// registrations keep their access_code_id, the code just can't be used again
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("access_code_id = ?", accessCode.ID).Delete(&models.AccessCodeTicketType{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&accessCode).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete access code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access code deleted successfully"})
+}
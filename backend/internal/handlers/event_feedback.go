@@ -94,15 +94,17 @@ func (h *FeedbackHandler) GetEventFeedback(c *gin.Context) {
 		return
 	}
 
-	feedbacks, err := models.FindFeedbackByEvent(h.db, uint(eventID))
+	query := h.db.Model(&models.EventFeedback{}).Where("event_id = ?", eventID)
+
+	feedbacks, page, err := paginate[models.EventFeedback](c, query, "event_feedbacks", sortKey{Name: "created", Expr: "event_feedbacks.created_at", Desc: true}, defaultPageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feedback"})
+		paginationError(c, err, "Failed to fetch feedback")
 		return
 	}
 
-	avgRating, _ := models.GetAverageRatingForEvent(h.db, uint(eventID))
+	count, avgRating, _ := models.GetRatingSummaryForEvent(h.db, uint(eventID))
 
-	var enhancedFeedbacks []gin.H
+	enhancedFeedbacks := make([]gin.H, 0, len(feedbacks))
 	for _, feedback := range feedbacks {
 		var user models.User
 		h.db.Select("id, first_name, last_name").First(&user, feedback.UserID)
@@ -122,7 +124,8 @@ func (h *FeedbackHandler) GetEventFeedback(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"feedbacks":      enhancedFeedbacks,
-		"count":          len(feedbacks),
+		"pagination":     page,
+		"count":          count,
 		"average_rating": avgRating,
 	})
 }
@@ -134,13 +137,15 @@ func (h *FeedbackHandler) GetUserFeedback(c *gin.Context) {
 		return
 	}
 
-	var feedbacks []models.EventFeedback
-	if err := h.db.Where("user_id = ?", userID).Find(&feedbacks).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feedback"})
+	query := h.db.Model(&models.EventFeedback{}).Where("user_id = ?", userID)
+
+	feedbacks, page, err := paginate[models.EventFeedback](c, query, "event_feedbacks", sortKey{Name: "created", Expr: "event_feedbacks.created_at", Desc: true}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch feedback")
 		return
 	}
 
-	var enhancedFeedbacks []gin.H
+	enhancedFeedbacks := make([]gin.H, 0, len(feedbacks))
 	for _, feedback := range feedbacks {
 		var event models.Event
 		h.db.Select("id, title, venue, start_datetime").First(&event, feedback.EventID)
@@ -159,7 +164,10 @@ func (h *FeedbackHandler) GetUserFeedback(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, enhancedFeedbacks)
+	c.JSON(http.StatusOK, gin.H{
+		"feedbacks":  enhancedFeedbacks,
+		"pagination": page,
+	})
 }
 
 func (h *FeedbackHandler) UpdateFeedback(c *gin.Context) {
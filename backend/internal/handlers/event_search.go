@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sql fragments evaluated per event row, shared by filters, facets and sorting
+const (
+	// a ticket type's price right now, from the first price tier that applies
+	// as in TicketType.PriceAt, or its own price when none do
+	currentPriceSQL = "COALESCE((SELECT price_tiers.price FROM price_tiers WHERE price_tiers.ticket_type_id = ticket_types.id AND price_tiers.deleted_at IS NULL" +
+		" AND (price_tiers.starts_at IS NULL OR datetime(price_tiers.starts_at) <= datetime('now'))" +
+		" AND (price_tiers.ends_at IS NULL OR datetime(price_tiers.ends_at) > datetime('now'))" +
+		" AND (price_tiers.max_sold IS NULL OR ticket_types.quantity_sold < price_tiers.max_sold)" +
+		" ORDER BY price_tiers.position, price_tiers.id LIMIT 1), ticket_types.price)"
+
+	// hidden ticket types are left out of everything public
+	minPriceSQL = "(SELECT MIN(" + currentPriceSQL + ") FROM ticket_types WHERE ticket_types.event_id = events.id AND ticket_types.deleted_at IS NULL AND ticket_types.is_hidden = 0)"
+
+	popularitySQL = "(SELECT COUNT(*) FROM registrations WHERE registrations.event_id = events.id AND registrations.status != 'canceled' AND registrations.deleted_at IS NULL)"
+
+	ratingSQL = "(SELECT COALESCE(AVG(event_feedbacks.rating), 0) FROM event_feedbacks WHERE event_feedbacks.event_id = events.id AND event_feedbacks.deleted_at IS NULL)"
+
+	// each event's min price worked out once, for bucketing
+	eventPricesSQL = "SELECT events.id AS event_id, " + minPriceSQL + " AS min_price FROM events"
+
+	// buckets the min_price column of eventPricesSQL, prices are in cents of
+	// the event's own currency
+	priceBucketSQL = "CASE WHEN min_price IS NULL THEN 'no_tickets'" +
+		" WHEN min_price = 0 THEN 'free'" +
+		" WHEN min_price < 5000 THEN 'under_50'" +
+		" WHEN min_price < 10000 THEN '50_to_100'" +
+		" WHEN min_price < 20000 THEN '100_to_200'" +
+		" ELSE '200_plus' END"
+
+	// takes three args: now, a week from now, a month from now
+	dateBucketSQL = "CASE WHEN events.start_datetime < ? THEN 'past'" +
+		" WHEN events.start_datetime < ? THEN 'this_week'" +
+		" WHEN events.start_datetime < ? THEN 'this_month'" +
+		" ELSE 'later' END"
+
+	// quantity_sold already counts held tickets, see ReserveTicket
+	hasTicketsSQL = "EXISTS (SELECT 1 FROM ticket_types tt WHERE tt.event_id = events.id AND tt.deleted_at IS NULL AND tt.is_hidden = 0" +
+		" AND tt.quantity_sold < tt.quantity_available)"
+)
+
+var eventSortKeys = map[string]sortKey{
+	"date":       {Name: "date", Expr: "events.start_datetime"},
+	"price_asc":  {Name: "price_asc", Expr: "COALESCE(" + minPriceSQL + ", 1e15)"},
+	"price_desc": {Name: "price_desc", Expr: "COALESCE(" + minPriceSQL + ", -1)", Desc: true},
+	"popularity": {Name: "popularity", Expr: popularitySQL, Desc: true},
+	"newest":     {Name: "newest", Expr: "events.created_at", Desc: true},
+	"rating":     {Name: "rating", Expr: ratingSQL, Desc: true},
+}
+
+type eventFilters struct {
+	CategoryIDs []uint
+	Cities      []string
+	StartDate   *time.Time
+	EndDate     *time.Time
+	Query       string
+	EventType   string
+	// prices are only comparable within one currency, so price filters and
+	// facets only look at events in Currency, or the default currency if unset
+	Currency     string
+	MinPrice     *models.Money
+	MaxPrice     *models.Money
+	PriceBuckets []string
+	DateBuckets  []string
+	HasTickets   bool
+
+	now time.Time
+}
+
+// queryList accepts both repeated params (?city=a&city=b) and comma separated values (?city=a,b)
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(key) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func parseEventFilters(c *gin.Context) eventFilters {
+	f := eventFilters{
+		Cities:       queryList(c, "city"),
+		Query:        c.Query("query"),
+		EventType:    c.Query("event_type"),
+		PriceBuckets: queryList(c, "price_bucket"),
+		DateBuckets:  queryList(c, "date_bucket"),
+		now:          time.Now(),
+	}
+
+	for _, raw := range queryList(c, "category_id") {
+		if categoryID, err := strconv.ParseUint(raw, 10, 32); err == nil {
+			f.CategoryIDs = append(f.CategoryIDs, uint(categoryID))
+		}
+	}
+
+	const string_date_and_time_format = time.RFC3339
+	if date, err := time.Parse(string_date_and_time_format, c.Query("start_date")); err == nil {
+		f.StartDate = &date
+	}
+	if date, err := time.Parse(string_date_and_time_format, c.Query("end_date")); err == nil {
+		f.EndDate = &date
+	}
+
+	if currency := models.NormalizeCurrency(c.Query("currency")); models.ValidCurrency(currency) {
+		f.Currency = currency
+	}
+	if minPrice, err := models.ParseMoney(c.Query("min_price")); err == nil {
+		f.MinPrice = &minPrice
+	}
+	if maxPrice, err := models.ParseMoney(c.Query("max_price")); err == nil {
+		f.MaxPrice = &maxPrice
+	}
+
+	f.HasTickets, _ = strconv.ParseBool(c.Query("has_tickets"))
+
+	return f
+}
+
+func (f eventFilters) priceCurrency() string {
+	if f.Currency != "" {
+		return f.Currency
+	}
+	return models.DefaultCurrency
+}
+
+func (f eventFilters) dateBucketArgs() []interface{} {
+	return []interface{}{f.now, f.now.AddDate(0, 0, 7), f.now.AddDate(0, 1, 0)}
+}
+
+// apply adds every filter to the query except the facet named by skip, so a
+// facet's counts reflect the other active filters but not its own selection
+func (f eventFilters) apply(query *gorm.DB, skip string) *gorm.DB {
+	if skip != "categories" && len(f.CategoryIDs) > 0 {
+		query = query.Where("events.category_id IN ?", f.CategoryIDs)
+	}
+
+	if skip != "cities" && len(f.Cities) > 0 {
+		conditions := make([]string, len(f.Cities))
+		args := make([]interface{}, len(f.Cities))
+		for i, city := range f.Cities {
+			conditions[i] = "events.city LIKE ?"
+			args[i] = "%" + city + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	if f.StartDate != nil {
+		query = query.Where("events.start_datetime >= ?", *f.StartDate)
+	}
+
+	if f.EndDate != nil {
+		query = query.Where("events.start_datetime <= ?", *f.EndDate)
+	}
+
+	if f.Query != "" {
+		query = query.Where("(events.title LIKE ? OR events.description LIKE ?)",
+			"%"+f.Query+"%", "%"+f.Query+"%")
+	}
+
+	if skip != "event_types" {
+		if f.EventType == "virtual" {
+			query = query.Where("events.is_virtual = ?", true)
+		} else if f.EventType == "physical" {
+			query = query.Where("events.is_virtual = ?", false)
+		}
+	}
+
+	// the price bucket facet is scoped to one currency even when no price filter is set
+	if f.Currency != "" || f.MinPrice != nil || f.MaxPrice != nil || len(f.PriceBuckets) > 0 || skip == "price_buckets" {
+		query = query.Where("events.currency = ?", f.priceCurrency())
+	}
+
+	if f.MinPrice != nil || f.MaxPrice != nil {
+		subQuery := query.Session(&gorm.Session{NewDB: true}).Table("ticket_types").
+			Select("DISTINCT event_id").
+			Where("ticket_types.deleted_at IS NULL AND ticket_types.is_hidden = 0")
+
+		if f.MinPrice != nil {
+			subQuery = subQuery.Where(currentPriceSQL+" >= ?", *f.MinPrice)
+		}
+
+		if f.MaxPrice != nil {
+			subQuery = subQuery.Where(currentPriceSQL+" <= ?", *f.MaxPrice)
+		}
+
+		query = query.Where("events.id IN (?)", subQuery)
+	}
+
+	if skip != "price_buckets" && len(f.PriceBuckets) > 0 {
+		query = query.Where("events.id IN (SELECT event_id FROM ("+eventPricesSQL+") WHERE ("+priceBucketSQL+") IN ?)", f.PriceBuckets)
+	}
+
+	if skip != "date_buckets" && len(f.DateBuckets) > 0 {
+		query = query.Where("("+dateBucketSQL+") IN ?", append(f.dateBucketArgs(), f.DateBuckets)...)
+	}
+
+	if f.HasTickets {
+		query = query.Where(hasTicketsSQL)
+	}
+
+	return query
+}
+
+type facetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// eventFacets counts the matching events per category, city, event type, price
+// bucket and date bucket. base must return a fresh query scoped to listable events.
+func eventFacets(base func() *gorm.DB, f eventFilters) (gin.H, error) {
+	var categories []facetCount
+	err := f.apply(base(), "categories").
+		Joins("LEFT JOIN event_categories ON event_categories.id = events.category_id").
+		Select("COALESCE(CAST(events.category_id AS TEXT), 'none') as value, COALESCE(event_categories.name, 'Uncategorized') as label, COUNT(*) as count").
+		Group("events.category_id").
+		Order("count DESC").
+		Scan(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var cities []facetCount
+	err = f.apply(base(), "cities").
+		Select("events.city as value, COUNT(*) as count").
+		Where("events.city != ''").
+		Group("events.city").
+		Order("count DESC").
+		Scan(&cities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var eventTypes []facetCount
+	err = f.apply(base(), "event_types").
+		Select("CASE WHEN events.is_virtual THEN 'virtual' ELSE 'physical' END as value, COUNT(*) as count").
+		Group("events.is_virtual").
+		Scan(&eventTypes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var priceBuckets []facetCount
+	err = f.apply(base(), "price_buckets").
+		Joins("JOIN (" + eventPricesSQL + ") event_prices ON event_prices.event_id = events.id").
+		Select("(" + priceBucketSQL + ") as value, COUNT(*) as count").
+		Group("value").
+		Scan(&priceBuckets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var dateBuckets []facetCount
+	err = f.apply(base(), "date_buckets").
+		Select("("+dateBucketSQL+") as value, COUNT(*) as count", f.dateBucketArgs()...).
+		Group("value").
+		Scan(&dateBuckets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"categories":     categories,
+		"cities":         cities,
+		"event_types":    eventTypes,
+		"price_buckets":  priceBuckets,
+		"price_currency": f.priceCurrency(),
+		"date_buckets":   dateBuckets,
+	}, nil
+}
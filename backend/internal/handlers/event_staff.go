@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type EventStaffHandler struct {
+	db *gorm.DB
+}
+
+func NewEventStaffHandler() *EventStaffHandler {
+	return &EventStaffHandler{
+		db: database.GetDB(),
+	}
+}
+
+// AddStaff lets an existing user check attendees in at the event
+func (h *EventStaffHandler) AddStaff(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage staff for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := findUserByEmail(h.db, input.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No account found for %s, they need to sign up first", models.NormalizeEmail(input.Email))})
+		return
+	}
+	if user.ID == event.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The organizer can already check attendees in"})
+		return
+	}
+	if models.IsEventStaff(h.db, event.ID, user.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is already on this event's staff", user.Email)})
+		return
+	}
+
+	staff := models.EventStaff{
+		EventID: event.ID,
+		UserID:  user.ID,
+		AddedBy: c.MustGet("userID").(uint),
+		User:    *user,
+	}
+	if err := h.db.Omit("User").Create(&staff).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add staff"})
+		return
+	}
+
+	models.CreateNotification(h.db, user.ID, &event.ID, "You've been added as event staff",
+		fmt.Sprintf("You can now check attendees in at %s.", event.Title),
+		models.NotificationTypeEventUpdate)
+
+	c.JSON(http.StatusCreated, staff)
+}
+
+func (h *EventStaffHandler) GetStaff(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view staff for this event")
+	if !ok {
+		return
+	}
+
+	var staff []models.EventStaff
+	if err := h.db.Preload("User").Where("event_id = ?", event.ID).Order("created_at").Find(&staff).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff"})
+		return
+	}
+
+	c.JSON(http.StatusOK, staff)
+}
+
+func (h *EventStaffHandler) RemoveStaff(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage staff for this event")
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := h.db.Unscoped().Where("event_id = ? AND user_id = ?", event.ID, userID).Delete(&models.EventStaff{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove staff"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Staff member not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Staff member removed"})
+}
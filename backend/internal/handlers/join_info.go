@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// JoinInfoHandler manages the meeting link of virtual events and the emails
+// that carry it to attendees
+type JoinInfoHandler struct {
+	db           *gorm.DB
+	emailService *services.EmailService
+}
+
+func NewJoinInfoHandler(emailService *services.EmailService) *JoinInfoHandler {
+	return &JoinInfoHandler{
+		db:           database.GetDB(),
+		emailService: emailService,
+	}
+}
+
+// GetJoinInfo returns the joining details to the organizer and to attendees
+// with a confirmed registration
+func (h *JoinInfoHandler) GetJoinInfo(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !event.IsVirtual {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This event is not a virtual event"})
+		return
+	}
+
+	if !isEventManager(c, &event) {
+		var registration models.Registration
+		err := h.db.Where("event_id = ? AND user_id = ? AND status != ?", event.ID, userID, models.RegistrationStatusCanceled).
+			Order("status = 'confirmed' DESC").First(&registration).Error
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Joining details are only available to registered attendees"})
+			return
+		}
+		if registration.Status != models.RegistrationStatusConfirmed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Joining details will be available once your registration is confirmed"})
+			return
+		}
+	}
+
+	info, err := models.FindJoinInfoByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The organizer hasn't added joining details yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// SaveJoinInfo sets the joining details. Changing the link or passcode once
+// they're set counts as a rotation and attendees are sent the new details.
+func (h *JoinInfoHandler) SaveJoinInfo(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the joining details for this event")
+	if !ok {
+		return
+	}
+
+	if !event.IsVirtual {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Joining details can only be added to virtual events"})
+		return
+	}
+
+	var input struct {
+		Platform     string `json:"platform"`
+		URL          string `json:"url" binding:"required"`
+		Passcode     string `json:"passcode"`
+		Instructions string `json:"instructions"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateJoinURL(input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := models.FindJoinInfoByEvent(h.db, event.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		info = &models.EventJoinInfo{EventID: event.ID, Version: 1}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load joining details"})
+		return
+	}
+
+	rotated := info.ID != 0 && (info.URL != strings.TrimSpace(input.URL) || info.Passcode != strings.TrimSpace(input.Passcode))
+
+	info.Platform = strings.TrimSpace(input.Platform)
+	info.URL = strings.TrimSpace(input.URL)
+	info.Passcode = strings.TrimSpace(input.Passcode)
+	info.Instructions = input.Instructions
+	if rotated {
+		now := time.Now()
+		info.Version++
+		info.RotatedAt = &now
+	}
+
+	if err := h.db.Save(info).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save joining details"})
+		return
+	}
+
+	notified := 0
+	if rotated {
+		notified = h.notifyAttendees(event, info)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"join_info":          info,
+		"attendees_notified": notified,
+	})
+}
+
+// RotateJoinInfo replaces the passcode (and optionally the link) after the
+// old details have leaked, and sends the new ones to confirmed attendees
+func (h *JoinInfoHandler) RotateJoinInfo(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the joining details for this event")
+	if !ok {
+		return
+	}
+
+	info, err := models.FindJoinInfoByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This event has no joining details to rotate"})
+		return
+	}
+
+	var input struct {
+		URL      string `json:"url"`
+		Passcode string `json:"passcode"`
+	}
+
+	// the body is optional, an empty one just rotates the passcode
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.URL != "" {
+		if err := validateJoinURL(input.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		info.URL = strings.TrimSpace(input.URL)
+	}
+
+	info.Passcode = strings.TrimSpace(input.Passcode)
+	if info.Passcode == "" {
+		passcode, err := models.NewPasscode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate passcode"})
+			return
+		}
+		info.Passcode = passcode
+	}
+
+	now := time.Now()
+	info.Version++
+	info.RotatedAt = &now
+
+	if err := h.db.Save(info).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save joining details"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"join_info":          info,
+		"attendees_notified": h.notifyAttendees(event, info),
+	})
+}
+
+// SendReminders emails every confirmed attendee a reminder, with the joining
+// details for virtual events
+func (h *JoinInfoHandler) SendReminders(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to send reminders for this event")
+	if !ok {
+		return
+	}
+
+	if event.IsCanceled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot send reminders for a canceled event"})
+		return
+	}
+	if !event.StartDatetime.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This event has already started"})
+		return
+	}
+
+	var joinInfo *models.EventJoinInfo
+	if event.IsVirtual {
+		joinInfo, _ = models.FindJoinInfoByEvent(h.db, event.ID)
+	}
+
+	users, err := h.confirmedAttendees(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load attendees"})
+		return
+	}
+
+	message := fmt.Sprintf("Don't forget, '%s' starts on %s.", event.Title, event.StartDatetime.Format("Monday, January 2 at 3:04 PM"))
+	for _, user := range users {
+		models.CreateNotification(h.db, user.ID, &event.ID, "Event Reminder", message, models.NotificationTypeReminder)
+	}
+
+	if h.emailService != nil {
+		go func() {
+			for _, user := range users {
+				if err := h.emailService.SendEventReminder(&user, event, joinInfo); err != nil {
+					log.Printf("Failed to send reminder to %s: %v", user.Email, err)
+				}
+			}
+		}()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Reminders sent",
+		"recipients": len(users),
+	})
+}
+
+// notifyAttendees tells confirmed attendees about new joining details and
+// returns how many were told
+func (h *JoinInfoHandler) notifyAttendees(event *models.Event, info *models.EventJoinInfo) int {
+	users, err := h.confirmedAttendees(event.ID)
+	if err != nil {
+		log.Printf("Failed to load attendees of event %d: %v", event.ID, err)
+		return 0
+	}
+
+	message := fmt.Sprintf("The joining details for '%s' have changed. The previous link no longer works, check your email or the event page for the new one.", event.Title)
+	for _, user := range users {
+		models.CreateNotification(h.db, user.ID, &event.ID, "Joining Details Changed", message, models.NotificationTypeEventUpdate)
+	}
+
+	if h.emailService != nil {
+		joinInfo := *info
+		go func() {
+			for _, user := range users {
+				if err := h.emailService.SendJoinInfoUpdated(&user, event, &joinInfo); err != nil {
+					log.Printf("Failed to send joining details to %s: %v", user.Email, err)
+				}
+			}
+		}()
+	}
+
+	return len(users)
+}
+
+func (h *JoinInfoHandler) confirmedAttendees(eventID uint) ([]models.User, error) {
+	var users []models.User
+	err := h.db.Where("id IN (?)", h.db.Model(&models.Registration{}).
+		Select("user_id").
+		Where("event_id = ? AND status = ?", eventID, models.RegistrationStatusConfirmed)).
+		Find(&users).Error
+	return users, err
+}
+
+func validateJoinURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("Joining link must be a full http or https URL")
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"lujke-dunn/314-group-project/backend/internal/storage"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxGalleryImages      = 20
+	maxImagesPerUpload    = 10
+	multipartFormOverhead = 1 << 20
+)
+
+var errUploadTooLarge = errors.New("file is too large")
+
+type MediaHandler struct {
+	db             *gorm.DB
+	store          storage.Storage
+	maxUploadBytes int64
+}
+
+func NewMediaHandler(store storage.Storage, maxUploadBytes int64) *MediaHandler {
+	return &MediaHandler{
+		db:             database.GetDB(),
+		store:          store,
+		maxUploadBytes: maxUploadBytes,
+	}
+}
+
+// attachImageURLs fills in the public urls of the original image and each thumbnail
+func attachImageURLs(store storage.Storage, images []models.EventImage) {
+	for i := range images {
+		urls := map[string]string{"original": store.URL(images[i].OriginalKey())}
+		for name := range models.ImageVariants {
+			urls[name] = store.URL(images[i].VariantKey(name))
+		}
+		images[i].URLs = urls
+	}
+}
+
+func (h *MediaHandler) UploadCoverImage(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage images for this event")
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+multipartFormOverhead)
+
+	file, err := c.FormFile("image")
+	if isBodyTooLarge(err) {
+		h.uploadError(c, errUploadTooLarge)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An image file is required in the 'image' field"})
+		return
+	}
+
+	image, err := h.storeImage(c.Request.Context(), event.ID, models.ImageKindCover, c.PostForm("caption"), file)
+	if err != nil {
+		h.uploadError(c, err)
+		return
+	}
+
+	var previous []models.EventImage
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ? AND kind = ?", event.ID, models.ImageKindCover).Find(&previous).Error; err != nil {
+			return err
+		}
+		if len(previous) > 0 {
+			if err := tx.Delete(&previous).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(image).Error
+	})
+	if err != nil {
+		h.deleteFiles(image)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cover image"})
+		return
+	}
+
+	for i := range previous {
+		h.deleteFiles(&previous[i])
+	}
+
+	images := []models.EventImage{*image}
+	attachImageURLs(h.store, images)
+
+	c.JSON(http.StatusCreated, images[0])
+}
+
+func (h *MediaHandler) DeleteCoverImage(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage images for this event")
+	if !ok {
+		return
+	}
+
+	image, err := models.FindCoverImage(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event has no cover image"})
+		return
+	}
+
+	if err := h.db.Delete(image).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cover image"})
+		return
+	}
+	h.deleteFiles(image)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cover image deleted successfully"})
+}
+
+func (h *MediaHandler) UploadGalleryImages(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage images for this event")
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImagesPerUpload*h.maxUploadBytes+multipartFormOverhead)
+
+	form, err := c.MultipartForm()
+	if isBodyTooLarge(err) {
+		h.uploadError(c, errUploadTooLarge)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return
+	}
+
+	files := append(form.File["images"], form.File["image"]...)
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one file is required in the 'images' field"})
+		return
+	}
+	if len(files) > maxImagesPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d images can be uploaded at once", maxImagesPerUpload)})
+		return
+	}
+
+	var existing int64
+	h.db.Model(&models.EventImage{}).Where("event_id = ? AND kind = ?", event.ID, models.ImageKindGallery).Count(&existing)
+	if int(existing)+len(files) > maxGalleryImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An event gallery can hold at most %d images", maxGalleryImages)})
+		return
+	}
+
+	var position int
+	h.db.Model(&models.EventImage{}).
+		Where("event_id = ? AND kind = ?", event.ID, models.ImageKindGallery).
+		Select("COALESCE(MAX(position), 0)").
+		Scan(&position)
+
+	caption := c.PostForm("caption")
+	var images []models.EventImage
+	for _, file := range files {
+		image, err := h.storeImage(c.Request.Context(), event.ID, models.ImageKindGallery, caption, file)
+		if err != nil {
+			for i := range images {
+				h.deleteFiles(&images[i])
+			}
+			h.uploadError(c, fmt.Errorf("%s: %w", file.Filename, err))
+			return
+		}
+		position++
+		image.Position = position
+		images = append(images, *image)
+	}
+
+	if err := h.db.Create(&images).Error; err != nil {
+		for i := range images {
+			h.deleteFiles(&images[i])
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save gallery images"})
+		return
+	}
+
+	attachImageURLs(h.store, images)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Images uploaded successfully",
+		"images":  images,
+	})
+}
+
+func (h *MediaHandler) DeleteGalleryImage(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage images for this event")
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.ParseUint(c.Param("image_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	var image models.EventImage
+	if err := h.db.Where("id = ? AND event_id = ?", imageID, event.ID).First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found for this event"})
+		return
+	}
+
+	if err := h.db.Delete(&image).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	h.deleteFiles(&image)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+// storeImage validates an uploaded file, generates its thumbnails and writes
+// everything to storage. The returned image has not been saved to the database.
+func (h *MediaHandler) storeImage(ctx context.Context, eventID uint, kind models.ImageKind, caption string, file *multipart.FileHeader) (*models.EventImage, error) {
+	if file.Size > h.maxUploadBytes {
+		return nil, errUploadTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, h.maxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > h.maxUploadBytes {
+		return nil, errUploadTooLarge
+	}
+
+	processed, err := services.ProcessImage(data, models.ImageVariants)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 12)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	image := &models.EventImage{
+		EventID:     eventID,
+		Kind:        kind,
+		Caption:     caption,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		SizeBytes:   int64(len(data)),
+		KeyPrefix:   fmt.Sprintf("events/%d/%s", eventID, hex.EncodeToString(token)),
+		Extension:   processed.Extension,
+	}
+
+	if err := h.store.Put(ctx, image.OriginalKey(), data, processed.ContentType); err != nil {
+		return nil, err
+	}
+	for name, thumb := range processed.Variants {
+		if err := h.store.Put(ctx, image.VariantKey(name), thumb, "image/jpeg"); err != nil {
+			h.deleteFiles(image)
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+func (h *MediaHandler) deleteFiles(image *models.EventImage) {
+	for _, key := range image.StorageKeys() {
+		if err := h.store.Delete(context.Background(), key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+// isBodyTooLarge reports whether err came from the request body going over
+// its http.MaxBytesReader limit
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+func (h *MediaHandler) uploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errUploadTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s, the limit is %d MB", err.Error(), h.maxUploadBytes>>20)})
+	case errors.Is(err, services.ErrUnsupportedImageType), errors.Is(err, services.ErrImageTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to store image: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+	}
+}
@@ -0,0 +1,766 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxTicketsPerOrder = 20
+
+type OrderHandler struct {
+	db       *gorm.DB
+	waitlist *services.Waitlist
+}
+
+func NewOrderHandler(waitlist *services.Waitlist) *OrderHandler {
+	return &OrderHandler{
+		db:       database.GetDB(),
+		waitlist: waitlist,
+	}
+}
+
+type attendeeInput struct {
+	Name    string               `json:"name"`
+	Email   string               `json:"email"`
+	SeatID  *uint                `json:"seat_id"`
+	Answers []models.AnswerInput `json:"answers"`
+}
+
+// orderTicket is a checked ticket of an order waiting to be created
+type orderTicket struct {
+	ticketType *models.TicketType
+	attendee   attendeeInput
+	answers    []models.RegistrationAnswer
+	seated     bool
+	// set when the ticket is part of a bundle, bundlePrice is its share
+	bundle      *models.Bundle
+	bundlePrice models.Money
+	addOns      []models.AddOnLine
+}
+
+// CreateOrder buys tickets of one or more ticket types in a single checkout.
+// Each item can name the attendees its tickets are for; tickets without an
+// attendee are for the buyer and can be assigned later. Bundles add all their
+// tickets, and each add-on goes with the first ticket it can be bought with.
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		EventID    uint   `json:"event_id" binding:"required"`
+		Key        string `json:"key"`
+		AccessCode string `json:"access_code"`
+		PromoCode  string `json:"promo_code"`
+		Items      []struct {
+			TicketTypeID uint            `json:"ticket_type_id" binding:"required"`
+			Quantity     int             `json:"quantity"`
+			Attendees    []attendeeInput `json:"attendees"`
+		} `json:"items" binding:"dive"`
+		Bundles []struct {
+			BundleID  uint            `json:"bundle_id" binding:"required"`
+			Quantity  int             `json:"quantity"`
+			Attendees []attendeeInput `json:"attendees"`
+		} `json:"bundles" binding:"dive"`
+		AddOns []addOnInput `json:"add_ons" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Items) == 0 && len(input.Bundles) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An order needs at least one item or bundle"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, input.EventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !event.IsPublished || event.IsCanceled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event is not available for registration"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, input.Key) {
+		return
+	}
+
+	var accessCode *models.AccessCode
+	if input.AccessCode != "" {
+		accessCode, _ = models.FindAccessCode(h.db, event.ID, input.AccessCode)
+	}
+
+	var promo *models.PromoCode
+	if input.PromoCode != "" {
+		var err error
+		promo, err = models.FindPromoCode(h.db, event.ID, input.PromoCode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code"})
+			return
+		}
+	}
+
+	var tickets []orderTicket
+	seenTypes := make(map[uint]bool, len(input.Items))
+	for _, item := range input.Items {
+		if seenTypes[item.TicketTypeID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each ticket type can only be listed once per order"})
+			return
+		}
+		seenTypes[item.TicketTypeID] = true
+
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = len(item.Attendees)
+		}
+		if quantity < 1 || quantity < len(item.Attendees) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be at least 1 and cover every attendee listed"})
+			return
+		}
+		if len(tickets)+quantity > maxTicketsPerOrder {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An order can have at most %d tickets", maxTicketsPerOrder)})
+			return
+		}
+
+		checked, ok := h.checkTickets(c, &event, accessCode, item.TicketTypeID, quantity, item.Attendees)
+		if !ok {
+			return
+		}
+		tickets = append(tickets, checked...)
+	}
+
+	for _, item := range input.Bundles {
+		bundle, err := models.FindBundle(h.db, event.ID, item.BundleID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found for this event"})
+			return
+		}
+
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		if quantity < 1 || quantity*bundle.TicketCount() < len(item.Attendees) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be at least 1 and cover every attendee listed"})
+			return
+		}
+		if len(tickets)+quantity*bundle.TicketCount() > maxTicketsPerOrder {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An order can have at most %d tickets", maxTicketsPerOrder)})
+			return
+		}
+
+		// attendees are given the bundle's tickets in the order they're listed
+		attendees := item.Attendees
+		for i := 0; i < quantity; i++ {
+			var bundleTickets []orderTicket
+			for _, bundleItem := range bundle.Items {
+				n := min(len(attendees), bundleItem.Quantity)
+				checked, ok := h.checkTickets(c, &event, accessCode, bundleItem.TicketTypeID, bundleItem.Quantity, attendees[:n])
+				if !ok {
+					return
+				}
+				attendees = attendees[n:]
+				bundleTickets = append(bundleTickets, checked...)
+			}
+
+			listPrices := make([]models.Money, len(bundleTickets))
+			for j := range bundleTickets {
+				listPrices[j] = bundleTickets[j].ticketType.Price
+			}
+			for j, share := range models.SplitBundlePrice(bundle.Price, listPrices) {
+				bundleTickets[j].bundle = bundle
+				bundleTickets[j].bundlePrice = share
+			}
+			tickets = append(tickets, bundleTickets...)
+		}
+	}
+
+	addOns, err := findAddOns(h.db, event.ID, input.AddOns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, line := range addOns {
+		added := false
+		for i := range tickets {
+			if line.AddOn.AppliesTo(tickets[i].ticketType.ID) {
+				tickets[i].addOns = append(tickets[i].addOns, line)
+				added = true
+				break
+			}
+		}
+		if !added {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s can't be added to the tickets in this order", line.AddOn.Name)})
+			return
+		}
+	}
+
+	// the promo code discounts every ticket of the ticket types it covers,
+	// bundles are already discounted
+	discounted := 0
+	if promo != nil {
+		for _, ticket := range tickets {
+			if ticket.bundle == nil && promo.AppliesTo(ticket.ticketType.ID) {
+				discounted++
+			}
+		}
+		if discounted == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrPromoCodeTicketType.Error()})
+			return
+		}
+	}
+
+	// everything is created in one transaction so a sold out ticket type or a
+	// taken seat leaves no partial order behind
+	order := models.Order{UserID: userID.(uint), EventID: event.ID, Status: models.OrderStatusPending, Currency: event.Currency}
+	var current *orderTicket
+	var seatTaken bool
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		purchase := models.Purchase{UserID: order.UserID, Quantities: make(map[uint]int)}
+		for _, ticket := range tickets {
+			purchase.Quantities[ticket.ticketType.ID]++
+			purchase.AttendeeEmails = append(purchase.AttendeeEmails, ticket.attendee.Email)
+		}
+		if err := models.CheckPurchaseLimits(tx, &event, purchase); err != nil {
+			return err
+		}
+		if promo != nil {
+			if err := promo.CheckUsable(tx, order.UserID, discounted); err != nil {
+				return err
+			}
+		}
+
+		created := make([]models.Registration, 0, len(tickets))
+		for i := range tickets {
+			current = &tickets[i]
+			registration := models.Registration{
+				UserID:        order.UserID,
+				EventID:       event.ID,
+				TicketTypeID:  current.ticketType.ID,
+				Status:        models.RegistrationStatusPending,
+				OrderID:       &order.ID,
+				AttendeeName:  current.attendee.Name,
+				AttendeeEmail: current.attendee.Email,
+			}
+			if current.ticketType.IsHidden {
+				if err := accessCode.CheckUsable(tx); err != nil {
+					return err
+				}
+				registration.AccessCodeID = &accessCode.ID
+			}
+			if err := tx.Create(&registration).Error; err != nil {
+				return err
+			}
+			if current.bundle != nil {
+				if err := registration.ApplyBundlePrice(tx, current.bundle.ID, current.bundlePrice); err != nil {
+					return err
+				}
+			} else if promo != nil && promo.AppliesTo(registration.TicketTypeID) {
+				if err := registration.ApplyPromoCode(tx, promo); err != nil {
+					return err
+				}
+			}
+			if err := registration.AddAddOns(tx, current.addOns); err != nil {
+				return err
+			}
+			created = append(created, registration)
+
+			if len(current.answers) > 0 {
+				for j := range current.answers {
+					current.answers[j].RegistrationID = registration.ID
+				}
+				if err := tx.Create(&current.answers).Error; err != nil {
+					return err
+				}
+			}
+			if current.seated {
+				if _, err := models.AssignSeat(tx, &registration, *current.attendee.SeatID); err != nil {
+					seatTaken = true
+					return err
+				}
+			}
+		}
+
+		breakdown := models.OrderBreakdown(created)
+		order.TotalPrice = breakdown.Subtotal
+		order.DiscountAmount = breakdown.Discount
+		order.AddOnAmount = breakdown.AddOns
+		order.FeeAmount = breakdown.Fees
+		order.TaxAmount = breakdown.Tax
+		order.AmountDue = breakdown.Total
+		if promo != nil {
+			order.PromoCodeID = &promo.ID
+		}
+		return tx.Model(&order).Updates(map[string]interface{}{
+			"total_price":     order.TotalPrice,
+			"discount_amount": order.DiscountAmount,
+			"add_on_amount":   order.AddOnAmount,
+			"fee_amount":      order.FeeAmount,
+			"tax_amount":      order.TaxAmount,
+			"amount_due":      order.AmountDue,
+			"promo_code_id":   order.PromoCodeID,
+		}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrAccessCodeExpired), errors.Is(err, models.ErrAccessCodeUsedUp), models.IsPromoCodeError(err), models.IsPurchaseLimitError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case seatTaken:
+			seatError(c, err)
+		case errors.Is(err, models.ErrAddOnSoldOut):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case current != nil && errors.Is(err, models.ErrSoldOut):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s tickets sold out while placing your order", current.ticketType.Name)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		}
+		return
+	}
+
+	if err := order.LoadTickets(h.db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order created successfully",
+		"order":   h.orderResponse(&order),
+	})
+}
+
+// checkTickets checks quantity tickets of a ticket type can be bought and
+// pairs them with the attendees listed for them
+func (h *OrderHandler) checkTickets(c *gin.Context, event *models.Event, accessCode *models.AccessCode, ticketTypeID uint, quantity int, attendees []attendeeInput) ([]orderTicket, bool) {
+	var ticketType models.TicketType
+	if err := h.db.Where("id = ? AND event_id = ?", ticketTypeID, event.ID).First(&ticketType).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found for this event"})
+		return nil, false
+	}
+
+	if !ticketType.IsOnSale() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s tickets are not currently on sale", ticketType.Name)})
+		return nil, false
+	}
+
+	if ticketType.IsHidden && (accessCode == nil || !accessCode.Unlocks(ticketType.ID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("A valid access code is required for %s tickets", ticketType.Name)})
+		return nil, false
+	}
+
+	available, err := ticketType.GetAvailableQuantity(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket availability"})
+		return nil, false
+	}
+	if available < quantity {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Only %d %s tickets are left", max(available, 0), ticketType.Name)})
+		return nil, false
+	}
+
+	seated, err := models.IsSeatedTicketType(h.db, ticketType.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket availability"})
+		return nil, false
+	}
+	if seated && len(attendees) != quantity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s tickets have reserved seating, list an attendee with a seat_id for each ticket", ticketType.Name)})
+		return nil, false
+	}
+
+	questions, err := models.FindQuestionsForTicketType(h.db, event.ID, ticketType.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load registration questions"})
+		return nil, false
+	}
+
+	tickets := make([]orderTicket, 0, quantity)
+	for i := 0; i < quantity; i++ {
+		var attendee attendeeInput
+		if i < len(attendees) {
+			attendee = attendees[i]
+		}
+		if err := cleanAttendee(&attendee); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if seated && attendee.SeatID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrSeatRequired.Error()})
+			return nil, false
+		}
+		if !seated && attendee.SeatID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s tickets don't have reserved seating", ticketType.Name)})
+			return nil, false
+		}
+
+		answers, err := models.CheckAnswers(questions, attendee.Answers)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": attendeeError(attendee, err)})
+			return nil, false
+		}
+
+		tickets = append(tickets, orderTicket{ticketType: &ticketType, attendee: attendee, answers: answers, seated: seated})
+	}
+	return tickets, true
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, ok := h.findOrder(c, true)
+	if !ok {
+		return
+	}
+
+	if err := order.LoadTickets(h.db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.orderResponse(order))
+}
+
+func (h *OrderHandler) GetUserOrders(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	query := h.db.Model(&models.Order{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	orders, page, err := paginate[models.Order](c, query, "orders", sortKey{Name: "created", Expr: "orders.created_at", Desc: true}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch orders")
+		return
+	}
+
+	// events and ticket counts for the whole page at once
+	orderIDs := make([]uint, 0, len(orders))
+	eventIDs := make([]uint, 0, len(orders))
+	for i := range orders {
+		orderIDs = append(orderIDs, orders[i].ID)
+		eventIDs = append(eventIDs, orders[i].EventID)
+	}
+
+	var events []models.Event
+	h.db.Select("id, title, start_datetime").Where("id IN ?", eventIDs).Find(&events)
+	eventsByID := make(map[uint]models.Event, len(events))
+	for _, event := range events {
+		eventsByID[event.ID] = event
+	}
+
+	var counts []struct {
+		OrderID uint
+		Count   int64
+	}
+	h.db.Model(&models.Registration{}).Select("order_id, COUNT(*) as count").
+		Where("order_id IN ?", orderIDs).Group("order_id").Scan(&counts)
+	ticketCounts := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		ticketCounts[count.OrderID] = count.Count
+	}
+
+	response := make([]gin.H, 0, len(orders))
+	for i := range orders {
+		event := eventsByID[orders[i].EventID]
+		ticketCount := ticketCounts[orders[i].ID]
+
+		response = append(response, gin.H{
+			"id":           orders[i].ID,
+			"event_id":     orders[i].EventID,
+			"event_title":  event.Title,
+			"event_start":  event.StartDatetime,
+			"status":       orders[i].Status,
+			"total_price":  orders[i].TotalPrice,
+			"amount_due":   orders[i].AmountDue,
+			"currency":     orders[i].Currency,
+			"ticket_count": ticketCount,
+			"created_at":   orders[i].CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders":     response,
+		"pagination": page,
+	})
+}
+
+// UpdateOrderTicket names the attendee of one ticket in the order and
+// replaces their answers
+func (h *OrderHandler) UpdateOrderTicket(c *gin.Context) {
+	order, ok := h.findOrder(c, false)
+	if !ok {
+		return
+	}
+
+	ticketID, err := strconv.ParseUint(c.Param("ticket_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	var ticket models.Registration
+	if err := h.db.Where("id = ? AND order_id = ?", ticketID, order.ID).First(&ticket).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found in this order"})
+		return
+	}
+
+	if ticket.Status == models.RegistrationStatusCanceled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This ticket has been canceled"})
+		return
+	}
+
+	if ticket.UserID != order.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This ticket has been transferred to someone else"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, order.EventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !event.StartDatetime.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attendees can't be changed once the event has started"})
+		return
+	}
+
+	var input attendeeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.SeatID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Seats can't be changed here"})
+		return
+	}
+	if err := cleanAttendee(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	questions, err := models.FindQuestionsForTicketType(h.db, event.ID, ticket.TicketTypeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load registration questions"})
+		return
+	}
+	answers, err := models.CheckAnswers(questions, input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": attendeeError(input, err)})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		ticket.AttendeeName = input.Name
+		ticket.AttendeeEmail = input.Email
+		if err := tx.Model(&ticket).Updates(map[string]interface{}{
+			"attendee_name":  ticket.AttendeeName,
+			"attendee_email": ticket.AttendeeEmail,
+		}).Error; err != nil {
+			return err
+		}
+		// answers to questions that are no longer asked are kept
+		var questionIDs []uint
+		for _, q := range questions {
+			questionIDs = append(questionIDs, q.ID)
+		}
+		if len(questionIDs) > 0 {
+			if err := tx.Where("registration_id = ? AND question_id IN ?", ticket.ID, questionIDs).Delete(&models.RegistrationAnswer{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range answers {
+			answers[i].RegistrationID = ticket.ID
+		}
+		if len(answers) > 0 {
+			return tx.Create(&answers).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticket":  ticket,
+		"answers": answers,
+	})
+}
+
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	order, ok := h.findOrder(c, true)
+	if !ok {
+		return
+	}
+
+	if order.Status == models.OrderStatusCanceled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is already canceled"})
+		return
+	}
+
+	if err := order.Cancel(h.db); err != nil {
+		if errors.Is(err, models.ErrOrderPaid) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+		return
+	}
+
+	var ticketTypeIDs []uint
+	h.db.Model(&models.Registration{}).Where("order_id = ?", order.ID).Distinct().Pluck("ticket_type_id", &ticketTypeIDs)
+	for _, id := range ticketTypeIDs {
+		h.waitlist.Promote(id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order canceled successfully",
+		"order":   order,
+	})
+}
+
+// findOrder loads the order named by the :id param if it belongs to the
+// current user, or to anyone when allowAdmin is set and the user is an admin
+func (h *OrderHandler) findOrder(c *gin.Context, allowAdmin bool) (*models.Order, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return nil, false
+	}
+
+	order, err := models.FindOrderByID(h.db, uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+
+	if order.UserID != userID.(uint) {
+		isAdmin, exists := c.Get("isAdmin")
+		if !allowAdmin || !exists || !isAdmin.(bool) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this order"})
+			return nil, false
+		}
+	}
+
+	return order, true
+}
+
+// orderResponse lists the order's tickets with their attendee, seat and answers
+func (h *OrderHandler) orderResponse(order *models.Order) gin.H {
+	ids := make([]uint, 0, len(order.Tickets))
+	for _, ticket := range order.Tickets {
+		ids = append(ids, ticket.ID)
+	}
+	answers, _ := models.FindAnswersByRegistrations(h.db, ids)
+	addOns, _ := models.FindAddOnsByRegistrations(h.db, ids)
+
+	tickets := make([]gin.H, 0, len(order.Tickets))
+	var expiresAt *time.Time
+	var active []models.Registration
+	for _, ticket := range order.Tickets {
+		if ticket.Status != models.RegistrationStatusCanceled {
+			active = append(active, ticket)
+		}
+		var seat *models.Seat
+		if assignment, err := models.FindSeatAssignment(h.db, ticket.ID); err == nil {
+			seat = &assignment.Seat
+		}
+		// the order is released as soon as its first ticket's hold lapses
+		if ticket.ExpiresAt != nil && (expiresAt == nil || ticket.ExpiresAt.Before(*expiresAt)) {
+			expiresAt = ticket.ExpiresAt
+		}
+		ticketAnswers := answers[ticket.ID]
+		if ticketAnswers == nil {
+			ticketAnswers = []models.RegistrationAnswer{}
+		}
+		ticketAddOns := addOns[ticket.ID]
+		if ticketAddOns == nil {
+			ticketAddOns = []models.RegistrationAddOn{}
+		}
+		tickets = append(tickets, gin.H{
+			"id":             ticket.ID,
+			"ticket_type_id": ticket.TicketTypeID,
+			"ticket_name":    ticket.TicketType.Name,
+			"bundle_id":      ticket.BundleID,
+			"price":          ticket.TotalPrice,
+			"discount":       ticket.DiscountAmount,
+			"add_ons":        ticketAddOns,
+			"breakdown":      ticket.Breakdown(),
+			"status":         ticket.Status,
+			"attendee_name":  ticket.AttendeeName,
+			"attendee_email": ticket.AttendeeEmail,
+			"seat":           seat,
+			"answers":        ticketAnswers,
+		})
+	}
+
+	return gin.H{
+		"id":              order.ID,
+		"user_id":         order.UserID,
+		"event_id":        order.EventID,
+		"status":          order.Status,
+		"total_price":     order.TotalPrice,
+		"promo_code_id":   order.PromoCodeID,
+		"discount_amount": order.DiscountAmount,
+		"add_on_amount":   order.AddOnAmount,
+		"fee_amount":      order.FeeAmount,
+		"tax_amount":      order.TaxAmount,
+		"amount_due":      order.AmountDue,
+		"currency":        order.Currency,
+		"breakdown":       models.OrderBreakdown(active),
+		"expires_at":      expiresAt,
+		"created_at":      order.CreatedAt,
+		"tickets":         tickets,
+	}
+}
+
+// cleanAttendee trims the attendee's details and checks their email
+func cleanAttendee(attendee *attendeeInput) error {
+	attendee.Name = strings.TrimSpace(attendee.Name)
+	attendee.Email = models.NormalizeEmail(attendee.Email)
+	if attendee.Email != "" {
+		if _, err := mail.ParseAddress(attendee.Email); err != nil {
+			return fmt.Errorf("'%s' is not a valid email address", attendee.Email)
+		}
+	}
+	if len(attendee.Name) > 255 {
+		return errors.New("Attendee name is too long")
+	}
+	return nil
+}
+
+// attendeeError says whose answers were wrong when an order has several attendees
+func attendeeError(attendee attendeeInput, err error) string {
+	if attendee.Name != "" {
+		return fmt.Sprintf("%s: %s", attendee.Name, err.Error())
+	}
+	return err.Error()
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	errInvalidCursor   = errors.New("Invalid pagination cursor")
+	errInvalidPageSize = errors.New("Limit must be a positive number")
+)
+
+// sortKey describes the ordering of a paginated list. Expr is evaluated
+// against the listed table and must never be NULL; rows with equal keys are
+// ordered by id in the same direction so cursors stay stable.
+type sortKey struct {
+	Name string
+	Expr string
+	Desc bool
+}
+
+type pageInfo struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// pageCursor is the decoded form of the opaque cursor handed to clients
+type pageCursor struct {
+	Sort    string      `json:"s"`
+	Key     interface{} `json:"k"`
+	KeyType string      `json:"t,omitempty"`
+	ID      uint        `json:"id"`
+}
+
+func encodeCursor(cursor pageCursor) string {
+	if t, ok := cursor.Key.(time.Time); ok {
+		cursor.Key = t.Format(time.RFC3339Nano)
+		cursor.KeyType = "time"
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 || cursor.Key == nil {
+		return nil, errInvalidCursor
+	}
+
+	if cursor.KeyType == "time" {
+		s, ok := cursor.Key.(string)
+		if !ok {
+			return nil, errInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		cursor.Key = t
+	}
+
+	return &cursor, nil
+}
+
+func pageLimit(c *gin.Context, defaultLimit int) (int, error) {
+	raw := c.Query("limit")
+	if raw == "" {
+		raw = c.Query("per_page")
+	}
+	if raw == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, errInvalidPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return limit, nil
+}
+
+// paginate fetches one page of query using keyset pagination on key and the
+// row id. The cursor and limit come from the request; a Link header pointing
+// at the next page is set when there is one.
+func paginate[T any](c *gin.Context, query *gorm.DB, table string, key sortKey, defaultLimit int) ([]T, pageInfo, error) {
+	limit, err := pageLimit(c, defaultLimit)
+	if err != nil {
+		return nil, pageInfo{}, err
+	}
+
+	idColumn := table + ".id"
+	op, direction := ">", "ASC"
+	if key.Desc {
+		op, direction = "<", "DESC"
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil || cursor.Sort != key.Name {
+			return nil, pageInfo{}, errInvalidCursor
+		}
+		query = query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s %[2]s ?))", key.Expr, op, idColumn),
+			cursor.Key, cursor.Key, cursor.ID)
+	}
+
+	var items []T
+	err = query.Order(fmt.Sprintf("%s %s, %s %s", key.Expr, direction, idColumn, direction)).
+		Limit(limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, pageInfo{}, err
+	}
+
+	info := pageInfo{Limit: limit}
+	if len(items) <= limit {
+		return items, info, nil
+	}
+
+	items = items[:limit]
+	lastID := uint(reflect.ValueOf(items[limit-1]).FieldByName("ID").Uint())
+
+	var lastKey interface{}
+	err = query.Session(&gorm.Session{NewDB: true}).
+		Table(table).
+		Select(key.Expr).
+		Where(idColumn+" = ?", lastID).
+		Row().
+		Scan(&lastKey)
+	if err != nil {
+		return nil, pageInfo{}, err
+	}
+	if b, ok := lastKey.([]byte); ok {
+		lastKey = string(b)
+	}
+
+	info.HasMore = true
+	info.NextCursor = encodeCursor(pageCursor{Sort: key.Name, Key: lastKey, ID: lastID})
+
+	next := *c.Request.URL
+	params := next.Query()
+	params.Set("cursor", info.NextCursor)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Del("per_page")
+	next.RawQuery = params.Encode()
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+
+	return items, info, nil
+}
+
+// paginationError writes the response for an error returned by paginate
+func paginationError(c *gin.Context, err error, message string) {
+	if errors.Is(err, errInvalidCursor) || errors.Is(err, errInvalidPageSize) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"lujke-dunn/314-group-project/backend/internal/database"
 	"lujke-dunn/314-group-project/backend/internal/models"
 	"lujke-dunn/314-group-project/backend/internal/services"
@@ -57,6 +60,24 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 		return
 	}
 
+	// the sweeper may not have got to it yet
+	if registration.HoldExpired() {
+		registration.ExpireHold(h.db)
+		c.JSON(http.StatusConflict, gin.H{"error": models.ErrHoldExpired.Error()})
+		return
+	}
+
+	// tickets bought together are paid for together
+	if registration.OrderID != nil {
+		order, err := models.FindOrderByID(h.db, *registration.OrderID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		h.payOrder(c, order)
+		return
+	}
+
 	var input struct {
 		Method        models.PaymentMethod `json:"method" binding:"required"`
 		TransactionID string               `json:"transaction_id"`
@@ -67,13 +88,17 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 		return
 	}
 
-	payment, err := models.CreatePayment(h.db, registration.ID, registration.TotalPrice, input.Method, input.TransactionID)
+	payment, err := models.CreatePayment(h.db, registration.ID, registration.Breakdown(), input.Method, input.TransactionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
 		return
 	}
 
 	if err := payment.Process(h.db); err != nil {
+		if errors.Is(err, models.ErrHoldExpired) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment: " + err.Error()})
 		return
 	}
@@ -93,11 +118,16 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 	user, _ := models.FindUserByID(h.db, userID.(uint))
 	event, _ := models.FindEventByID(h.db, registration.EventID)
 	ticketType, _ := models.FindTicketTypeByID(h.db, registration.TicketTypeID)
+	addOns, _ := models.FindAddOnsByRegistrations(h.db, []uint{registration.ID})
 	
 	if h.emailService != nil && user != nil && event != nil {
 		go func() {
-			h.emailService.SendPaymentConfirmation(user, event.Title, payment.Amount)
-			h.emailService.SendRegistrationConfirmation(user, event.Title, ticketType.Name)
+			var joinInfo *models.EventJoinInfo
+			if updatedRegistration != nil {
+				joinInfo = models.JoinInfoForRegistration(h.db, updatedRegistration, event)
+			}
+			h.emailService.SendPaymentConfirmation(user, event.Title, registration.Breakdown(), addOns[registration.ID])
+			h.emailService.SendRegistrationConfirmation(user, event, ticketType.Name, joinInfo, updatedRegistration)
 		}()
 	}
 
@@ -109,6 +139,161 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 	})
 }
 
+func (h *PaymentHandler) ProcessOrderPayment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := models.FindOrderByID(h.db, uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	if order.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to process payment for this order"})
+		return
+	}
+
+	h.payOrder(c, order)
+}
+
+// payOrder takes one payment for every ticket of the order that hasn't been
+// canceled and confirms them all
+func (h *PaymentHandler) payOrder(c *gin.Context, order *models.Order) {
+	if order.Status == models.OrderStatusPaid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is already paid"})
+		return
+	}
+
+	if order.Status == models.OrderStatusCanceled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot process payment for canceled order"})
+		return
+	}
+
+	var input struct {
+		Method        models.PaymentMethod `json:"method" binding:"required"`
+		TransactionID string               `json:"transaction_id"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tickets, err := order.ActiveTickets(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order tickets"})
+		return
+	}
+	if len(tickets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order has no tickets left to pay for"})
+		return
+	}
+	// expiring any one ticket's hold releases the whole order
+	for i := range tickets {
+		if tickets[i].HoldExpired() {
+			tickets[i].ExpireHold(h.db)
+			c.JSON(http.StatusConflict, gin.H{"error": models.ErrHoldExpired.Error()})
+			return
+		}
+	}
+
+	breakdown := models.OrderBreakdown(tickets)
+	payment := models.Payment{
+		RegistrationID: tickets[0].ID,
+		OrderID:        &order.ID,
+		Amount:         breakdown.Total,
+		Subtotal:       breakdown.Subtotal,
+		AddOnAmount:    breakdown.AddOns,
+		FeeAmount:      breakdown.Fees,
+		TaxAmount:      breakdown.Tax,
+		Currency:       order.Currency,
+		Status:         models.PaymentStatusPending,
+		Method:         input.Method,
+		TransactionID:  input.TransactionID,
+	}
+	if err := h.db.Create(&payment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
+		return
+	}
+
+	if err := payment.Process(h.db); err != nil {
+		if errors.Is(err, models.ErrHoldExpired) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment: " + err.Error()})
+		return
+	}
+
+	order, _ = models.FindOrderByID(h.db, order.ID)
+	order.LoadTickets(h.db)
+
+	notification, _ := models.CreateNotification(
+		h.db,
+		order.UserID,
+		&order.EventID,
+		"Order Confirmed",
+		fmt.Sprintf("Your order of %d ticket(s) has been confirmed. Thank you for your payment", len(tickets)),
+		models.NotificationTypePayment,
+	)
+
+	// the buyer gets the receipt, each attendee their own ticket confirmation
+	user, _ := models.FindUserByID(h.db, order.UserID)
+	event, _ := models.FindEventByID(h.db, order.EventID)
+
+	ticketIDs := make([]uint, len(tickets))
+	for i := range tickets {
+		ticketIDs[i] = tickets[i].ID
+	}
+	byTicket, _ := models.FindAddOnsByRegistrations(h.db, ticketIDs)
+	var addOns []models.RegistrationAddOn
+	for _, id := range ticketIDs {
+		addOns = append(addOns, byTicket[id]...)
+	}
+
+	if h.emailService != nil && user != nil && event != nil {
+		paid := *order
+		go func() {
+			h.emailService.SendPaymentConfirmation(user, event.Title, breakdown, addOns)
+			for i := range paid.Tickets {
+				ticket := &paid.Tickets[i]
+				if ticket.Status != models.RegistrationStatusConfirmed {
+					continue
+				}
+				recipient := user
+				if ticket.AttendeeEmail != "" {
+					name := ticket.AttendeeName
+					if name == "" {
+						name = "Guest"
+					}
+					recipient = &models.User{FirstName: name, Email: ticket.AttendeeEmail}
+				}
+				joinInfo := models.JoinInfoForRegistration(h.db, ticket, event)
+				if err := h.emailService.SendRegistrationConfirmation(recipient, event, ticket.TicketType.Name, joinInfo, ticket); err != nil {
+					log.Printf("Failed to send confirmation email to %s: %v", recipient.Email, err)
+				}
+			}
+		}()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Payment processed successfully",
+		"payment":      payment,
+		"order":        order,
+		"notification": notification,
+	})
+}
+
 func (h *PaymentHandler) GetPayments(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -136,7 +321,7 @@ func (h *PaymentHandler) GetPayments(c *gin.Context) {
 		}
 	}
 
-	payments, err := models.FindPaymentsByRegistration(h.db, registration.ID)
+	payments, err := registration.GetPayments(h.db)
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view payments for this registration"})
 		return
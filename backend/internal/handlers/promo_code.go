@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"errors"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxBulkPromoCodes   = 500
+	bulkPromoCodeLength = 8
+)
+
+type PromoCodeHandler struct {
+	db *gorm.DB
+}
+
+func NewPromoCodeHandler() *PromoCodeHandler {
+	return &PromoCodeHandler{
+		db: database.GetDB(),
+	}
+}
+
+// promoCodeSettings are the parts of a promo code shared by codes generated in bulk
+type promoCodeSettings struct {
+	Description    string              `json:"description"`
+	DiscountType   models.DiscountType `json:"discount_type" binding:"required"`
+	DiscountValue  float64             `json:"discount_value" binding:"required"`
+	MaxUses        *int                `json:"max_uses"`
+	MaxUsesPerUser *int                `json:"max_uses_per_user"`
+	MinQuantity    int                 `json:"min_quantity"`
+	StartsAt       *time.Time          `json:"starts_at"`
+	ExpiresAt      *time.Time          `json:"expires_at"`
+	TicketTypeIDs  []uint              `json:"ticket_type_ids"`
+}
+
+// apply checks the settings and copies them onto promo
+func (s *promoCodeSettings) apply(c *gin.Context, db *gorm.DB, event *models.Event, promo *models.PromoCode) bool {
+	promo.EventID = event.ID
+	promo.Description = strings.TrimSpace(s.Description)
+	promo.DiscountType = s.DiscountType
+	promo.DiscountValue = s.DiscountValue
+	promo.MaxUses = s.MaxUses
+	promo.MaxUsesPerUser = s.MaxUsesPerUser
+	promo.MinQuantity = s.MinQuantity
+	if promo.MinQuantity == 0 {
+		promo.MinQuantity = 1
+	}
+	promo.StartsAt = s.StartsAt
+	promo.ExpiresAt = s.ExpiresAt
+	if err := promo.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	promo.TicketTypeIDs = make([]uint, 0, len(s.TicketTypeIDs))
+	for id := range uniqueIDs(s.TicketTypeIDs) {
+		promo.TicketTypeIDs = append(promo.TicketTypeIDs, id)
+	}
+	if len(promo.TicketTypeIDs) > 0 {
+		var count int64
+		db.Model(&models.TicketType{}).Where("event_id = ? AND id IN ?", event.ID, promo.TicketTypeIDs).Count(&count)
+		if int(count) != len(promo.TicketTypeIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All ticket types must belong to this event"})
+			return false
+		}
+	}
+	return true
+}
+
+// savePromoCodeTicketTypes replaces the ticket types the codes are limited to
+func savePromoCodeTicketTypes(tx *gorm.DB, codes []models.PromoCode) error {
+	var links []models.PromoCodeTicketType
+	ids := make([]uint, len(codes))
+	for i, code := range codes {
+		ids[i] = code.ID
+		for _, ticketTypeID := range code.TicketTypeIDs {
+			links = append(links, models.PromoCodeTicketType{PromoCodeID: code.ID, TicketTypeID: ticketTypeID})
+		}
+	}
+	if err := tx.Where("promo_code_id IN ?", ids).Delete(&models.PromoCodeTicketType{}).Error; err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&links, 100).Error
+}
+
+func (h *PromoCodeHandler) CreatePromoCode(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage promo codes for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+		promoCodeSettings
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := models.NormalizeAccessCode(input.Code)
+	if len(code) < 4 || len(code) > 50 || strings.ContainsAny(code, " \t") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code must be 4 to 50 characters without spaces"})
+		return
+	}
+
+	promo := models.PromoCode{Code: code}
+	if !input.apply(c, h.db, event, &promo) {
+		return
+	}
+
+	var existing int64
+	h.db.Model(&models.PromoCode{}).Where("event_id = ? AND code = ?", event.ID, code).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A promo code with this code already exists for this event"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&promo).Error; err != nil {
+			return err
+		}
+		return savePromoCodeTicketTypes(tx, []models.PromoCode{promo})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promo code"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, promo)
+}
+
+// BulkCreatePromoCodes generates a batch of codes with the same settings, e.g. one
+// per customer of a partner, each with a random suffix after the prefix
+func (h *PromoCodeHandler) BulkCreatePromoCodes(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage promo codes for this event")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Prefix string `json:"prefix"`
+		Count  int    `json:"count" binding:"required"`
+		Batch  string `json:"batch" binding:"required"`
+		promoCodeSettings
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Count < 1 || input.Count > maxBulkPromoCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Count must be between 1 and 500"})
+		return
+	}
+	prefix := models.NormalizeAccessCode(input.Prefix)
+	if len(prefix) > 30 || strings.ContainsAny(prefix, " \t") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prefix must be at most 30 characters without spaces"})
+		return
+	}
+	batch := strings.TrimSpace(input.Batch)
+	if len(batch) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch name is too long"})
+		return
+	}
+
+	var template models.PromoCode
+	if !input.apply(c, h.db, event, &template) {
+		return
+	}
+
+	var taken []string
+	h.db.Model(&models.PromoCode{}).Where("event_id = ?", event.ID).Pluck("code", &taken)
+	used := make(map[string]bool, len(taken)+input.Count)
+	for _, code := range taken {
+		used[code] = true
+	}
+
+	codes := make([]models.PromoCode, 0, input.Count)
+	for len(codes) < input.Count {
+		code, err := models.NewPromoCode(prefix, bulkPromoCodeLength)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate promo codes"})
+			return
+		}
+		if used[code] {
+			continue
+		}
+		used[code] = true
+
+		promo := template
+		promo.Code = code
+		promo.Batch = batch
+		codes = append(codes, promo)
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&codes, 100).Error; err != nil {
+			return err
+		}
+		return savePromoCodeTicketTypes(tx, codes)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promo codes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Promo codes created successfully",
+		"batch":   batch,
+		"codes":   codes,
+	})
+}
+
+// ListPromoCodes lists the event's codes with how often they've been used,
+// optionally only those of one batch
+func (h *PromoCodeHandler) ListPromoCodes(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view promo codes for this event")
+	if !ok {
+		return
+	}
+
+	query := h.db.Where("event_id = ?", event.ID)
+	if batch := c.Query("batch"); batch != "" {
+		query = query.Where("batch = ?", batch)
+	}
+
+	var codes []models.PromoCode
+	if err := query.Order("code").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo codes"})
+		return
+	}
+
+	ptrs := make([]*models.PromoCode, len(codes))
+	for i := range codes {
+		ptrs[i] = &codes[i]
+	}
+	if err := models.LoadPromoCodeDetails(h.db, ptrs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+// UpdatePromoCode replaces a code's settings. Registrations that already used
+// it keep the discount they got.
+func (h *PromoCodeHandler) UpdatePromoCode(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage promo codes for this event")
+	if !ok {
+		return
+	}
+
+	promo, ok := h.findPromoCode(c, event)
+	if !ok {
+		return
+	}
+
+	var input promoCodeSettings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !input.apply(c, h.db, event, promo) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(promo).Error; err != nil {
+			return err
+		}
+		return savePromoCodeTicketTypes(tx, []models.PromoCode{*promo})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promo code"})
+		return
+	}
+
+	if err := models.LoadPromoCodeDetails(h.db, []*models.PromoCode{promo}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load promo code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promo)
+}
+
+func (h *PromoCodeHandler) DeletePromoCode(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage promo codes for this event")
+	if !ok {
+		return
+	}
+
+	promo, ok := h.findPromoCode(c, event)
+	if !ok {
+		return
+	}
+
+	// registrations keep their promo_code_id and discount, the code just
+	// can't be used again
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("promo_code_id = ?", promo.ID).Delete(&models.PromoCodeTicketType{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(promo).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promo code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promo code deleted successfully"})
+}
+
+// ValidatePromoCode lets a buyer check a code before checking out and shows
+// what it takes off the ticket type's price
+func (h *PromoCodeHandler) ValidatePromoCode(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var input struct {
+		Code         string `json:"code" binding:"required"`
+		TicketTypeID uint   `json:"ticket_type_id" binding:"required"`
+		Quantity     int    `json:"quantity"`
+		Key          string `json:"key"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, input.Key) {
+		return
+	}
+
+	var ticketType models.TicketType
+	if err := h.db.Where("id = ? AND event_id = ?", input.TicketTypeID, eventID).First(&ticketType).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found for this event"})
+		return
+	}
+
+	promo, err := models.FindPromoCode(h.db, uint(eventID), input.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code"})
+		return
+	}
+
+	if !promo.AppliesTo(ticketType.ID) {
+		err = models.ErrPromoCodeTicketType
+	} else {
+		err = promo.CheckUsable(h.db, userID.(uint), input.Quantity)
+	}
+	if err != nil {
+		if models.IsPromoCodeError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check promo code"})
+		return
+	}
+
+	price, _, err := ticketType.CurrentPrice(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket price"})
+		return
+	}
+
+	discount := promo.DiscountFor(price)
+	c.JSON(http.StatusOK, gin.H{
+		"code":           promo.Code,
+		"description":    promo.Description,
+		"discount_type":  promo.DiscountType,
+		"discount_value": promo.DiscountValue,
+		"price":          price,
+		"discount":       discount,
+		"discounted":     price - discount,
+	})
+}
+
+func (h *PromoCodeHandler) findPromoCode(c *gin.Context, event *models.Event) (*models.PromoCode, bool) {
+	codeID, err := strconv.ParseUint(c.Param("code_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code ID"})
+		return nil, false
+	}
+
+	var promo models.PromoCode
+	if err := h.db.Where("id = ? AND event_id = ?", codeID, event.ID).First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found for this event"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promo code"})
+		}
+		return nil, false
+	}
+	return &promo, true
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"lujke-dunn/314-group-project/backend/internal/database"
 	"lujke-dunn/314-group-project/backend/internal/models"
 	"lujke-dunn/314-group-project/backend/internal/services"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -15,12 +20,14 @@ import (
 type RegistrationHandler struct {
 	db           *gorm.DB
 	emailService *services.EmailService
+	waitlist     *services.Waitlist
 }
 
-func NewRegistrationHandler(emailService *services.EmailService) *RegistrationHandler {
+func NewRegistrationHandler(emailService *services.EmailService, waitlist *services.Waitlist) *RegistrationHandler {
 	return &RegistrationHandler{
 		db:           database.GetDB(),
 		emailService: emailService,
+		waitlist:     waitlist,
 	}
 }
 
@@ -33,7 +40,13 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 
 	var input struct {
 		EventID      uint `json:"event_id" binding:"required"`
-		TicketTypeID uint `json:"ticket_type_id" binding:"required"`
+		TicketTypeID uint  `json:"ticket_type_id" binding:"required"`
+		SeatID       *uint  `json:"seat_id"`
+		Key          string `json:"key"`
+		AccessCode   string `json:"access_code"`
+		PromoCode    string `json:"promo_code"`
+		Answers      []models.AnswerInput `json:"answers"`
+		AddOns       []addOnInput `json:"add_ons" binding:"dive"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -52,12 +65,24 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 		return
 	}
 
+	if !checkEventAccess(c, h.db, &event, input.Key) {
+		return
+	}
+
 	var ticketType models.TicketType
 	if err := h.db.Where("id = ? AND event_id = ?", input.TicketTypeID, input.EventID).First(&ticketType).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found for this event"})
 		return
 	}
 
+	// a retried or double submitted request gets back the registration it
+	// already made instead of taking another ticket. It's checked again in
+	// the transaction below for requests that arrive together.
+	if existing, err := models.FindPendingRegistration(h.db, userID.(uint), ticketType.ID); err == nil {
+		pendingRegistrationResponse(c, existing)
+		return
+	}
+
 	// check if tickets are available and on sale
 	availableQuantity, err := ticketType.GetAvailableQuantity(h.db)
 	if err != nil {
@@ -66,7 +91,7 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 	}
 
 	if availableQuantity <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No tickets available"})
+		c.JSON(http.StatusConflict, gin.H{"error": "No tickets available", "waitlist": !ticketType.IsHidden})
 		return
 	}
 
@@ -75,15 +100,169 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 		return
 	}
 
-	registration, err := models.CreateRegistration(h.db, userID.(uint), input.EventID, input.TicketTypeID)
+	var accessCode *models.AccessCode
+	if ticketType.IsHidden {
+		if input.AccessCode != "" {
+			accessCode, _ = models.FindAccessCode(h.db, event.ID, input.AccessCode)
+		}
+		if accessCode == nil || !accessCode.Unlocks(ticketType.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "A valid access code is required for this ticket type"})
+			return
+		}
+	}
+
+	var promo *models.PromoCode
+	if input.PromoCode != "" {
+		promo, err = models.FindPromoCode(h.db, event.ID, input.PromoCode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code"})
+			return
+		}
+		if !promo.AppliesTo(ticketType.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrPromoCodeTicketType.Error()})
+			return
+		}
+	}
+
+	seated, err := models.IsSeatedTicketType(h.db, ticketType.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket availability"})
+		return
+	}
+
+	if seated && input.SeatID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrSeatRequired.Error()})
+		return
+	}
+
+	if !seated && input.SeatID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This ticket type doesn't have reserved seating"})
+		return
+	}
+
+	questions, err := models.FindQuestionsForTicketType(h.db, event.ID, ticketType.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load registration questions"})
+		return
+	}
+
+	answers, err := models.CheckAnswers(questions, input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addOns, err := findAddOns(h.db, event.ID, input.AddOns)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, line := range addOns {
+		if !line.AddOn.AppliesTo(ticketType.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s can't be added to this ticket", line.AddOn.Name)})
+			return
+		}
+	}
+
+	// the registration and its seat are created together so a taken seat leaves nothing behind
+	var registration, existing *models.Registration
+	var seat *models.SeatAssignment
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if existing, err = models.FindPendingRegistration(tx, userID.(uint), ticketType.ID); err == nil {
+			return nil
+		}
+		existing = nil
+		purchase := models.Purchase{UserID: userID.(uint), Quantities: map[uint]int{ticketType.ID: 1}}
+		if err := models.CheckPurchaseLimits(tx, &event, purchase); err != nil {
+			return err
+		}
+		if accessCode != nil {
+			if err := accessCode.CheckUsable(tx); err != nil {
+				return err
+			}
+		}
+		if promo != nil {
+			if err := promo.CheckUsable(tx, userID.(uint), 1); err != nil {
+				return err
+			}
+		}
+		registration, err = models.CreateRegistration(tx, userID.(uint), input.EventID, input.TicketTypeID)
+		if err != nil {
+			return err
+		}
+		if promo != nil {
+			if err := registration.ApplyPromoCode(tx, promo); err != nil {
+				return err
+			}
+		}
+		if err := registration.AddAddOns(tx, addOns); err != nil {
+			return err
+		}
+		if accessCode != nil {
+			registration.AccessCodeID = &accessCode.ID
+			if err := tx.Model(registration).Update("access_code_id", accessCode.ID).Error; err != nil {
+				return err
+			}
+		}
+		if len(answers) > 0 {
+			for i := range answers {
+				answers[i].RegistrationID = registration.ID
+			}
+			if err := tx.Create(&answers).Error; err != nil {
+				return err
+			}
+		}
+		if seated {
+			seat, err = models.AssignSeat(tx, registration, *input.SeatID)
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrAccessCodeExpired) || errors.Is(err, models.ErrAccessCodeUsedUp) || models.IsPromoCodeError(err) || models.IsPurchaseLimitError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// someone else bought the last ticket since the check above
+		if errors.Is(err, models.ErrSoldOut) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No tickets available", "waitlist": !ticketType.IsHidden})
+			return
+		}
+		if errors.Is(err, models.ErrAddOnSoldOut) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if seated && registration != nil {
+			seatError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create registration"})
 		return
 	}
+	if existing != nil {
+		pendingRegistrationResponse(c, existing)
+		return
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message":      "Registration created successfully",
 		"registration": registration,
+		"breakdown":    registration.Breakdown(),
+		"answers":      answers,
+	}
+	if seat != nil {
+		response["seat"] = seat.Seat
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func pendingRegistrationResponse(c *gin.Context, existing *models.Registration) {
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "You already have a pending registration for this ticket",
+		"registration": existing,
+		"breakdown":    existing.Breakdown(),
+		"duplicate":    true,
 	})
 }
 
@@ -114,35 +293,170 @@ func (h *RegistrationHandler) GetEventRegistrations(c *gin.Context) {
 		}
 	}
 
-	var rawRegistrations []models.Registration
-	if err := h.db.Preload("User", func(db *gorm.DB) *gorm.DB {
+	query := h.db.Model(&models.Registration{}).Preload("User", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, first_name, last_name, email")
-	}).Preload("TicketType").Where("event_id = ?", eventID).Find(&rawRegistrations).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registrations"})
+	}).Preload("TicketType").Where("event_id = ?", eventID)
+
+	rawRegistrations, page, err := paginate[models.Registration](c, query, "registrations", sortKey{Name: "created", Expr: "registrations.created_at", Desc: true}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch registrations")
+		return
+	}
+
+	registrationIDs := make([]uint, 0, len(rawRegistrations))
+	for _, reg := range rawRegistrations {
+		registrationIDs = append(registrationIDs, reg.ID)
+	}
+	answers, err := models.FindAnswersByRegistrations(h.db, registrationIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration answers"})
+		return
+	}
+	addOns, err := models.FindAddOnsByRegistrations(h.db, registrationIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration add-ons"})
 		return
 	}
 
 	type RegistrationWithDetails struct {
 		models.Registration
-		User       models.User       `json:"user"`
-		TicketType models.TicketType `json:"ticket_type"`
+		User       models.User                 `json:"user"`
+		TicketType models.TicketType           `json:"ticket_type"`
+		Answers    []models.RegistrationAnswer `json:"answers"`
 	}
 
-	var registrations []RegistrationWithDetails
+	registrations := make([]RegistrationWithDetails, 0, len(rawRegistrations))
 	for _, reg := range rawRegistrations {
+		regAnswers := answers[reg.ID]
+		if regAnswers == nil {
+			regAnswers = []models.RegistrationAnswer{}
+		}
+		reg.AddOns = addOns[reg.ID]
 		registrations = append(registrations, RegistrationWithDetails{
 			Registration: reg,
 			User:         reg.User,
 			TicketType:   reg.TicketType,
+			Answers:      regAnswers,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"registrations": registrations,
-		"total":         len(rawRegistrations),
+		"pagination":    page,
 	})
 }
 
+// ExportEventRegistrations downloads the event's registrations as CSV with a
+// column for each registration question
+func (h *RegistrationHandler) ExportEventRegistrations(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if event.UserID != userID.(uint) {
+		isAdmin, exists := c.Get("isAdmin")
+		if !exists || !isAdmin.(bool) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to export registrations for this event"})
+			return
+		}
+	}
+
+	query := h.db.Preload("User").Preload("TicketType").Where("event_id = ?", event.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var registrations []models.Registration
+	if err := query.Order("created_at").Find(&registrations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registrations"})
+		return
+	}
+
+	// questions that were deleted still get a column if someone answered them
+	var questions []models.RegistrationQuestion
+	h.db.Unscoped().Where("event_id = ? AND (deleted_at IS NULL OR id IN (?))", event.ID,
+		h.db.Model(&models.RegistrationAnswer{}).Select("question_id")).
+		Order("position, id").Find(&questions)
+
+	registrationIDs := make([]uint, 0, len(registrations))
+	for _, reg := range registrations {
+		registrationIDs = append(registrationIDs, reg.ID)
+	}
+	answers, err := models.FindAnswersByRegistrations(h.db, registrationIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration answers"})
+		return
+	}
+	addOns, err := models.FindAddOnsByRegistrations(h.db, registrationIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration add-ons"})
+		return
+	}
+
+	header := []string{"registration_id", "status", "registered_at", "first_name", "last_name", "email", "ticket_type", "price_tier", "total_price", "add_ons", "add_on_amount", "currency"}
+	for _, q := range questions {
+		header = append(header, csvSafe(q.Label))
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"event-%d-registrations.csv\"", event.ID))
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(header)
+	for _, reg := range registrations {
+		byQuestion := make(map[uint]string, len(answers[reg.ID]))
+		for _, answer := range answers[reg.ID] {
+			byQuestion[answer.QuestionID] = answer.Text()
+		}
+		var bought []string
+		for _, item := range addOns[reg.ID] {
+			bought = append(bought, fmt.Sprintf("%s x%d", item.Name, item.Quantity))
+		}
+
+		row := []string{
+			strconv.FormatUint(uint64(reg.ID), 10),
+			string(reg.Status),
+			reg.CreatedAt.Format(time.RFC3339),
+			csvSafe(reg.User.FirstName),
+			csvSafe(reg.User.LastName),
+			csvSafe(reg.User.Email),
+			csvSafe(reg.TicketType.Name),
+			csvSafe(reg.PriceTierName),
+			reg.TotalPrice.String(),
+			csvSafe(strings.Join(bought, "; ")),
+			reg.AddOnAmount.String(),
+			reg.Currency,
+		}
+		for _, q := range questions {
+			row = append(row, csvSafe(byQuestion[q.ID]))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+}
+
+// csvSafe stops spreadsheet apps treating attendee text as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
 func (h *RegistrationHandler) UpdateRegistrationStatus(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -187,10 +501,18 @@ func (h *RegistrationHandler) UpdateRegistrationStatus(c *gin.Context) {
 	originalStatus := registration.Status
 	registration.Status = models.RegistrationStatus(input.Status)
 	if err := h.db.Save(&registration).Error; err != nil {
+		if errors.Is(err, models.ErrSoldOut) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No tickets left to restore this registration"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update registration"})
 		return
 	}
 
+	if originalStatus != models.RegistrationStatusCanceled && registration.Status == models.RegistrationStatusCanceled {
+		h.waitlist.Promote(registration.TicketTypeID)
+	}
+
 	// Send email notification based on status change
 	if h.emailService != nil {
 		go func() {
@@ -210,7 +532,7 @@ func (h *RegistrationHandler) UpdateRegistrationStatus(c *gin.Context) {
 						}
 						// Send confirmation email if status changed to confirmed
 						if originalStatus != models.RegistrationStatusConfirmed && registration.Status == models.RegistrationStatusConfirmed {
-							if err := h.emailService.SendRegistrationConfirmation(&user, event.Title, ticketType.Name); err != nil {
+							if err := h.emailService.SendRegistrationConfirmation(&user, &event, ticketType.Name, models.JoinInfoForRegistration(h.db, &registration, &event), &registration); err != nil {
 								log.Printf("Failed to send confirmation email: %v", err)
 							}
 						}
@@ -230,13 +552,15 @@ func (h *RegistrationHandler) GetUserRegistrations(c *gin.Context) {
 		return
 	}
 
-	registrations, err := models.FindRegistrationsByUser(h.db, userID.(uint))
+	query := h.db.Model(&models.Registration{}).Where("user_id = ?", userID)
+
+	registrations, page, err := paginate[models.Registration](c, query, "registrations", sortKey{Name: "created", Expr: "registrations.created_at", Desc: true}, defaultPageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch registration"})
+		paginationError(c, err, "Failed to fetch registration")
 		return
 	}
 
-	var enhancedRegistrations []gin.H
+	enhancedRegistrations := make([]gin.H, 0, len(registrations))
 	for _, reg := range registrations {
 		var event models.Event
 		var ticketType models.TicketType
@@ -245,19 +569,29 @@ func (h *RegistrationHandler) GetUserRegistrations(c *gin.Context) {
 		h.db.First(&ticketType, reg.TicketTypeID)
 
 		enhancedRegistrations = append(enhancedRegistrations, gin.H{
-			"id":             reg.ID,
-			"event_id":       reg.EventID,
-			"ticket_type_id": reg.TicketTypeID,
-			"status":         reg.Status,
-			"total_price":    reg.TotalPrice,
-			"created_at":     reg.CreatedAt,
-			"event_title":    event.Title,
-			"ticket_name":    ticketType.Name,
+			"id":              reg.ID,
+			"event_id":        reg.EventID,
+			"ticket_type_id":  reg.TicketTypeID,
+			"status":          reg.Status,
+			"total_price":     reg.TotalPrice,
+			"discount_amount": reg.DiscountAmount,
+			"add_on_amount":   reg.AddOnAmount,
+			"amount_due":      reg.AmountDue,
+			"currency":        reg.Currency,
+			"created_at":      reg.CreatedAt,
+			"event_title":     event.Title,
+			"ticket_name":     ticketType.Name,
+			"order_id":        reg.OrderID,
+			"bundle_id":       reg.BundleID,
+			"attendee_name":   reg.AttendeeName,
+			"expires_at":      reg.ExpiresAt,
 		})
 	}
 
-	c.JSON(http.StatusOK, enhancedRegistrations)
-	return
+	c.JSON(http.StatusOK, gin.H{
+		"registrations": enhancedRegistrations,
+		"pagination":    page,
+	})
 }
 
 func (h *RegistrationHandler) GetRegistrationDetails(c *gin.Context) {
@@ -294,6 +628,15 @@ func (h *RegistrationHandler) GetRegistrationDetails(c *gin.Context) {
 
 	payments, _ := registration.GetPayments(h.db)
 
+	answers, _ := models.FindAnswersByRegistrations(h.db, []uint{registration.ID})
+	addOns, _ := models.FindAddOnsByRegistrations(h.db, []uint{registration.ID})
+	registration.AddOns = addOns[registration.ID]
+
+	var seat *models.Seat
+	if assignment, err := models.FindSeatAssignment(h.db, registration.ID); err == nil {
+		seat = &assignment.Seat
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"registration": registration,
 		"event": gin.H{
@@ -313,7 +656,10 @@ func (h *RegistrationHandler) GetRegistrationDetails(c *gin.Context) {
 			"price":  ticketType.Price,
 			"is_vip": ticketType.IsVIP,
 		},
-		"payments": payments,
+		"seat":      seat,
+		"join_info": models.JoinInfoForRegistration(h.db, registration, &event),
+		"answers":   answers[registration.ID],
+		"payments":  payments,
 	})
 }
 
@@ -357,6 +703,7 @@ func (h *RegistrationHandler) CancelRegistration(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel registration"})
 		return
 	}
+	h.waitlist.Promote(registration.TicketTypeID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":      "Registration canceled successfully",
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RegistrationQuestionHandler struct {
+	db *gorm.DB
+}
+
+func NewRegistrationQuestionHandler() *RegistrationQuestionHandler {
+	return &RegistrationQuestionHandler{
+		db: database.GetDB(),
+	}
+}
+
+// GetQuestions lists the questions asked when registering. With
+// ?ticket_type_id= only the ones asked for that ticket type are returned.
+func (h *RegistrationQuestionHandler) GetQuestions(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return
+	}
+
+	var questions []models.RegistrationQuestion
+	if raw := c.Query("ticket_type_id"); raw != "" {
+		ticketTypeID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+			return
+		}
+		questions, err = models.FindQuestionsForTicketType(h.db, event.ID, uint(ticketTypeID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch questions"})
+			return
+		}
+	} else if err := h.db.Where("event_id = ?", event.ID).Order("position, id").Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch questions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, questions)
+}
+
+type questionInput struct {
+	TicketTypeID *uint    `json:"ticket_type_id"`
+	Label        *string  `json:"label"`
+	HelpText     *string  `json:"help_text"`
+	Type         *string  `json:"type"`
+	Options      []string `json:"options"`
+	Required     *bool    `json:"required"`
+	MinLength    *int     `json:"min_length"`
+	MaxLength    *int     `json:"max_length"`
+	Pattern      *string  `json:"pattern"`
+	Position     *int     `json:"position"`
+}
+
+// apply copies the fields that were sent onto the question
+func (input *questionInput) apply(q *models.RegistrationQuestion) {
+	if input.Label != nil {
+		q.Label = strings.TrimSpace(*input.Label)
+	}
+	if input.HelpText != nil {
+		q.HelpText = *input.HelpText
+	}
+	if input.Type != nil {
+		q.Type = models.QuestionType(*input.Type)
+	}
+	if input.Options != nil {
+		q.Options = make([]string, 0, len(input.Options))
+		for _, option := range input.Options {
+			q.Options = append(q.Options, strings.TrimSpace(option))
+		}
+	}
+	if input.Required != nil {
+		q.Required = *input.Required
+	}
+	if input.MinLength != nil {
+		q.MinLength = *input.MinLength
+	}
+	if input.MaxLength != nil {
+		q.MaxLength = *input.MaxLength
+	}
+	if input.Pattern != nil {
+		q.Pattern = *input.Pattern
+	}
+	if input.Position != nil {
+		q.Position = *input.Position
+	}
+}
+
+func (h *RegistrationQuestionHandler) CreateQuestion(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage questions for this event")
+	if !ok {
+		return
+	}
+
+	var input questionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Label == nil || strings.TrimSpace(*input.Label) == "" || input.Type == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label and type are required"})
+		return
+	}
+
+	question := models.RegistrationQuestion{EventID: event.ID}
+	if input.Position == nil {
+		var count int64
+		h.db.Model(&models.RegistrationQuestion{}).Where("event_id = ?", event.ID).Count(&count)
+		question.Position = int(count)
+	}
+	input.apply(&question)
+
+	if !h.setTicketType(c, &question, input.TicketTypeID) {
+		return
+	}
+
+	question.FillDefaultOptions()
+	if err := question.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Create(&question).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create question"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, question)
+}
+
+func (h *RegistrationQuestionHandler) UpdateQuestion(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage questions for this event")
+	if !ok {
+		return
+	}
+
+	question, ok := h.findQuestion(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var input questionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// answers already given would no longer make sense
+	if input.Type != nil && models.QuestionType(*input.Type) != question.Type {
+		var answered int64
+		h.db.Model(&models.RegistrationAnswer{}).Where("question_id = ?", question.ID).Count(&answered)
+		if answered > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "This question has already been answered, its type can't be changed"})
+			return
+		}
+	}
+
+	input.apply(question)
+	if question.Label == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label can't be empty"})
+		return
+	}
+
+	if input.TicketTypeID != nil {
+		if !h.setTicketType(c, question, input.TicketTypeID) {
+			return
+		}
+	}
+
+	question.FillDefaultOptions()
+	if err := question.CheckDefinition(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Save(question).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update question"})
+		return
+	}
+
+	c.JSON(http.StatusOK, question)
+}
+
+// DeleteQuestion stops the question being asked. Answers already given are
+// kept so they still show up on existing registrations.
+func (h *RegistrationQuestionHandler) DeleteQuestion(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage questions for this event")
+	if !ok {
+		return
+	}
+
+	question, ok := h.findQuestion(c, event.ID)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(question).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete question"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Question deleted successfully"})
+}
+
+func (h *RegistrationQuestionHandler) findQuestion(c *gin.Context, eventID uint) (*models.RegistrationQuestion, bool) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return nil, false
+	}
+
+	var question models.RegistrationQuestion
+	if err := h.db.Where("id = ? AND event_id = ?", questionID, eventID).First(&question).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found for this event"})
+		return nil, false
+	}
+
+	return &question, true
+}
+
+// setTicketType limits the question to one ticket type of its event. A zero
+// id asks the question for every ticket type again.
+func (h *RegistrationQuestionHandler) setTicketType(c *gin.Context, q *models.RegistrationQuestion, ticketTypeID *uint) bool {
+	if ticketTypeID == nil || *ticketTypeID == 0 {
+		q.TicketTypeID = nil
+		return true
+	}
+
+	var count int64
+	h.db.Model(&models.TicketType{}).Where("id = ? AND event_id = ?", *ticketTypeID, q.EventID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ticket type not found for this event"})
+		return false
+	}
+
+	q.TicketTypeID = ticketTypeID
+	return true
+}
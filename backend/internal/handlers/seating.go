@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxSeatsPerRow = 200
+
+var errSeatsAlreadySold = errors.New("Seats have already been sold for this event, the seat map can no longer be changed")
+
+// seatMapRejected is a seat map that would leave its ticket types with fewer
+// tickets than are sold or more than the venue holds
+type seatMapRejected struct {
+	message string
+}
+
+func (e *seatMapRejected) Error() string {
+	return e.message
+}
+
+type SeatingHandler struct {
+	db *gorm.DB
+}
+
+func NewSeatingHandler() *SeatingHandler {
+	return &SeatingHandler{
+		db: database.GetDB(),
+	}
+}
+
+type seatRowInput struct {
+	Label       string `json:"label" binding:"required"`
+	Seats       int    `json:"seats" binding:"required"`
+	StartNumber int    `json:"start_number"`
+	PriceZone   string `json:"price_zone" binding:"required"`
+	Accessible  []int  `json:"accessible"`
+	Blocked     []int  `json:"blocked"`
+}
+
+type seatMapInput struct {
+	Name       string `json:"name"`
+	PriceZones []struct {
+		Name         string `json:"name" binding:"required"`
+		Color        string `json:"color"`
+		TicketTypeID uint   `json:"ticket_type_id" binding:"required"`
+	} `json:"price_zones" binding:"required,min=1,dive"`
+	Sections []struct {
+		Name string         `json:"name" binding:"required"`
+		Rows []seatRowInput `json:"rows" binding:"required,min=1,dive"`
+	} `json:"sections" binding:"required,min=1,dive"`
+}
+
+// SaveSeatMap creates or replaces the event's seat map. Ticket types used by a
+// price zone get their quantity set to the number of sellable seats in it.
+func (h *SeatingHandler) SaveSeatMap(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the seat map for this event")
+	if !ok {
+		return
+	}
+
+	var input seatMapInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ticketTypeIDs []uint
+	h.db.Model(&models.TicketType{}).Where("event_id = ?", event.ID).Pluck("id", &ticketTypeIDs)
+	eventTicketTypes := make(map[uint]bool, len(ticketTypeIDs))
+	for _, id := range ticketTypeIDs {
+		eventTicketTypes[id] = true
+	}
+
+	seatMap := models.SeatMap{EventID: event.ID, Name: input.Name}
+	zoneIndex := make(map[string]int, len(input.PriceZones))
+	for i, z := range input.PriceZones {
+		if _, dup := zoneIndex[z.Name]; dup {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Price zone '%s' is listed twice", z.Name)})
+			return
+		}
+		if !eventTicketTypes[z.TicketTypeID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Price zone '%s' uses a ticket type that doesn't belong to this event", z.Name)})
+			return
+		}
+		// attendees who already hold these tickets would have no seat
+		var sold int64
+		h.db.Model(&models.Registration{}).Where("ticket_type_id = ? AND status != ?", z.TicketTypeID, models.RegistrationStatusCanceled).Count(&sold)
+		if sold > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Price zone '%s' uses a ticket type that has already been sold without seats", z.Name)})
+			return
+		}
+		zoneIndex[z.Name] = i
+		seatMap.PriceZones = append(seatMap.PriceZones, models.PriceZone{Name: z.Name, Color: z.Color, TicketTypeID: z.TicketTypeID})
+	}
+
+	// seats are built per section and linked to their zone once the zones have ids
+	type pendingSeat struct {
+		seat models.Seat
+		zone int
+	}
+	seatsBySection := make([][]pendingSeat, len(input.Sections))
+	sectionNames := make(map[string]bool, len(input.Sections))
+
+	for i, section := range input.Sections {
+		if sectionNames[section.Name] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Section '%s' is listed twice", section.Name)})
+			return
+		}
+		sectionNames[section.Name] = true
+		seatMap.Sections = append(seatMap.Sections, models.SeatSection{Name: section.Name, Position: i})
+
+		rowLabels := make(map[string]bool, len(section.Rows))
+		for y, row := range section.Rows {
+			label := strings.TrimSpace(row.Label)
+			if label == "" || rowLabels[label] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Rows in section '%s' need unique labels", section.Name)})
+				return
+			}
+			rowLabels[label] = true
+
+			zone, ok := zoneIndex[row.PriceZone]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Row %s in section '%s' uses unknown price zone '%s'", label, section.Name, row.PriceZone)})
+				return
+			}
+			if row.Seats < 1 || row.Seats > maxSeatsPerRow {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A row must have between 1 and %d seats", maxSeatsPerRow)})
+				return
+			}
+
+			start := row.StartNumber
+			if start == 0 {
+				start = 1
+			}
+			accessible := intSet(row.Accessible)
+			blocked := intSet(row.Blocked)
+
+			for x := 0; x < row.Seats; x++ {
+				number := start + x
+				seatsBySection[i] = append(seatsBySection[i], pendingSeat{
+					seat: models.Seat{
+						Row:        label,
+						Number:     number,
+						Label:      fmt.Sprintf("%s%d", label, number),
+						X:          x,
+						Y:          y,
+						Accessible: accessible[number],
+						Blocked:    blocked[number],
+					},
+					zone: zone,
+				})
+			}
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var sold int64
+		tx.Model(&models.SeatAssignment{}).Where("event_id = ?", event.ID).Count(&sold)
+		if sold > 0 {
+			return errSeatsAlreadySold
+		}
+
+		if existing, err := models.FindSeatMapByEvent(tx, event.ID); err == nil {
+			if err := models.DeleteSeatMap(tx, existing); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&seatMap).Error; err != nil {
+			return err
+		}
+
+		var seats []models.Seat
+		perTicketType := make(map[uint]int)
+		for i, section := range seatMap.Sections {
+			for _, p := range seatsBySection[i] {
+				zone := seatMap.PriceZones[p.zone]
+				p.seat.SeatMapID = seatMap.ID
+				p.seat.SectionID = section.ID
+				p.seat.PriceZoneID = zone.ID
+				seats = append(seats, p.seat)
+				if !p.seat.Blocked {
+					perTicketType[zone.TicketTypeID]++
+				}
+			}
+		}
+		if err := tx.CreateInBatches(&seats, 500).Error; err != nil {
+			return err
+		}
+
+		for ticketTypeID := range zoneTicketTypes(seatMap.PriceZones) {
+			var ticketType models.TicketType
+			if err := tx.First(&ticketType, ticketTypeID).Error; err != nil {
+				return err
+			}
+			// tickets sold before the event had a seat map still need seats
+			if perTicketType[ticketTypeID] < ticketType.QuantitySold {
+				return &seatMapRejected{fmt.Sprintf("%d '%s' tickets have already been sold, the seat map only has %d seats for them",
+					ticketType.QuantitySold, ticketType.Name, perTicketType[ticketTypeID])}
+			}
+			err := tx.Model(&models.TicketType{}).Where("id = ?", ticketTypeID).
+				Update("quantity_available", perTicketType[ticketTypeID]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		msg, err := checkTicketCapacity(tx, event, 0, 0)
+		if err != nil {
+			return err
+		}
+		if msg != "" {
+			return &seatMapRejected{msg}
+		}
+		return nil
+	})
+	if errors.Is(err, errSeatsAlreadySold) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	var rejected *seatMapRejected
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": rejected.message})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save seat map"})
+		return
+	}
+
+	h.respondWithSeatMap(c, http.StatusOK, event.ID)
+}
+
+func (h *SeatingHandler) GetSeatMap(c *gin.Context) {
+	event, ok := h.findVisibleEvent(c)
+	if !ok {
+		return
+	}
+	h.respondWithSeatMap(c, http.StatusOK, event.ID)
+}
+
+// GetSeatAvailability is a light version of GetSeatMap for polling while a
+// buyer picks a seat
+func (h *SeatingHandler) GetSeatAvailability(c *gin.Context) {
+	event, ok := h.findVisibleEvent(c)
+	if !ok {
+		return
+	}
+
+	seatMap, err := models.FindSeatMapByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event has no seat map"})
+		return
+	}
+
+	available, unavailable, err := seatMap.SeatAvailability(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat availability"})
+		return
+	}
+
+	zones := make([]gin.H, 0, len(seatMap.PriceZones))
+	for _, zone := range seatMap.PriceZones {
+		zones = append(zones, gin.H{
+			"price_zone_id":  zone.ID,
+			"name":           zone.Name,
+			"ticket_type_id": zone.TicketTypeID,
+			"available":      available[zone.ID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event_id":             event.ID,
+		"price_zones":          zones,
+		"unavailable_seat_ids": unavailable,
+	})
+}
+
+func (h *SeatingHandler) DeleteSeatMap(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the seat map for this event")
+	if !ok {
+		return
+	}
+
+	seatMap, err := models.FindSeatMapByEvent(h.db, event.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event has no seat map"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var sold int64
+		tx.Model(&models.SeatAssignment{}).Where("event_id = ?", event.ID).Count(&sold)
+		if sold > 0 {
+			return errSeatsAlreadySold
+		}
+		return models.DeleteSeatMap(tx, seatMap)
+	})
+	if errors.Is(err, errSeatsAlreadySold) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete seat map"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Seat map deleted successfully"})
+}
+
+func (h *SeatingHandler) respondWithSeatMap(c *gin.Context, status int, eventID uint) {
+	seatMap, err := models.FindSeatMapByEvent(h.db, eventID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event has no seat map"})
+		return
+	}
+
+	if err := seatMap.LoadSeatStatus(h.db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seats"})
+		return
+	}
+
+	c.JSON(status, seatMap)
+}
+
+// findVisibleEvent loads the event named by the :id param if the current user may see it
+func (h *SeatingHandler) findVisibleEvent(c *gin.Context) (*models.Event, bool) {
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil, false
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return nil, false
+	}
+
+	return &event, true
+}
+
+func intSet(values []int) map[int]bool {
+	set := make(map[int]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func zoneTicketTypes(zones []models.PriceZone) map[uint]bool {
+	ids := make(map[uint]bool, len(zones))
+	for _, zone := range zones {
+		ids[zone.TicketTypeID] = true
+	}
+	return ids
+}
+
+// seatError writes the response for an error from models.AssignSeat
+func seatError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrSeatTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "That seat has just been taken, please pick another"})
+	case errors.Is(err, models.ErrSeatUnavailable), errors.Is(err, models.ErrSeatZoneMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve seat"})
+	}
+}
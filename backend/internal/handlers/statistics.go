@@ -44,8 +44,29 @@ func (h *StatisticsHandler) GetSystemStats(c *gin.Context) {
 	var confirmedRegistrations int64
 	h.db.Model(&models.Registration{}).Where("status = ?", models.RegistrationStatusConfirmed).Count(&confirmedRegistrations)
 
-	var totalRevenue float64
-	h.db.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusCompleted).Select("COALESCE(SUM(amount), 0)").Scan(&totalRevenue)
+	type RevenueByCurrency struct {
+		Currency string       `json:"currency"`
+		Payments int64        `json:"payments"`
+		Total    models.Money `json:"total"`
+	}
+
+	// amounts in different currencies can't be added together
+	revenue := []RevenueByCurrency{}
+	h.db.Model(&models.Payment{}).
+		Select("currency, COUNT(*) as payments, COALESCE(SUM(amount), 0) as total").
+		Where("status = ?", models.PaymentStatusCompleted).
+		Group("currency").
+		Order("total DESC").
+		Scan(&revenue)
+
+	// total_revenue predates other currencies, it's kept for older clients
+	// and only counts the default currency
+	var totalRevenue models.Money
+	for _, r := range revenue {
+		if r.Currency == models.DefaultCurrency {
+			totalRevenue = r.Total
+		}
+	}
 
 	var newUsers int64
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
@@ -61,6 +82,7 @@ func (h *StatisticsHandler) GetSystemStats(c *gin.Context) {
 		"total_registrations":     totalRegistrations,
 		"confirmed_registrations": confirmedRegistrations,
 		"total_revenue":           totalRevenue,
+		"revenue":                 revenue,
 		"new_users_last_30_days":  newUsers,
 		"new_events_last_30_days": newEvents,
 	})
@@ -100,26 +122,29 @@ func (h *StatisticsHandler) GetEventStats(c *gin.Context) {
 	h.db.Model(&models.Registration{}).Where("event_id = ? AND status = ?", eventID, models.RegistrationStatusCanceled).Count(&cancelledCount)
 
 	type TicketSalesByType struct {
-		TicketTypeID uint    `json:"ticket_type_id"`
-		Name         string  `json:"name"`
-		Sold         int64   `json:"sold"`
-		Available    int     `json:"available"`
-		Revenue      float64 `json:"revenue"`
+		TicketTypeID uint         `json:"ticket_type_id"`
+		Name         string       `json:"name"`
+		Sold         int64        `json:"sold"`
+		Available    int          `json:"available"`
+		Revenue      models.Money `json:"revenue"`
+		CheckedIn    int64        `json:"checked_in"`
 	}
 
 	var ticketSales []TicketSalesByType
 
 	h.db.Table("ticket_types").
-		Select("ticket_types.id as ticket_type_id, ticket_types.name, COUNT(registrations.id) as available, COALESCE(SUM(registrations.total_price), 0) as revenue").
+		Select("ticket_types.id as ticket_type_id, ticket_types.name, COUNT(registrations.id) as available, COALESCE(SUM(registrations.total_price), 0) as revenue, COUNT(check_ins.id) as checked_in").
 		Joins("LEFT JOIN registrations ON ticket_types.id = registrations.ticket_type_id AND registrations.status != 'canceled'").
+		// a ticket has at most one check-in that hasn't been undone
+		Joins("LEFT JOIN check_ins ON check_ins.registration_id = registrations.id AND check_ins.undone_at IS NULL AND check_ins.deleted_at IS NULL").
 		Where("ticket_types.event_id = ?", eventID).
 		Group("ticket_types.id").
 		Scan(&ticketSales)
 
-	var totalRevenue float64
+	var totalRevenue models.Money
 	h.db.Model(&models.Registration{}).
 		Where("event_id = ? AND status = ?", eventID, models.RegistrationStatusConfirmed).
-		Select("COALESCE(SUM(total_price), 0)").
+		Select("COALESCE(SUM(total_price + add_on_amount), 0)").
 		Scan(&totalRevenue)
 
 	var feedbackCount int64
@@ -140,6 +165,98 @@ func (h *StatisticsHandler) GetEventStats(c *gin.Context) {
 		ratingDistribution = append(ratingDistribution, RatingDistribution{Rating: i, Count: count})
 	}
 
+	type PriceTierSales struct {
+		TicketTypeID uint         `json:"ticket_type_id"`
+		Tier         string       `json:"tier"`
+		Sold         int64        `json:"sold"`
+		Revenue      models.Money `json:"revenue"`
+	}
+
+	// tickets sold at the ticket type's own price have an empty tier
+	var priceTierSales []PriceTierSales
+	h.db.Table("registrations").
+		Select("ticket_type_id, COALESCE(price_tier_name, '') as tier, COUNT(*) as sold, COALESCE(SUM(total_price), 0) as revenue").
+		Where("event_id = ? AND status != ?", eventID, models.RegistrationStatusCanceled).
+		Group("ticket_type_id, COALESCE(price_tier_name, '')").
+		Order("ticket_type_id, MIN(created_at)").
+		Scan(&priceTierSales)
+
+	type PromoCodeUsage struct {
+		PromoCodeID   uint         `json:"promo_code_id"`
+		Code          string       `json:"code"`
+		Batch         string       `json:"batch,omitempty"`
+		Uses          int64        `json:"uses"`
+		DiscountGiven models.Money `json:"discount_given"`
+		Revenue       models.Money `json:"revenue"`
+	}
+
+	// codes that haven't been used yet are left out so a large partner batch
+	// doesn't swamp the stats, deleted codes still count with an empty code
+	var promoCodeUsage []PromoCodeUsage
+	h.db.Table("registrations").
+		Select("registrations.promo_code_id, COALESCE(promo_codes.code, '') as code, COALESCE(promo_codes.batch, '') as batch, COUNT(registrations.id) as uses, COALESCE(SUM(registrations.discount_amount), 0) as discount_given, COALESCE(SUM(registrations.total_price), 0) as revenue").
+		Joins("LEFT JOIN promo_codes ON promo_codes.id = registrations.promo_code_id").
+		Where("registrations.event_id = ? AND registrations.promo_code_id IS NOT NULL AND registrations.status != ?", eventID, models.RegistrationStatusCanceled).
+		Group("registrations.promo_code_id").
+		Order("uses DESC").
+		Scan(&promoCodeUsage)
+
+	var totalDiscount models.Money
+	for _, usage := range promoCodeUsage {
+		totalDiscount += usage.DiscountGiven
+	}
+
+	type AddOnSales struct {
+		AddOnID           uint         `json:"add_on_id"`
+		Name              string       `json:"name"`
+		Sold              int64        `json:"sold"`
+		QuantityAvailable *int         `json:"quantity_available"`
+		Revenue           models.Money `json:"revenue"`
+	}
+
+	// deleted add-ons are only listed if tickets still have them
+	var addOnSales []AddOnSales
+	h.db.Table("add_ons").
+		Select("add_ons.id as add_on_id, add_ons.name, add_ons.quantity_available, COALESCE(SUM(registration_add_ons.quantity), 0) as sold, COALESCE(SUM(registration_add_ons.total), 0) as revenue").
+		Joins("LEFT JOIN registration_add_ons ON registration_add_ons.add_on_id = add_ons.id AND registration_add_ons.deleted_at IS NULL AND registration_add_ons.registration_id IN (?)",
+			h.db.Model(&models.Registration{}).Select("id").Where("event_id = ? AND status != ?", eventID, models.RegistrationStatusCanceled)).
+		Where("add_ons.event_id = ?", eventID).
+		Group("add_ons.id").
+		Having("add_ons.deleted_at IS NULL OR COUNT(registration_add_ons.id) > 0").
+		Order("add_ons.id").
+		Scan(&addOnSales)
+
+	type BundleSales struct {
+		BundleID uint         `json:"bundle_id"`
+		Name     string       `json:"name"`
+		Tickets  int64        `json:"tickets"`
+		Revenue  models.Money `json:"revenue"`
+	}
+
+	var bundleSales []BundleSales
+	h.db.Table("registrations").
+		Select("registrations.bundle_id, COALESCE(bundles.name, '') as name, COUNT(registrations.id) as tickets, COALESCE(SUM(registrations.total_price), 0) as revenue").
+		Joins("LEFT JOIN bundles ON bundles.id = registrations.bundle_id").
+		Where("registrations.event_id = ? AND registrations.bundle_id IS NOT NULL AND registrations.status != ?", eventID, models.RegistrationStatusCanceled).
+		Group("registrations.bundle_id").
+		Order("registrations.bundle_id").
+		Scan(&bundleSales)
+
+	type GateCheckIns struct {
+		Gate      string `json:"gate"`
+		CheckedIn int64  `json:"checked_in"`
+	}
+
+	var checkedIn int64
+	var gateCheckIns []GateCheckIns
+	h.db.Model(&models.CheckIn{}).Where("event_id = ? AND undone_at IS NULL", eventID).Count(&checkedIn)
+	h.db.Model(&models.CheckIn{}).
+		Select("gate, COUNT(*) as checked_in").
+		Where("event_id = ? AND undone_at IS NULL", eventID).
+		Group("gate").
+		Order("gate").
+		Scan(&gateCheckIns)
+
 	type RegistrationOverTime struct {
 		Date  string `json:"date"`
 		Count int64  `json:"count"`
@@ -157,6 +274,7 @@ func (h *StatisticsHandler) GetEventStats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"event_id":    event.ID,
 		"event_title": event.Title,
+		"currency":    event.Currency,
 		"registrations": gin.H{
 			"total":     pendingCount + confirmedCount + cancelledCount,
 			"pending":   pendingCount,
@@ -164,12 +282,24 @@ func (h *StatisticsHandler) GetEventStats(c *gin.Context) {
 			"cancelled": cancelledCount,
 		},
 		"ticket_sales":  ticketSales,
+		"price_tiers":   priceTierSales,
 		"total_revenue": totalRevenue,
 		"feedback": gin.H{
 			"count":               feedbackCount,
 			"average_rating":      avgRating,
 			"rating_distribution": ratingDistribution,
 		},
+		"promo_codes": gin.H{
+			"usage":          promoCodeUsage,
+			"total_discount": totalDiscount,
+		},
+		"check_ins": gin.H{
+			"checked_in": checkedIn,
+			"confirmed":  confirmedCount,
+			"by_gate":    gateCheckIns,
+		},
+		"add_ons":                 addOnSales,
+		"bundles":                 bundleSales,
 		"registrations_over_time": registrationsOverTime,
 	})
 }
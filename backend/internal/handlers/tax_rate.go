@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TaxRateHandler struct {
+	db *gorm.DB
+}
+
+func NewTaxRateHandler() *TaxRateHandler {
+	return &TaxRateHandler{
+		db: database.GetDB(),
+	}
+}
+
+type taxRateInput struct {
+	Country   string  `json:"country" binding:"required"`
+	State     string  `json:"state"`
+	Name      string  `json:"name" binding:"required"`
+	Rate      float64 `json:"rate" binding:"required"`
+	Inclusive bool    `json:"inclusive"`
+}
+
+func (in *taxRateInput) apply(rate *models.TaxRate) {
+	rate.Country = strings.TrimSpace(in.Country)
+	rate.State = strings.TrimSpace(in.State)
+	rate.Name = strings.TrimSpace(in.Name)
+	rate.Rate = in.Rate
+	rate.Inclusive = in.Inclusive
+}
+
+func (h *TaxRateHandler) ListTaxRates(c *gin.Context) {
+	var rates []models.TaxRate
+	if err := h.db.Order("country, state").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// CreateTaxRate sets up the tax for a country, or for one state of it when a
+// state is given
+func (h *TaxRateHandler) CreateTaxRate(c *gin.Context) {
+	var input taxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rate models.TaxRate
+	input.apply(&rate)
+	if err := rate.Check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.regionTaken(&rate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tax rate already exists for this country and state"})
+		return
+	}
+
+	if err := h.db.Create(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tax rate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// UpdateTaxRate changes a rate for registrations made from now on, existing
+// ones keep the tax they were charged
+func (h *TaxRateHandler) UpdateTaxRate(c *gin.Context) {
+	rate, ok := h.findTaxRate(c)
+	if !ok {
+		return
+	}
+
+	var input taxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.apply(rate)
+	if err := rate.Check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.regionTaken(rate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tax rate already exists for this country and state"})
+		return
+	}
+
+	if err := h.db.Save(rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tax rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+func (h *TaxRateHandler) DeleteTaxRate(c *gin.Context) {
+	rate, ok := h.findTaxRate(c)
+	if !ok {
+		return
+	}
+
+	// hard deleted so the region can be given a new rate
+	if err := h.db.Unscoped().Delete(rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tax rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rate deleted successfully"})
+}
+
+func (h *TaxRateHandler) regionTaken(rate *models.TaxRate) bool {
+	var count int64
+	h.db.Model(&models.TaxRate{}).
+		Where("LOWER(country) = LOWER(?) AND LOWER(state) = LOWER(?) AND id != ?", rate.Country, rate.State, rate.ID).
+		Count(&count)
+	return count > 0
+}
+
+func (h *TaxRateHandler) findTaxRate(c *gin.Context) (*models.TaxRate, bool) {
+	rateID, err := strconv.ParseUint(c.Param("rate_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rate ID"})
+		return nil, false
+	}
+
+	var rate models.TaxRate
+	if err := h.db.First(&rate, rateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rate"})
+		}
+		return nil, false
+	}
+	return &rate, true
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TicketHandler struct {
+	db      *gorm.DB
+	tickets *services.TicketSigner
+	printer *services.Printer
+}
+
+func NewTicketHandler(tickets *services.TicketSigner) *TicketHandler {
+	return &TicketHandler{
+		db:      database.GetDB(),
+		tickets: tickets,
+		printer: services.NewPrinter(tickets),
+	}
+}
+
+// GetTicket downloads the QR code for a confirmed ticket. Apps that draw
+// their own QR code can ask for ?format=json to get the signed credential,
+// and ?format=pdf gives a ticket ready to print.
+func (h *TicketHandler) GetTicket(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	registrationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid registration ID"})
+		return
+	}
+
+	registration, err := models.FindRegistrationByID(h.db, uint(registrationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found"})
+		return
+	}
+
+	// the holder, or the event's organizer reissuing it for them
+	if registration.UserID != userID.(uint) {
+		var event models.Event
+		h.db.Select("id, user_id").First(&event, registration.EventID)
+		if !isEventManager(c, &event) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view this ticket"})
+			return
+		}
+	}
+
+	if registration.Status != models.RegistrationStatusConfirmed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tickets are only issued for confirmed registrations"})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		credential, err := h.tickets.Sign(registration)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"registration_id": registration.ID,
+			"event_id":        registration.EventID,
+			"credential":      credential,
+			"key_id":          h.tickets.KeyID(),
+		})
+		return
+	}
+
+	if c.Query("format") == "pdf" {
+		var event models.Event
+		var ticketType models.TicketType
+		var holder models.User
+		h.db.First(&event, registration.EventID)
+		h.db.First(&ticketType, registration.TicketTypeID)
+		h.db.First(&holder, registration.UserID)
+		pdf, err := h.printer.TicketPDF(services.TicketDocument{
+			Event:        &event,
+			Registration: registration,
+			TicketType:   ticketType.Name,
+			AttendeeName: registration.NameOnTicket(&holder),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="ticket-%d.pdf"`, registration.ID))
+		c.Data(http.StatusOK, "application/pdf", pdf)
+		return
+	}
+
+	png, err := h.tickets.QRCode(registration)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="ticket-%d.png"`, registration.ID))
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// GetPublicKey publishes the key ticket credentials are signed with, for
+// scanner apps checking tickets offline
+func (h *TicketHandler) GetPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "Ed25519",
+		"key_id":     h.tickets.KeyID(),
+		"public_key": base64.StdEncoding.EncodeToString(h.tickets.PublicKey()),
+		// how to check a credential read from a ticket's QR code
+		"format": "T1.key_id.claims.signature, claims and signature are unpadded base64url, " +
+			"the signature is over everything before the last dot and the claims are JSON",
+	})
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"fmt"
 	"lujke-dunn/314-group-project/backend/internal/database"
 	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
 	"net/http"
 	"strconv"
 	"time"
@@ -12,12 +14,14 @@ import (
 )
 
 type TicketTypeHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	waitlist *services.Waitlist
 }
 
-func NewTicketTypeHandler() *TicketTypeHandler {
+func NewTicketTypeHandler(waitlist *services.Waitlist) *TicketTypeHandler {
 	return &TicketTypeHandler{
-		db: database.GetDB(),
+		db:       database.GetDB(),
+		waitlist: waitlist,
 	}
 }
 
@@ -49,13 +53,17 @@ func (h *TicketTypeHandler) CreateTicketType(c *gin.Context) {
 	}
 
 	var input struct {
-		Name              string     `json:"name" binding:"required"`
-		Description       string     `json:"description"`
-		Price             float64    `json:"price" binding:"required"`
-		QuantityAvailable int        `json:"quantity_available" binding:"required"`
-		IsVIP             bool       `json:"is_vip"`
-		SaleStartDate     *time.Time `json:"sale_start_date"`
-		SaleEndDate       *time.Time `json:"sale_end_date"`
+		Name              string             `json:"name" binding:"required"`
+		Description       string             `json:"description"`
+		Price             models.Money       `json:"price" binding:"required"`
+		QuantityAvailable int                `json:"quantity_available" binding:"required"`
+		IsVIP             bool               `json:"is_vip"`
+		IsHidden          bool               `json:"is_hidden"`
+		MinPerUser        int                `json:"min_per_user"`
+		MaxPerUser        int                `json:"max_per_user"`
+		SaleStartDate     *time.Time         `json:"sale_start_date"`
+		SaleEndDate       *time.Time         `json:"sale_end_date"`
+		PriceTiers        []models.PriceTier `json:"price_tiers"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -68,11 +76,21 @@ func (h *TicketTypeHandler) CreateTicketType(c *gin.Context) {
 		return
 	}
 
+	if err := models.CheckPriceTiers(input.PriceTiers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if input.QuantityAvailable <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity available must be positive"})
 		return
 	}
 
+	if err := models.CheckPurchaseLimitSettings(input.MinPerUser, input.MaxPerUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if input.SaleStartDate != nil && input.SaleEndDate != nil {
 		if input.SaleEndDate.Before(*input.SaleStartDate) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Sale end date must be after sale start date"})
@@ -80,6 +98,16 @@ func (h *TicketTypeHandler) CreateTicketType(c *gin.Context) {
 		}
 	}
 
+	msg, err := checkTicketCapacity(h.db, &event, 0, input.QuantityAvailable)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check venue capacity"})
+		return
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
 	ticketType := models.TicketType{
 		EventID:           uint(eventID),
 		Name:              input.Name,
@@ -87,14 +115,24 @@ func (h *TicketTypeHandler) CreateTicketType(c *gin.Context) {
 		Price:             input.Price,
 		QuantityAvailable: input.QuantityAvailable,
 		IsVIP:             input.IsVIP,
+		IsHidden:          input.IsHidden,
+		MinPerUser:        input.MinPerUser,
+		MaxPerUser:        input.MaxPerUser,
 		SaleStartDate:     input.SaleStartDate,
 		SaleEndDate:       input.SaleEndDate,
 	}
 
-	if err := h.db.Create(&ticketType).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ticketType).Error; err != nil {
+			return err
+		}
+		return models.ReplacePriceTiers(tx, ticketType.ID, input.PriceTiers)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket type"})
 		return
 	}
+	ticketType.PriceTiers = input.PriceTiers
 	c.JSON(http.StatusCreated, ticketType)
 }
 
@@ -111,29 +149,52 @@ func (h *TicketTypeHandler) GetTicketTypes(c *gin.Context) {
 		return
 	}
 
-	if !event.IsPublished {
-		userID, exists := c.Get("userID")
-		if !exists || userID.(uint) != event.UserID {
-			isAdmin, exists := c.Get("isAdmin")
-			if !exists || !isAdmin.(bool) {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Event not published"})
-				return
-			}
+	if !checkEventAccess(c, h.db, &event, c.Query("key")) {
+		return
+	}
+
+	// hidden ticket types are only listed for the organizer or with an access code that unlocks them
+	var accessCode *models.AccessCode
+	if code := c.Query("access_code"); code != "" {
+		accessCode, err = models.FindAccessCode(h.db, event.ID, code)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access code"})
+			return
+		}
+		if err := accessCode.CheckUsable(h.db); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
+	query := h.db.Where("event_id = ?", eventID).Scopes(visibleTicketTypes(c, &event, accessCode))
+
 	var ticketTypes []models.TicketType
-	if err := h.db.Where("event_id = ?", eventID).Find(&ticketTypes).Error; err != nil {
+	if err := query.Find(&ticketTypes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ticket types"})
 		return
 	}
 
 	type TicketTypeWithAvailability struct {
 		models.TicketType
-		AvailableQuantity int `json:"available_quantity"`
-		SoldQuantity      int `json:"sold_quantity"`
+		AvailableQuantity int                 `json:"available_quantity"`
+		SoldQuantity      int                 `json:"sold_quantity"`
+		CurrentPrice      models.Money        `json:"current_price"`
+		CurrentTier       string              `json:"current_tier,omitempty"`
+		NextPriceChange   *models.PriceChange `json:"next_price_change,omitempty"`
+	}
+
+	ids := make([]uint, len(ticketTypes))
+	for i := range ticketTypes {
+		ids[i] = ticketTypes[i].ID
+	}
+	tiers, err := models.FindPriceTiersByTicketTypes(h.db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price tiers"})
+		return
 	}
 
+	now := time.Now()
 	var enrichedTicketTypes []TicketTypeWithAvailability
 	for _, ticket := range ticketTypes {
 		availableQty, err := ticket.GetAvailableQuantity(h.db)
@@ -143,10 +204,19 @@ func (h *TicketTypeHandler) GetTicketTypes(c *gin.Context) {
 		}
 
 		soldQty := ticket.QuantityAvailable - availableQty
+		ticket.PriceTiers = tiers[ticket.ID]
+		price, tier := ticket.PriceAt(ticket.PriceTiers, soldQty, now)
+		var tierName string
+		if tier != nil {
+			tierName = tier.Name
+		}
 		enrichedTicketTypes = append(enrichedTicketTypes, TicketTypeWithAvailability{
 			TicketType:        ticket,
 			AvailableQuantity: availableQty,
 			SoldQuantity:      soldQty,
+			CurrentPrice:      price,
+			CurrentTier:       tierName,
+			NextPriceChange:   ticket.NextPriceChange(ticket.PriceTiers, soldQty, now),
 		})
 	}
 
@@ -193,13 +263,18 @@ func (h *TicketTypeHandler) UpdateTicketType(c *gin.Context) {
 	}
 
 	var input struct {
-		Name              string     `json:"name"`
-		Description       string     `json:"description"`
-		Price             *float64   `json:"price"`
-		QuantityAvailable *int       `json:"quantity_available"`
-		IsVIP             *bool      `json:"is_vip"`
-		SaleStartDate     *time.Time `json:"sale_start_date"`
-		SaleEndDate       *time.Time `json:"sale_end_date"`
+		Name              string        `json:"name"`
+		Description       string        `json:"description"`
+		Price             *models.Money `json:"price"`
+		QuantityAvailable *int          `json:"quantity_available"`
+		IsVIP             *bool         `json:"is_vip"`
+		IsHidden          *bool         `json:"is_hidden"`
+		MinPerUser        *int          `json:"min_per_user"`
+		MaxPerUser        *int          `json:"max_per_user"`
+		SaleStartDate     *time.Time    `json:"sale_start_date"`
+		SaleEndDate       *time.Time    `json:"sale_end_date"`
+		// replaces all of the tiers, an empty list removes them
+		PriceTiers *[]models.PriceTier `json:"price_tiers"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -207,6 +282,13 @@ func (h *TicketTypeHandler) UpdateTicketType(c *gin.Context) {
 		return
 	}
 
+	if input.PriceTiers != nil {
+		if err := models.CheckPriceTiers(*input.PriceTiers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	if input.Name != "" {
 		ticketType.Name = input.Name
 	}
@@ -227,6 +309,19 @@ func (h *TicketTypeHandler) UpdateTicketType(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity available must be positive"})
 			return
 		}
+		msg, err := checkTicketCapacity(h.db, &event, ticketType.ID, *input.QuantityAvailable)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check venue capacity"})
+			return
+		}
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if *input.QuantityAvailable < ticketType.QuantitySold {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d tickets have already been sold", ticketType.QuantitySold)})
+			return
+		}
 		ticketType.QuantityAvailable = *input.QuantityAvailable
 	}
 
@@ -234,6 +329,21 @@ func (h *TicketTypeHandler) UpdateTicketType(c *gin.Context) {
 		ticketType.IsVIP = *input.IsVIP
 	}
 
+	if input.IsHidden != nil {
+		ticketType.IsHidden = *input.IsHidden
+	}
+
+	if input.MinPerUser != nil {
+		ticketType.MinPerUser = *input.MinPerUser
+	}
+	if input.MaxPerUser != nil {
+		ticketType.MaxPerUser = *input.MaxPerUser
+	}
+	if err := models.CheckPurchaseLimitSettings(ticketType.MinPerUser, ticketType.MaxPerUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if input.SaleStartDate != nil {
 		ticketType.SaleStartDate = input.SaleStartDate
 	}
@@ -249,11 +359,30 @@ func (h *TicketTypeHandler) UpdateTicketType(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Save(&ticketType).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&ticketType).Error; err != nil {
+			return err
+		}
+		if input.PriceTiers == nil {
+			return nil
+		}
+		return models.ReplacePriceTiers(tx, ticketType.ID, *input.PriceTiers)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket type"})
 		return
 	}
 
+	if ticketType.PriceTiers, err = models.FindPriceTiers(h.db, ticketType.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price tiers"})
+		return
+	}
+
+	// extra tickets go to the waitlist first
+	if input.QuantityAvailable != nil {
+		h.waitlist.Promote(ticketType.ID)
+	}
+
 	c.JSON(http.StatusOK, ticketType)
 }
 
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TransferHandler struct {
+	db           *gorm.DB
+	emailService *services.EmailService
+	frontendURL  string
+}
+
+func NewTransferHandler(emailService *services.EmailService, frontendURL string) *TransferHandler {
+	return &TransferHandler{
+		db:           database.GetDB(),
+		emailService: emailService,
+		frontendURL:  strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// CreateTransfer offers the holder's confirmed ticket to someone else by email.
+// The ticket stays the holder's until the recipient accepts.
+func (h *TransferHandler) CreateTransfer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	registrationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid registration ID"})
+		return
+	}
+
+	var input struct {
+		Email string `json:"email" binding:"required"`
+		Name  string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Email = models.NormalizeEmail(input.Email)
+	input.Name = strings.TrimSpace(input.Name)
+	if _, err := mail.ParseAddress(input.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("'%s' is not a valid email address", input.Email)})
+		return
+	}
+
+	registration, err := models.FindRegistrationByID(h.db, uint(registrationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found"})
+		return
+	}
+
+	if registration.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only transfer your own tickets"})
+		return
+	}
+
+	if registration.Status != models.RegistrationStatusConfirmed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only confirmed tickets can be transferred"})
+		return
+	}
+
+	if _, err := models.FindActiveCheckIn(h.db, registration.ID); err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This ticket has already been checked in"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, registration.EventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if msg := transferBlocked(&event); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	sender, err := models.FindUserByID(h.db, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if models.NormalizeEmail(sender.Email) == input.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't transfer a ticket to yourself"})
+		return
+	}
+
+	if _, err := models.FindPendingTransfer(h.db, registration.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This ticket already has a transfer waiting to be accepted, cancel it first"})
+		return
+	}
+
+	transfer, err := models.NewTicketTransfer(registration, &event, input.Email, input.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+		return
+	}
+	if err := h.db.Create(transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+		return
+	}
+
+	h.sendTransfer(transfer, sender, &event, registration)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Transfer sent, the ticket is yours until it's accepted",
+		"transfer": transfer,
+	})
+}
+
+// sendTransfer emails the recipient their link to accept, and lets them know
+// in the app too if they already have an account
+func (h *TransferHandler) sendTransfer(transfer *models.TicketTransfer, sender *models.User, event *models.Event, registration *models.Registration) {
+	var ticketType models.TicketType
+	h.db.First(&ticketType, registration.TicketTypeID)
+
+	fromName := strings.TrimSpace(sender.FirstName + " " + sender.LastName)
+	if recipient, err := findUserByEmail(h.db, transfer.ToEmail); err == nil {
+		models.CreateNotification(h.db, recipient.ID, &event.ID, "You've been sent a ticket",
+			fmt.Sprintf("%s has sent you a %s ticket for %s. Accept it by %s.",
+				fromName, ticketType.Name, event.Title, transfer.ExpiresAt.Format("Jan 2 3:04 PM")),
+			models.NotificationTypeTransfer)
+	}
+
+	if h.emailService == nil {
+		return
+	}
+	link := fmt.Sprintf("%s/transfers/accept?token=%s", h.frontendURL, url.QueryEscape(transfer.Token))
+	go func() {
+		if err := h.emailService.SendTicketTransfer(transfer.ToEmail, transfer.ToName, fromName, event, ticketType.Name, link, transfer.ExpiresAt); err != nil {
+			log.Printf("Failed to send ticket transfer to %s: %v", transfer.ToEmail, err)
+		}
+	}()
+}
+
+// CancelTransfer takes back a transfer that hasn't been accepted yet
+func (h *TransferHandler) CancelTransfer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	registrationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid registration ID"})
+		return
+	}
+
+	transfer, err := models.FindPendingTransfer(h.db, uint(registrationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This ticket has no pending transfer"})
+		return
+	}
+
+	if transfer.FromUserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only cancel your own transfers"})
+		return
+	}
+
+	if err := transfer.Close(h.db, models.TransferStatusCanceled); err != nil {
+		if errors.Is(err, models.ErrTransferNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Transfer canceled",
+		"transfer": transfer,
+	})
+}
+
+// GetTransfer shows the recipient what they're being sent before they accept
+func (h *TransferHandler) GetTransfer(c *gin.Context) {
+	transfer, err := models.FindTicketTransferByToken(h.db, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+
+	var event models.Event
+	var ticketType models.TicketType
+	var registration models.Registration
+	var sender models.User
+	h.db.First(&registration, transfer.RegistrationID)
+	h.db.First(&event, transfer.EventID)
+	h.db.First(&ticketType, registration.TicketTypeID)
+	h.db.First(&sender, transfer.FromUserID)
+
+	status := transfer.Status
+	if transfer.IsExpired() {
+		status = models.TransferStatusExpired
+	}
+	_, err = findUserByEmail(h.db, transfer.ToEmail)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      status,
+		"to_email":    transfer.ToEmail,
+		"to_name":     transfer.ToName,
+		"from_name":   strings.TrimSpace(sender.FirstName + " " + sender.LastName),
+		"expires_at":  transfer.ExpiresAt,
+		"has_account": err == nil,
+		"event": gin.H{
+			"id":             event.ID,
+			"title":          event.Title,
+			"start_datetime": event.StartDatetime,
+			"venue":          event.Venue,
+		},
+		"ticket_type": ticketType.Name,
+	})
+}
+
+// AcceptTransfer moves the ticket to the recipient. Someone without an account
+// can create one as they accept, someone with one has to be logged in to it.
+func (h *TransferHandler) AcceptTransfer(c *gin.Context) {
+	var input struct {
+		Token     string `json:"token" binding:"required"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Password  string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := models.FindTicketTransferByToken(h.db, input.Token)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+	if transfer.IsExpired() {
+		transfer.Close(h.db, models.TransferStatusExpired)
+		c.JSON(http.StatusConflict, gin.H{"error": models.ErrTransferExpired.Error()})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, transfer.EventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if msg := transferBlocked(&event); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var recipient *models.User
+	if userID, exists := c.Get("userID"); exists {
+		recipient, err = models.FindUserByID(h.db, userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if models.NormalizeEmail(recipient.Email) != transfer.ToEmail {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("This ticket was sent to %s", transfer.ToEmail)})
+			return
+		}
+	} else if _, err := findUserByEmail(h.db, transfer.ToEmail); err == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Log in as %s to accept this ticket", transfer.ToEmail)})
+		return
+	} else {
+		input.FirstName = strings.TrimSpace(input.FirstName)
+		input.LastName = strings.TrimSpace(input.LastName)
+		if input.FirstName == "" || input.LastName == "" || len(input.Password) < 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "First name, last name and a password of at least 6 characters are needed to create your account"})
+			return
+		}
+		recipient = &models.User{
+			Email:       transfer.ToEmail,
+			FirstName:   input.FirstName,
+			LastName:    input.LastName,
+			IsOrganizer: true,
+		}
+		if err := recipient.SetPassword(input.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+			return
+		}
+	}
+
+	var registration *models.Registration
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if recipient.ID == 0 {
+			if err := tx.Create(recipient).Error; err != nil {
+				return err
+			}
+		}
+		var ticketTypeID uint
+		if err := tx.Model(&models.Registration{}).Where("id = ?", transfer.RegistrationID).Select("ticket_type_id").Scan(&ticketTypeID).Error; err != nil {
+			return err
+		}
+		// the recipient can't go over the event's limits by being sent tickets
+		purchase := models.Purchase{UserID: recipient.ID, Quantities: map[uint]int{ticketTypeID: 1}}
+		if err := models.CheckPurchaseLimits(tx, &event, purchase); err != nil {
+			return err
+		}
+		var err error
+		registration, err = transfer.Accept(tx, recipient)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTransferExpired):
+			transfer.Close(h.db, models.TransferStatusExpired)
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrTicketChanged):
+			transfer.Close(h.db, models.TransferStatusCanceled)
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrTransferNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case models.IsPurchaseLimitError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept transfer"})
+		}
+		return
+	}
+
+	models.CreateNotification(h.db, transfer.FromUserID, &event.ID, "Your ticket was transferred",
+		fmt.Sprintf("%s accepted your ticket for %s. It's no longer in your account.", transfer.ToEmail, event.Title),
+		models.NotificationTypeTransfer)
+
+	// the ticket got a new code, so the recipient needs its QR code
+	if h.emailService != nil {
+		var ticketType models.TicketType
+		h.db.First(&ticketType, registration.TicketTypeID)
+		joinInfo := models.JoinInfoForRegistration(h.db, registration, &event)
+		go func() {
+			if err := h.emailService.SendRegistrationConfirmation(recipient, &event, ticketType.Name, joinInfo, registration); err != nil {
+				log.Printf("Failed to send ticket to %s: %v", recipient.Email, err)
+			}
+		}()
+	}
+
+	response := gin.H{
+		"message":      "Ticket accepted, it's now in your account",
+		"registration": registration,
+	}
+	// a new account is logged straight in
+	if _, exists := c.Get("userID"); !exists {
+		token, err := newAuthToken(recipient)
+		if err == nil {
+			response["token"] = token
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetTransferHistory lists who a ticket has been passed between. The current
+// and previous holders can see it, as can the event's organizer.
+func (h *TransferHandler) GetTransferHistory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	registrationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid registration ID"})
+		return
+	}
+
+	registration, err := models.FindRegistrationByID(h.db, uint(registrationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found"})
+		return
+	}
+
+	transfers, err := models.FindTransfersByRegistration(h.db, registration.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfers"})
+		return
+	}
+
+	allowed := registration.UserID == userID.(uint)
+	for _, transfer := range transfers {
+		allowed = allowed || transfer.FromUserID == userID.(uint)
+	}
+	if !allowed {
+		var event models.Event
+		h.db.Select("id, user_id").First(&event, registration.EventID)
+		if !isEventManager(c, &event) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view this ticket's transfers"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"registration_id": registration.ID,
+		"transfers":       transfers,
+	})
+}
+
+// GetEventTransfers lists the event's ticket transfers, newest first
+func (h *TransferHandler) GetEventTransfers(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view transfers for this event")
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.TicketTransfer{}).Where("event_id = ?", event.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	transfers, page, err := paginate[models.TicketTransfer](c, query, "ticket_transfers", sortKey{Name: "created", Expr: "ticket_transfers.created_at", Desc: true}, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch transfers")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers":  transfers,
+		"pagination": page,
+	})
+}
+
+// transferBlocked says why tickets to the event can't be transferred, or
+// nothing if they can
+func transferBlocked(event *models.Event) string {
+	switch {
+	case event.TransfersDisabled:
+		return "The organizer has turned off ticket transfers for this event"
+	case event.IsCanceled:
+		return "This event has been canceled"
+	case !event.StartDatetime.After(time.Now()):
+		return "Tickets can't be transferred once the event has started"
+	}
+	return ""
+}
+
+// findUserByEmail matches an account regardless of how its email was cased
+func findUserByEmail(db *gorm.DB, email string) (*models.User, error) {
+	var user models.User
+	if err := db.Where("LOWER(email) = ?", models.NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -93,14 +93,7 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":     user.ID,
-		"email":       user.Email,
-		"is_admin":    user.IsAdmin,
-		"is_organizer": user.IsOrganizer,
-	})
-
-	tokenString, err := token.SignedString([]byte("dogpark"))
+	tokenString, err := newAuthToken(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -119,6 +112,17 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 	})
 }
 
+// newAuthToken signs the token a user is logged in with
+func newAuthToken(user *models.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":     user.ID,
+		"email":       user.Email,
+		"is_admin":    user.IsAdmin,
+		"is_organizer": user.IsOrganizer,
+	})
+	return token.SignedString([]byte("dogpark"))
+}
+
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
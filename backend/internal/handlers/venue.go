@@ -0,0 +1,635 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultVenueRadiusKm = 25.0
+	autocompleteLimit    = 10
+	kmPerDegree          = 111.32
+)
+
+var (
+	errVenueNotFound    = errors.New("Venue not found")
+	errRoomNotInVenue   = errors.New("Room not found for this venue")
+	errRoomWithoutVenue = errors.New("A room can only be set together with a venue")
+)
+
+// sums the live ticket quantities of an event row
+const eventTicketTotalSQL = "(SELECT COALESCE(SUM(ticket_types.quantity_available), 0) FROM ticket_types WHERE ticket_types.event_id = events.id AND ticket_types.deleted_at IS NULL)"
+
+var venueSortKeys = map[string]sortKey{
+	"name":     {Name: "name", Expr: "venues.name"},
+	"capacity": {Name: "capacity", Expr: "venues.capacity", Desc: true},
+	"newest":   {Name: "newest", Expr: "venues.created_at", Desc: true},
+}
+
+type VenueHandler struct {
+	db *gorm.DB
+}
+
+func NewVenueHandler() *VenueHandler {
+	return &VenueHandler{
+		db: database.GetDB(),
+	}
+}
+
+type venueInput struct {
+	Name          *string  `json:"name"`
+	Address       *string  `json:"address"`
+	City          *string  `json:"city"`
+	State         *string  `json:"state"`
+	ZipCode       *string  `json:"zip_code"`
+	Country       *string  `json:"country"`
+	Latitude      *float64 `json:"latitude"`
+	Longitude     *float64 `json:"longitude"`
+	Capacity      *int     `json:"capacity"`
+	Accessibility *string  `json:"accessibility"`
+	StepFree      *bool    `json:"step_free"`
+	HearingLoop   *bool    `json:"hearing_loop"`
+	Website       *string  `json:"website"`
+}
+
+// apply copies every field that was sent onto the venue
+func (in venueInput) apply(venue *models.Venue) {
+	setString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	setString(&venue.Name, in.Name)
+	setString(&venue.Address, in.Address)
+	setString(&venue.City, in.City)
+	setString(&venue.State, in.State)
+	setString(&venue.ZipCode, in.ZipCode)
+	setString(&venue.Country, in.Country)
+	setString(&venue.Accessibility, in.Accessibility)
+	setString(&venue.Website, in.Website)
+
+	if in.Latitude != nil {
+		venue.Latitude = in.Latitude
+	}
+	if in.Longitude != nil {
+		venue.Longitude = in.Longitude
+	}
+	if in.Capacity != nil {
+		venue.Capacity = *in.Capacity
+	}
+	if in.StepFree != nil {
+		venue.StepFree = *in.StepFree
+	}
+	if in.HearingLoop != nil {
+		venue.HearingLoop = *in.HearingLoop
+	}
+}
+
+func validateVenue(venue *models.Venue) string {
+	if venue.Name == "" {
+		return "Venue name is required"
+	}
+	if venue.Capacity < 0 {
+		return "Capacity cannot be negative"
+	}
+	if (venue.Latitude == nil) != (venue.Longitude == nil) {
+		return "Latitude and longitude must be set together"
+	}
+	if venue.Latitude != nil && (*venue.Latitude < -90 || *venue.Latitude > 90) {
+		return "Latitude must be between -90 and 90"
+	}
+	if venue.Longitude != nil && (*venue.Longitude < -180 || *venue.Longitude > 180) {
+		return "Longitude must be between -180 and 180"
+	}
+	return ""
+}
+
+func (h *VenueHandler) CreateVenue(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input venueInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	venue := models.Venue{UserID: userID.(uint)}
+	input.apply(&venue)
+
+	if msg := validateVenue(&venue); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := h.db.Create(&venue).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create venue"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, venue)
+}
+
+func (h *VenueHandler) GetVenue(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return
+	}
+
+	var venue models.Venue
+	err = h.db.Preload("Rooms", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name")
+	}).First(&venue, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return
+	}
+
+	var upcoming int64
+	h.db.Model(&models.Event{}).
+		Where("venue_id = ? AND is_published = ? AND is_canceled = ? AND visibility = ? AND start_datetime > ?", venue.ID, true, false, models.EventVisibilityPublic, time.Now()).
+		Count(&upcoming)
+
+	c.JSON(http.StatusOK, gin.H{
+		"venue":           venue,
+		"upcoming_events": upcoming,
+	})
+}
+
+// ListVenues searches venues by text, city, capacity and accessibility. When
+// lat and lng are given only venues within radius_km are returned, nearest first.
+func (h *VenueHandler) ListVenues(c *gin.Context) {
+	query := h.db.Model(&models.Venue{})
+
+	if q := strings.TrimSpace(c.Query("query")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("(venues.name LIKE ? OR venues.address LIKE ? OR venues.city LIKE ?)", like, like, like)
+	}
+
+	if cities := queryList(c, "city"); len(cities) > 0 {
+		conditions := make([]string, len(cities))
+		args := make([]interface{}, len(cities))
+		for i, city := range cities {
+			conditions[i] = "venues.city LIKE ?"
+			args[i] = "%" + city + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	if raw := c.Query("min_capacity"); raw != "" {
+		minCapacity, err := strconv.Atoi(raw)
+		if err != nil || minCapacity < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_capacity must be a non-negative number"})
+			return
+		}
+		query = query.Where("venues.capacity >= ?", minCapacity)
+	}
+
+	if stepFree, _ := strconv.ParseBool(c.Query("step_free")); stepFree {
+		query = query.Where("venues.step_free = ?", true)
+	}
+	if hearingLoop, _ := strconv.ParseBool(c.Query("hearing_loop")); hearingLoop {
+		query = query.Where("venues.hearing_loop = ?", true)
+	}
+
+	sort := c.DefaultQuery("sort", "name")
+	key, ok := venueSortKeys[sort]
+
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+		if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng must be valid coordinates"})
+			return
+		}
+
+		radius := defaultVenueRadiusKm
+		if raw := c.Query("radius_km"); raw != "" {
+			r, err := strconv.ParseFloat(raw, 64)
+			if err != nil || r <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be a positive number"})
+				return
+			}
+			radius = r
+		}
+
+		// equirectangular approximation, plenty accurate at city scale
+		lngScale := math.Max(math.Cos(lat*math.Pi/180), 0.01)
+		latDelta := radius / kmPerDegree
+		lngDelta := latDelta / lngScale
+		distanceSQL := fmt.Sprintf("(((venues.latitude - %[1]f) * (venues.latitude - %[1]f) + (venues.longitude - %[2]f) * %[3]f * (venues.longitude - %[2]f) * %[3]f))",
+			lat, lng, lngScale)
+
+		query = query.Where("venues.latitude IS NOT NULL AND venues.longitude IS NOT NULL").
+			Where("venues.latitude BETWEEN ? AND ?", lat-latDelta, lat+latDelta).
+			Where("venues.longitude BETWEEN ? AND ?", lng-lngDelta, lng+lngDelta).
+			Where(distanceSQL+" <= ?", latDelta*latDelta)
+
+		if c.Query("sort") == "" || sort == "distance" {
+			sort, ok = "distance", true
+			key = sortKey{Name: "distance", Expr: distanceSQL}
+		}
+	}
+
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort option"})
+		return
+	}
+
+	venues, page, err := paginate[models.Venue](c, query, "venues", key, defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch venues")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"venues":     venues,
+		"pagination": page,
+		"sort":       sort,
+	})
+}
+
+// AutocompleteVenues returns a short list of venues for a search box, names
+// starting with the text first
+func (h *VenueHandler) AutocompleteVenues(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusOK, gin.H{"venues": []gin.H{}})
+		return
+	}
+
+	var venues []struct {
+		ID       uint   `json:"id"`
+		Name     string `json:"name"`
+		City     string `json:"city"`
+		Address  string `json:"address"`
+		Capacity int    `json:"capacity"`
+	}
+
+	err := h.db.Model(&models.Venue{}).
+		Select("id, name, city, address, capacity").
+		Where("name LIKE ? OR city LIKE ?", "%"+q+"%", q+"%").
+		Order(gorm.Expr("CASE WHEN name LIKE ? THEN 0 ELSE 1 END, name", q+"%")).
+		Limit(autocompleteLimit).
+		Scan(&venues).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search venues"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"venues": venues})
+}
+
+// GetVenueEvents lists the published events held at a venue, upcoming only
+// unless include_past is set
+func (h *VenueHandler) GetVenueEvents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return
+	}
+
+	if _, err := models.FindVenueByID(h.db, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return
+	}
+
+	query := h.db.Model(&models.Event{}).
+		Where("events.venue_id = ? AND events.is_published = ? AND events.is_canceled = ? AND events.visibility = ?", id, true, false, models.EventVisibilityPublic).
+		Preload("Room")
+
+	if includePast, _ := strconv.ParseBool(c.Query("include_past")); !includePast {
+		query = query.Where("events.end_datetime > ?", time.Now())
+	}
+
+	if roomID := c.Query("room_id"); roomID != "" {
+		query = query.Where("events.venue_room_id = ?", roomID)
+	}
+
+	events, page, err := paginate[models.Event](c, query, "events", eventSortKeys["date"], defaultPageSize)
+	if err != nil {
+		paginationError(c, err, "Failed to fetch venue events")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     events,
+		"pagination": page,
+	})
+}
+
+func (h *VenueHandler) UpdateVenue(c *gin.Context) {
+	venue, ok := h.findManagedVenue(c, "You don't have permission to update this venue")
+	if !ok {
+		return
+	}
+
+	var input venueInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldCapacity := venue.Capacity
+	input.apply(venue)
+
+	if msg := validateVenue(venue); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// events that rely on the venue's capacity must still fit
+	if venue.Capacity > 0 && (oldCapacity == 0 || venue.Capacity < oldCapacity) {
+		var over int64
+		h.db.Model(&models.Event{}).
+			Where("events.venue_id = ? AND events.is_canceled = ?", venue.ID, false).
+			Where("(events.venue_room_id IS NULL OR events.venue_room_id IN (?))",
+				h.db.Model(&models.VenueRoom{}).Select("id").Where("venue_id = ? AND capacity = 0", venue.ID)).
+			Where(eventTicketTotalSQL+" > ?", venue.Capacity).
+			Count(&over)
+		if over > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d event(s) at this venue have more tickets than the new capacity", over)})
+			return
+		}
+	}
+
+	if err := h.db.Save(venue).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update venue"})
+		return
+	}
+
+	// keep the copied location on linked events in sync
+	h.db.Model(&models.Event{}).Where("venue_id = ?", venue.ID).Updates(map[string]interface{}{
+		"venue":    venue.Name,
+		"address":  venue.Address,
+		"city":     venue.City,
+		"state":    venue.State,
+		"zip_code": venue.ZipCode,
+		"country":  venue.Country,
+	})
+
+	c.JSON(http.StatusOK, venue)
+}
+
+func (h *VenueHandler) DeleteVenue(c *gin.Context) {
+	venue, ok := h.findManagedVenue(c, "You don't have permission to delete this venue")
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Event{}).Where("venue_id = ?", venue.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a venue that has events"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("venue_id = ?", venue.ID).Delete(&models.VenueRoom{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(venue).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete venue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Venue deleted successfully"})
+}
+
+type roomInput struct {
+	Name          *string `json:"name"`
+	Capacity      *int    `json:"capacity"`
+	Floor         *string `json:"floor"`
+	Accessibility *string `json:"accessibility"`
+}
+
+func (h *VenueHandler) CreateRoom(c *gin.Context) {
+	venue, ok := h.findManagedVenue(c, "You don't have permission to manage rooms for this venue")
+	if !ok {
+		return
+	}
+
+	var input roomInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room := models.VenueRoom{VenueID: venue.ID}
+	if msg := input.apply(&room, venue); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := h.db.Create(&room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, room)
+}
+
+func (h *VenueHandler) UpdateRoom(c *gin.Context) {
+	venue, ok := h.findManagedVenue(c, "You don't have permission to manage rooms for this venue")
+	if !ok {
+		return
+	}
+
+	room, ok := h.findRoom(c, venue)
+	if !ok {
+		return
+	}
+
+	var input roomInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldCapacity := room.Capacity
+	if msg := input.apply(room, venue); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if room.Capacity > 0 && (oldCapacity == 0 || room.Capacity < oldCapacity) {
+		var over int64
+		h.db.Model(&models.Event{}).
+			Where("events.venue_room_id = ? AND events.is_canceled = ?", room.ID, false).
+			Where(eventTicketTotalSQL+" > ?", room.Capacity).
+			Count(&over)
+		if over > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d event(s) in this room have more tickets than the new capacity", over)})
+			return
+		}
+	}
+
+	if err := h.db.Save(room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+		return
+	}
+
+	c.JSON(http.StatusOK, room)
+}
+
+func (h *VenueHandler) DeleteRoom(c *gin.Context) {
+	venue, ok := h.findManagedVenue(c, "You don't have permission to manage rooms for this venue")
+	if !ok {
+		return
+	}
+
+	room, ok := h.findRoom(c, venue)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Event{}).Where("venue_room_id = ?", room.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a room that has events"})
+		return
+	}
+
+	if err := h.db.Delete(room).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete room"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Room deleted successfully"})
+}
+
+func (in roomInput) apply(room *models.VenueRoom, venue *models.Venue) string {
+	if in.Name != nil {
+		room.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Capacity != nil {
+		room.Capacity = *in.Capacity
+	}
+	if in.Floor != nil {
+		room.Floor = *in.Floor
+	}
+	if in.Accessibility != nil {
+		room.Accessibility = *in.Accessibility
+	}
+
+	if room.Name == "" {
+		return "Room name is required"
+	}
+	if room.Capacity < 0 {
+		return "Capacity cannot be negative"
+	}
+	if venue.Capacity > 0 && room.Capacity > venue.Capacity {
+		return fmt.Sprintf("Room capacity cannot exceed the venue capacity of %d", venue.Capacity)
+	}
+	return ""
+}
+
+// findManagedVenue loads the venue named by the :id param and checks the
+// current user created it or is an admin
+func (h *VenueHandler) findManagedVenue(c *gin.Context, forbiddenMessage string) (*models.Venue, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return nil, false
+	}
+
+	venue, err := models.FindVenueByID(h.db, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return nil, false
+	}
+
+	if venue.UserID != userID.(uint) {
+		isAdmin, exists := c.Get("isAdmin")
+		if !exists || !isAdmin.(bool) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMessage})
+			return nil, false
+		}
+	}
+
+	return venue, true
+}
+
+func (h *VenueHandler) findRoom(c *gin.Context, venue *models.Venue) (*models.VenueRoom, bool) {
+	roomID, err := strconv.ParseUint(c.Param("room_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return nil, false
+	}
+
+	room, err := models.FindVenueRoom(h.db, venue.ID, uint(roomID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found for this venue"})
+		return nil, false
+	}
+
+	return room, true
+}
+
+// linkEventVenue points the event at a venue and optionally one of its rooms,
+// copying the venue's address onto the event
+func linkEventVenue(db *gorm.DB, event *models.Event, venueID, roomID *uint) error {
+	if venueID == nil {
+		if roomID != nil {
+			return errRoomWithoutVenue
+		}
+		return nil
+	}
+
+	venue, err := models.FindVenueByID(db, *venueID)
+	if err != nil {
+		return errVenueNotFound
+	}
+
+	if roomID != nil {
+		if _, err := models.FindVenueRoom(db, venue.ID, *roomID); err != nil {
+			return errRoomNotInVenue
+		}
+	}
+
+	venue.ApplyTo(event)
+	event.VenueRoomID = roomID
+	return nil
+}
+
+// checkTicketCapacity makes sure the event's ticket quantities, with quantity
+// for the ticket type excludeID, fit in its venue. The returned message is
+// empty when they do.
+func checkTicketCapacity(db *gorm.DB, event *models.Event, excludeID uint, quantity int) (string, error) {
+	capacity, place, err := models.EventCapacity(db, event)
+	if err != nil || capacity == 0 {
+		return "", err
+	}
+
+	total, err := models.TotalTicketQuantity(db, event.ID, excludeID)
+	if err != nil {
+		return "", err
+	}
+
+	if total+quantity > capacity {
+		return fmt.Sprintf("Total ticket quantity (%d) would exceed the capacity of %s (%d)", total+quantity, place, capacity), nil
+	}
+	return "", nil
+}
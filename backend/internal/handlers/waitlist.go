@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"errors"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"lujke-dunn/314-group-project/backend/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WaitlistHandler struct {
+	db       *gorm.DB
+	waitlist *services.Waitlist
+}
+
+func NewWaitlistHandler(waitlist *services.Waitlist) *WaitlistHandler {
+	return &WaitlistHandler{
+		db:       database.GetDB(),
+		waitlist: waitlist,
+	}
+}
+
+// JoinWaitlist queues the user for a sold out ticket type
+func (h *WaitlistHandler) JoinWaitlist(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var input struct {
+		Key string `json:"key"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var event models.Event
+	if err := h.db.First(&event, eventID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !event.IsPublished || event.IsCanceled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event is not available for registration"})
+		return
+	}
+
+	if !checkEventAccess(c, h.db, &event, input.Key) {
+		return
+	}
+
+	var ticketType models.TicketType
+	if err := h.db.Where("id = ? AND event_id = ?", c.Param("ticket_id"), event.ID).First(&ticketType).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found for this event"})
+		return
+	}
+
+	if ticketType.IsHidden {
+		c.JSON(http.StatusForbidden, gin.H{"error": "There's no waitlist for this ticket type"})
+		return
+	}
+
+	if !ticketType.IsOnSale() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tickets are not currently on sale"})
+		return
+	}
+
+	if entry, err := models.FindActiveWaitlistEntry(h.db, userID.(uint), ticketType.ID); err == nil {
+		position, _ := entry.Position(h.db)
+		c.JSON(http.StatusConflict, gin.H{"error": "You're already on the waitlist for this ticket type", "entry": entry, "position": position})
+		return
+	}
+
+	available, err := ticketType.GetAvailableQuantity(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket availability"})
+		return
+	}
+	if available > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tickets are still available, register instead"})
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		EventID:      event.ID,
+		TicketTypeID: ticketType.ID,
+		UserID:       userID.(uint),
+		Status:       models.WaitlistStatusWaiting,
+	}
+	if err := h.db.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+		return
+	}
+
+	position, _ := entry.Position(h.db)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "You've been added to the waitlist",
+		"entry":    entry,
+		"position": position,
+	})
+}
+
+// GetUserWaitlist lists the waitlists the user is on along with their place in each
+func (h *WaitlistHandler) GetUserWaitlist(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var entries []models.WaitlistEntry
+	query := h.db.Where("user_id = ?", userID)
+	if c.Query("all") != "true" {
+		query = query.Where("status IN ?", []models.WaitlistStatus{models.WaitlistStatusWaiting, models.WaitlistStatusOffered})
+	}
+	if err := query.Order("created_at DESC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+
+	response := make([]gin.H, 0, len(entries))
+	for i := range entries {
+		var event models.Event
+		var ticketType models.TicketType
+		h.db.Select("id, title, start_datetime").First(&event, entries[i].EventID)
+		h.db.Select("id, name").First(&ticketType, entries[i].TicketTypeID)
+		position, _ := entries[i].Position(h.db)
+
+		item := gin.H{
+			"id":               entries[i].ID,
+			"event_id":         entries[i].EventID,
+			"event_title":      event.Title,
+			"event_start":      event.StartDatetime,
+			"ticket_type_id":   entries[i].TicketTypeID,
+			"ticket_name":      ticketType.Name,
+			"status":           entries[i].Status,
+			"position":         position,
+			"offer_expires_at": entries[i].OfferExpiresAt,
+			"registration_id":  entries[i].RegistrationID,
+			"created_at":       entries[i].CreatedAt,
+		}
+		// the token is also in the offer email, this lets the app claim without it
+		if entries[i].Status == models.WaitlistStatusOffered {
+			item["claim_token"] = entries[i].ClaimToken
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// LeaveWaitlist takes the user off a waitlist, passing on any ticket offered to them
+func (h *WaitlistHandler) LeaveWaitlist(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var entry models.WaitlistEntry
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("entry_id"), userID).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return
+	}
+
+	if !entry.IsActive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You're no longer on this waitlist"})
+		return
+	}
+
+	wasOffered := entry.Status == models.WaitlistStatusOffered
+	if err := entry.Close(h.db, models.WaitlistStatusRemoved); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+	if wasOffered {
+		h.waitlist.Promote(entry.TicketTypeID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You've left the waitlist"})
+}
+
+// ClaimOffer registers the user for the ticket kept for them. Like a normal
+// registration it's pending until paid for.
+func (h *WaitlistHandler) ClaimOffer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Token   string               `json:"token" binding:"required"`
+		SeatID  *uint                `json:"seat_id"`
+		Answers []models.AnswerInput `json:"answers"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := models.FindWaitlistEntryByToken(h.db, input.Token)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist offer not found"})
+		return
+	}
+
+	if entry.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This offer was made to someone else"})
+		return
+	}
+
+	seated, err := models.IsSeatedTicketType(h.db, entry.TicketTypeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket availability"})
+		return
+	}
+
+	if seated && input.SeatID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.ErrSeatRequired.Error()})
+		return
+	}
+
+	if !seated && input.SeatID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This ticket type doesn't have reserved seating"})
+		return
+	}
+
+	questions, err := models.FindQuestionsForTicketType(h.db, entry.EventID, entry.TicketTypeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load registration questions"})
+		return
+	}
+
+	answers, err := models.CheckAnswers(questions, input.Answers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var registration *models.Registration
+	var seat *models.SeatAssignment
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		registration, err = entry.Claim(tx)
+		if err != nil {
+			return err
+		}
+		if len(answers) > 0 {
+			for i := range answers {
+				answers[i].RegistrationID = registration.ID
+			}
+			if err := tx.Create(&answers).Error; err != nil {
+				return err
+			}
+		}
+		if seated {
+			seat, err = models.AssignSeat(tx, registration, *input.SeatID)
+		}
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrOfferExpired), errors.Is(err, models.ErrNotOffered):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case seated && registration != nil:
+			seatError(c, err)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim ticket"})
+		}
+		return
+	}
+
+	response := gin.H{
+		"message":      "Ticket claimed, complete your payment to confirm it",
+		"registration": registration,
+		"answers":      answers,
+	}
+	if seat != nil {
+		response["seat"] = seat.Seat
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetEventWaitlist lists everyone on the event's waitlists in queue order.
+// ?ticket_type_id= limits it to one ticket type.
+func (h *WaitlistHandler) GetEventWaitlist(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to view the waitlist for this event")
+	if !ok {
+		return
+	}
+
+	type waitlistRow struct {
+		models.WaitlistEntry
+		TicketName string `json:"ticket_name"`
+		FirstName  string `json:"first_name"`
+		LastName   string `json:"last_name"`
+		Email      string `json:"email"`
+		Position   int    `json:"position" gorm:"-"`
+	}
+
+	query := h.db.Table("waitlist_entries").
+		Select("waitlist_entries.*, ticket_types.name AS ticket_name, users.first_name, users.last_name, users.email").
+		Joins("JOIN ticket_types ON ticket_types.id = waitlist_entries.ticket_type_id").
+		Joins("JOIN users ON users.id = waitlist_entries.user_id").
+		Where("waitlist_entries.event_id = ? AND waitlist_entries.deleted_at IS NULL", event.ID).
+		Where("waitlist_entries.status IN ?", []models.WaitlistStatus{models.WaitlistStatusWaiting, models.WaitlistStatusOffered})
+	if ticketTypeID := c.Query("ticket_type_id"); ticketTypeID != "" {
+		query = query.Where("waitlist_entries.ticket_type_id = ?", ticketTypeID)
+	}
+
+	var rows []waitlistRow
+	if err := query.Order("waitlist_entries.ticket_type_id, waitlist_entries.id").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+
+	positions := make(map[uint]int)
+	for i := range rows {
+		if rows[i].Status == models.WaitlistStatusWaiting {
+			positions[rows[i].TicketTypeID]++
+			rows[i].Position = positions[rows[i].TicketTypeID]
+		}
+	}
+	if rows == nil {
+		rows = []waitlistRow{}
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// PromoteEntry offers a free ticket to a user on the waitlist straight away,
+// ahead of anyone before them
+func (h *WaitlistHandler) PromoteEntry(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the waitlist for this event")
+	if !ok {
+		return
+	}
+
+	var entry models.WaitlistEntry
+	if err := h.db.Where("id = ? AND event_id = ?", c.Param("entry_id"), event.ID).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found for this event"})
+		return
+	}
+
+	if entry.Status != models.WaitlistStatusWaiting {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only users still waiting can be promoted"})
+		return
+	}
+
+	if err := h.waitlist.OfferTo(&entry); err != nil {
+		if errors.Is(err, models.ErrSoldOut) {
+			c.JSON(http.StatusConflict, gin.H{"error": "There are no free tickets to offer, raise the ticket quantity first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote waitlist entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ticket offered to the user",
+		"entry":   entry,
+	})
+}
+
+// ClearWaitlist removes everyone still waiting. Tickets already offered can
+// still be claimed. ?ticket_type_id= clears just one ticket type.
+func (h *WaitlistHandler) ClearWaitlist(c *gin.Context) {
+	event, ok := findManagedEvent(c, h.db, "You don't have permission to manage the waitlist for this event")
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.WaitlistEntry{}).Where("event_id = ? AND status = ?", event.ID, models.WaitlistStatusWaiting)
+	if ticketTypeID := c.Query("ticket_type_id"); ticketTypeID != "" {
+		query = query.Where("ticket_type_id = ?", ticketTypeID)
+	}
+
+	result := query.Update("status", models.WaitlistStatusRemoved)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Waitlist cleared",
+		"removed": result.RowsAffected,
+	})
+}
@@ -25,14 +25,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte("dogpark"), nil
-		})
-
+		token, err := parseToken(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
 			c.Abort()
@@ -40,10 +33,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("userID", uint(claims["user_id"].(float64)))
-			c.Set("email", claims["email"].(string))
-			c.Set("isAdmin", claims["is_admin"].(bool))
-			c.Set("isOrganizer", claims["is_organizer"].(bool))
+			setClaims(c, claims)
 			c.Next()
 		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
@@ -53,6 +43,39 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware sets the user on the context when a valid token is
+// sent but lets anonymous requests through, for public routes that show more
+// to signed in users
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if token, err := parseToken(parts[1]); err == nil && token.Valid {
+				if claims, ok := token.Claims.(jwt.MapClaims); ok {
+					setClaims(c, claims)
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+func parseToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte("dogpark"), nil
+	})
+}
+
+func setClaims(c *gin.Context, claims jwt.MapClaims) {
+	c.Set("userID", uint(claims["user_id"].(float64)))
+	c.Set("email", claims["email"].(string))
+	c.Set("isAdmin", claims["is_admin"].(bool))
+	c.Set("isOrganizer", claims["is_organizer"].(bool))
+}
+
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		isAdmin, exists := c.Get("isAdmin")
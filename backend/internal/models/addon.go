@@ -0,0 +1,231 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrAddOnSoldOut = errors.New("is sold out")
+
+// AddOn is something sold alongside an event's tickets, like parking or a
+// workshop pass. What a buyer takes is recorded against one of their tickets
+// as a RegistrationAddOn.
+type AddOn struct {
+	Base
+	EventID     uint   `gorm:"not null;index" json:"event_id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	Price       Money  `gorm:"not null;default:0" json:"price"`
+	// nil when there's no limit
+	QuantityAvailable *int `json:"quantity_available"`
+	// only ever changed by ReserveAddOn and ReleaseAddOn, like a ticket
+	// type's sold count
+	QuantitySold int `gorm:"<-:false;not null;default:0" json:"quantity_sold"`
+
+	// no ticket types means it can go with any ticket
+	TicketTypeIDs []uint `gorm:"-" json:"ticket_type_ids"`
+}
+
+func (AddOn) TableName() string {
+	return "add_ons"
+}
+
+// AddOnTicketType limits an add-on to tickets of a ticket type
+type AddOnTicketType struct {
+	AddOnID      uint `gorm:"primaryKey"`
+	TicketTypeID uint `gorm:"primaryKey;index"`
+}
+
+func (AddOnTicketType) TableName() string {
+	return "add_on_ticket_types"
+}
+
+// RegistrationAddOn is a quantity of an add-on bought with a ticket. The name
+// and price are copied so the purchase reads the same if the add-on changes.
+type RegistrationAddOn struct {
+	Base
+	RegistrationID uint   `gorm:"not null;index" json:"registration_id"`
+	AddOnID        uint   `gorm:"not null;index" json:"add_on_id"`
+	Name           string `gorm:"type:varchar(100);not null" json:"name"`
+	Quantity       int    `gorm:"not null" json:"quantity"`
+	UnitPrice      Money  `gorm:"not null" json:"unit_price"`
+	Total          Money  `gorm:"not null" json:"total"`
+}
+
+func (RegistrationAddOn) TableName() string {
+	return "registration_add_ons"
+}
+
+// AddOnLine is a quantity of an add-on a buyer is about to take
+type AddOnLine struct {
+	AddOn    *AddOn
+	Quantity int
+}
+
+// CheckDefinition reports what's wrong with an add-on an organizer is saving
+func (a *AddOn) CheckDefinition() error {
+	if strings.TrimSpace(a.Name) == "" {
+		return errors.New("Name is required")
+	}
+	if a.Price < 0 {
+		return errors.New("Price cannot be negative")
+	}
+	if a.QuantityAvailable != nil && *a.QuantityAvailable < 0 {
+		return errors.New("Quantity available cannot be negative")
+	}
+	return nil
+}
+
+func (a *AddOn) AppliesTo(ticketTypeID uint) bool {
+	if len(a.TicketTypeIDs) == 0 {
+		return true
+	}
+	for _, id := range a.TicketTypeIDs {
+		if id == ticketTypeID {
+			return true
+		}
+	}
+	return false
+}
+
+// Available is how many more can be sold, -1 when there's no limit
+func (a *AddOn) Available() int {
+	if a.QuantityAvailable == nil {
+		return -1
+	}
+	return max(*a.QuantityAvailable-a.QuantitySold, 0)
+}
+
+// ReserveAddOn takes quantity of the add-on's stock, or fails with
+// ErrAddOnSoldOut if there isn't that much left
+func ReserveAddOn(db *gorm.DB, addOnID uint, quantity int) error {
+	result := db.Exec("UPDATE add_ons SET quantity_sold = quantity_sold + ? WHERE id = ? AND (quantity_available IS NULL OR quantity_sold + ? <= quantity_available)", quantity, addOnID, quantity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAddOnSoldOut
+	}
+	return nil
+}
+
+// ReleaseAddOn gives back stock taken with ReserveAddOn
+func ReleaseAddOn(db *gorm.DB, addOnID uint, quantity int) error {
+	return db.Exec("UPDATE add_ons SET quantity_sold = MAX(quantity_sold - ?, 0) WHERE id = ?", quantity, addOnID).Error
+}
+
+// AddAddOns records the add-ons bought with the registration and adds them to
+// what the buyer owes
+func (r *Registration) AddAddOns(tx *gorm.DB, lines []AddOnLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	items := make([]RegistrationAddOn, 0, len(lines))
+	for _, line := range lines {
+		if err := ReserveAddOn(tx, line.AddOn.ID, line.Quantity); err != nil {
+			if errors.Is(err, ErrAddOnSoldOut) {
+				return fmt.Errorf("%s %w", line.AddOn.Name, err)
+			}
+			return err
+		}
+		item := RegistrationAddOn{
+			RegistrationID: r.ID,
+			AddOnID:        line.AddOn.ID,
+			Name:           line.AddOn.Name,
+			Quantity:       line.Quantity,
+			UnitPrice:      line.AddOn.Price,
+			Total:          line.AddOn.Price * Money(line.Quantity),
+		}
+		r.AddOnAmount += item.Total
+		items = append(items, item)
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return err
+	}
+	r.AddOns = append(r.AddOns, items...)
+
+	if err := r.applyCharges(tx); err != nil {
+		return err
+	}
+	columns := r.chargeColumns()
+	columns["add_on_amount"] = r.AddOnAmount
+	return tx.Model(r).Updates(columns).Error
+}
+
+// changeAddOnStock releases or takes back the add-ons bought with a
+// registration when it's canceled or reinstated
+func changeAddOnStock(tx *gorm.DB, registrationID uint, release bool) error {
+	var items []RegistrationAddOn
+	if err := tx.Session(&gorm.Session{NewDB: true}).Where("registration_id = ?", registrationID).Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		var err error
+		if release {
+			err = ReleaseAddOn(tx, item.AddOnID, item.Quantity)
+		} else if err = ReserveAddOn(tx, item.AddOnID, item.Quantity); errors.Is(err, ErrAddOnSoldOut) {
+			err = fmt.Errorf("%s %w", item.Name, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadAddOnTicketTypes fills in the ticket types the add-ons are limited to
+func LoadAddOnTicketTypes(db *gorm.DB, addOns []AddOn) error {
+	for i := range addOns {
+		addOns[i].TicketTypeIDs = []uint{}
+		if err := db.Model(&AddOnTicketType{}).Where("add_on_id = ?", addOns[i].ID).Pluck("ticket_type_id", &addOns[i].TicketTypeIDs).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceAddOnTicketTypes limits the add-on to the given ticket types, or
+// lifts the limit when there are none
+func ReplaceAddOnTicketTypes(tx *gorm.DB, addOn *AddOn) error {
+	if err := tx.Where("add_on_id = ?", addOn.ID).Delete(&AddOnTicketType{}).Error; err != nil {
+		return err
+	}
+	if len(addOn.TicketTypeIDs) == 0 {
+		return nil
+	}
+	links := make([]AddOnTicketType, len(addOn.TicketTypeIDs))
+	for i, id := range addOn.TicketTypeIDs {
+		links[i] = AddOnTicketType{AddOnID: addOn.ID, TicketTypeID: id}
+	}
+	return tx.Create(&links).Error
+}
+
+func FindAddOnsByEvent(db *gorm.DB, eventID uint) ([]AddOn, error) {
+	var addOns []AddOn
+	if err := db.Where("event_id = ?", eventID).Order("id").Find(&addOns).Error; err != nil {
+		return nil, err
+	}
+	return addOns, LoadAddOnTicketTypes(db, addOns)
+}
+
+// FindAddOnsByRegistrations loads what was bought with several registrations
+// keyed by registration
+func FindAddOnsByRegistrations(db *gorm.DB, registrationIDs []uint) (map[uint][]RegistrationAddOn, error) {
+	byRegistration := make(map[uint][]RegistrationAddOn, len(registrationIDs))
+	if len(registrationIDs) == 0 {
+		return byRegistration, nil
+	}
+
+	var items []RegistrationAddOn
+	if err := db.Where("registration_id IN ?", registrationIDs).Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		byRegistration[item.RegistrationID] = append(byRegistration[item.RegistrationID], item)
+	}
+	return byRegistration, nil
+}
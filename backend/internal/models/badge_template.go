@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// BadgeLayout is how badges are arranged on the printed page, in millimetres
+type BadgeLayout struct {
+	PageWidth   float64 `json:"page_width"`
+	PageHeight  float64 `json:"page_height"`
+	BadgeWidth  float64 `json:"badge_width"`
+	BadgeHeight float64 `json:"badge_height"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+}
+
+// BadgeLayouts are the page layouts a template can use: a sheet of eight
+// 90x55 mm badges on A4, a sheet of six 4x3 inch badges on US letter, or one
+// A6 badge per page for badge printers
+var BadgeLayouts = map[string]BadgeLayout{
+	"a4_8":     {PageWidth: 210, PageHeight: 297, BadgeWidth: 90, BadgeHeight: 55, Columns: 2, Rows: 4},
+	"letter_6": {PageWidth: 215.9, PageHeight: 279.4, BadgeWidth: 101.6, BadgeHeight: 76.2, Columns: 2, Rows: 3},
+	"a6":       {PageWidth: 148, PageHeight: 105, BadgeWidth: 148, BadgeHeight: 105, Columns: 1, Rows: 1},
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// BadgeTemplate is how an event's name badges are printed
+type BadgeTemplate struct {
+	Base
+	EventID uint   `gorm:"not null;index" json:"event_id"`
+	Name    string `gorm:"type:varchar(100);not null" json:"name"`
+	Layout  string `gorm:"type:varchar(20);not null;default:'a4_8'" json:"layout"`
+	// shown across the top of the badge, the event's title when empty
+	Heading        string `gorm:"type:varchar(100)" json:"heading"`
+	Footer         string `gorm:"type:varchar(100)" json:"footer"`
+	AccentColor    string `gorm:"type:varchar(7);not null;default:'#2196F3'" json:"accent_color"`
+	ShowTicketType bool   `gorm:"default:false" json:"show_ticket_type"`
+	// a QR code of the attendee's ticket so badges can be scanned in
+	ShowQRCode bool `gorm:"default:false" json:"show_qr_code"`
+	// used when badges are printed without naming a template
+	IsDefault bool `gorm:"default:false" json:"is_default"`
+}
+
+func (BadgeTemplate) TableName() string {
+	return "badge_templates"
+}
+
+// DefaultBadgeTemplate is used for events without a template of their own
+func DefaultBadgeTemplate(eventID uint) *BadgeTemplate {
+	return &BadgeTemplate{
+		EventID:        eventID,
+		Name:           "Default",
+		Layout:         "a4_8",
+		AccentColor:    "#2196F3",
+		ShowTicketType: true,
+	}
+}
+
+// CheckDefinition reports what's wrong with a template an organizer is saving
+func (t *BadgeTemplate) CheckDefinition() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("Template name is required")
+	}
+	if t.Layout == "" {
+		t.Layout = "a4_8"
+	}
+	if _, ok := BadgeLayouts[t.Layout]; !ok {
+		return errors.New("Layout must be 'a4_8', 'letter_6' or 'a6'")
+	}
+	if t.AccentColor == "" {
+		t.AccentColor = "#2196F3"
+	}
+	if !hexColor.MatchString(t.AccentColor) {
+		return errors.New("Accent color must be a hex color like #2196F3")
+	}
+	return nil
+}
+
+// FindBadgeTemplate finds the named template, or the event's default when id
+// is 0, falling back to the built in default
+func FindBadgeTemplate(db *gorm.DB, eventID, id uint) (*BadgeTemplate, error) {
+	var template BadgeTemplate
+	if id != 0 {
+		if err := db.Where("id = ? AND event_id = ?", id, eventID).First(&template).Error; err != nil {
+			return nil, err
+		}
+		return &template, nil
+	}
+	if err := db.Where("event_id = ? AND is_default = ?", eventID, true).First(&template).Error; err != nil {
+		return DefaultBadgeTemplate(eventID), nil
+	}
+	return &template, nil
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Bundle sells tickets of several ticket types together at one price, e.g. a
+// family pass of two adult and two child tickets. Buying one creates a
+// registration for every ticket in it, with the price split between them.
+type Bundle struct {
+	Base
+	EventID     uint         `gorm:"not null;index" json:"event_id"`
+	Name        string       `gorm:"type:varchar(100);not null" json:"name"`
+	Description string       `gorm:"type:text" json:"description"`
+	Price       Money        `gorm:"not null;default:0" json:"price"`
+	Items       []BundleItem `gorm:"foreignKey:BundleID" json:"items"`
+}
+
+func (Bundle) TableName() string {
+	return "bundles"
+}
+
+type BundleItem struct {
+	BundleID     uint `gorm:"primaryKey" json:"-"`
+	TicketTypeID uint `gorm:"primaryKey;index" json:"ticket_type_id"`
+	Quantity     int  `gorm:"not null;default:1" json:"quantity"`
+}
+
+func (BundleItem) TableName() string {
+	return "bundle_items"
+}
+
+// CheckDefinition reports what's wrong with a bundle an organizer is saving
+func (b *Bundle) CheckDefinition() error {
+	if strings.TrimSpace(b.Name) == "" {
+		return errors.New("Name is required")
+	}
+	if b.Price < 0 {
+		return errors.New("Price cannot be negative")
+	}
+	seen := make(map[uint]bool, len(b.Items))
+	for _, item := range b.Items {
+		if item.Quantity < 1 {
+			return errors.New("Every item needs a quantity of at least 1")
+		}
+		if seen[item.TicketTypeID] {
+			return errors.New("Each ticket type can only be listed once per bundle")
+		}
+		seen[item.TicketTypeID] = true
+	}
+	if b.TicketCount() < 2 {
+		return errors.New("A bundle needs at least 2 tickets")
+	}
+	return nil
+}
+
+// TicketCount is how many tickets one bundle is made up of
+func (b *Bundle) TicketCount() int {
+	count := 0
+	for _, item := range b.Items {
+		count += item.Quantity
+	}
+	return count
+}
+
+// SplitBundlePrice shares a bundle's price between its tickets in proportion
+// to what they'd cost on their own, so ticket type revenue still adds up. Any
+// cents left over from rounding go on the last ticket.
+func SplitBundlePrice(price Money, listPrices []Money) []Money {
+	shares := make([]Money, len(listPrices))
+	if len(listPrices) == 0 {
+		return shares
+	}
+
+	var listTotal Money
+	for _, listPrice := range listPrices {
+		listTotal += listPrice
+	}
+	var given Money
+	for i, listPrice := range listPrices[:len(listPrices)-1] {
+		if listTotal > 0 {
+			shares[i] = Money(int64(price) * int64(listPrice) / int64(listTotal))
+		} else {
+			shares[i] = price / Money(len(listPrices))
+		}
+		given += shares[i]
+	}
+	shares[len(shares)-1] = price - given
+	return shares
+}
+
+// ApplyBundlePrice prices a registration as its share of a bundle, in place of
+// the ticket type's price or tier
+func (r *Registration) ApplyBundlePrice(tx *gorm.DB, bundleID uint, price Money) error {
+	r.BundleID = &bundleID
+	r.TotalPrice = price
+	r.PriceTierID = nil
+	r.PriceTierName = ""
+	if err := r.applyCharges(tx); err != nil {
+		return err
+	}
+
+	columns := r.chargeColumns()
+	columns["bundle_id"] = bundleID
+	columns["total_price"] = r.TotalPrice
+	columns["price_tier_id"] = nil
+	columns["price_tier_name"] = ""
+	return tx.Model(r).Updates(columns).Error
+}
+
+func FindBundlesByEvent(db *gorm.DB, eventID uint) ([]Bundle, error) {
+	var bundles []Bundle
+	result := db.Preload("Items").Where("event_id = ?", eventID).Order("id").Find(&bundles)
+	return bundles, result.Error
+}
+
+func FindBundle(db *gorm.DB, eventID, bundleID uint) (*Bundle, error) {
+	var bundle Bundle
+	result := db.Preload("Items").Where("id = ? AND event_id = ?", bundleID, eventID).First(&bundle)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &bundle, nil
+}
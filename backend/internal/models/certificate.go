@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrEventNotEnded = errors.New("Certificates can be issued once the event has ended")
+
+// Certificate records that an attendee was at an event, for CPD. Anyone can
+// check one is genuine with its Code. Revoked certificates are kept so
+// checking them says so rather than that they never existed.
+type Certificate struct {
+	Base
+	EventID        uint   `gorm:"not null;index" json:"event_id"`
+	RegistrationID uint   `gorm:"not null;uniqueIndex" json:"registration_id"`
+	UserID         uint   `gorm:"not null;index" json:"user_id"`
+	RecipientName  string `gorm:"type:varchar(255);not null" json:"recipient_name"`
+	RecipientEmail string `gorm:"type:varchar(255);not null" json:"recipient_email"`
+	Code           string `gorm:"type:varchar(20);not null;uniqueIndex" json:"code"`
+	// CPD hours the certificate is worth
+	CPDHours  float64    `json:"cpd_hours"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	EmailedAt *time.Time `json:"emailed_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (Certificate) TableName() string {
+	return "certificates"
+}
+
+func (c *Certificate) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
+
+// NewCertificateCode makes a verification code like CERT-7KQ2MXH4PA
+func NewCertificateCode() (string, error) {
+	return NewPromoCode("CERT", 10)
+}
+
+// EventCPDHours is the event's length in hours to the nearest half hour
+func EventCPDHours(event *Event) float64 {
+	hours := event.EndDatetime.Sub(event.StartDatetime).Hours()
+	if hours < 0 {
+		return 0
+	}
+	return math.Round(hours*2) / 2
+}
+
+// IssueCertificates gives a certificate to every confirmed attendee who was
+// checked in and doesn't have one yet, returning the new certificates. It
+// runs in one transaction so issuing twice at once can't give an attendee two.
+func IssueCertificates(db *gorm.DB, event *Event, cpdHours float64) ([]Certificate, error) {
+	if event.EndDatetime.After(time.Now()) {
+		return nil, ErrEventNotEnded
+	}
+
+	var certificates []Certificate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var registrations []Registration
+		err := tx.Preload("User").
+			Where("event_id = ? AND status = ?", event.ID, RegistrationStatusConfirmed).
+			Where("id IN (?)", tx.Model(&CheckIn{}).Select("registration_id").
+				Where("event_id = ? AND undone_at IS NULL", event.ID)).
+			Where("id NOT IN (?)", tx.Model(&Certificate{}).Select("registration_id").
+				Where("event_id = ?", event.ID)).
+			Order("id").
+			Find(&registrations).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		certificates = make([]Certificate, 0, len(registrations))
+		for i := range registrations {
+			registration := &registrations[i]
+			code, err := NewCertificateCode()
+			if err != nil {
+				return err
+			}
+			email := registration.AttendeeEmail
+			if email == "" {
+				email = registration.User.Email
+			}
+			certificates = append(certificates, Certificate{
+				EventID:        event.ID,
+				RegistrationID: registration.ID,
+				UserID:         registration.UserID,
+				RecipientName:  registration.NameOnTicket(&registration.User),
+				RecipientEmail: email,
+				Code:           code,
+				CPDHours:       cpdHours,
+				IssuedAt:       now,
+			})
+		}
+		if len(certificates) == 0 {
+			return nil
+		}
+		return tx.Create(&certificates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return certificates, nil
+}
+
+func FindCertificateByCode(db *gorm.DB, code string) (*Certificate, error) {
+	var certificate Certificate
+	result := db.Where("code = ?", NormalizeAccessCode(code)).First(&certificate)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &certificate, nil
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CheckInMethod string
+
+const (
+	// the ticket's QR code or code was scanned
+	CheckInMethodScan CheckInMethod = "scan"
+	// door staff found the attendee by name
+	CheckInMethodSearch CheckInMethod = "search"
+)
+
+// why a ticket was turned away at the door, for scanner apps to act on
+const (
+	CheckInRejectedInvalid    = "invalid"
+	CheckInRejectedWrongEvent = "wrong_event"
+	CheckInRejectedReplaced   = "replaced"
+	CheckInRejectedUnpaid     = "unpaid"
+	CheckInRejectedCanceled   = "canceled"
+	CheckInRejectedRefunded   = "refunded"
+	CheckInRejectedDuplicate  = "already_checked_in"
+	// an offline scan uploaded again after its check-in was undone
+	CheckInRejectedUndone = "undone"
+)
+
+// CheckInError says why a ticket can't be checked in
+type CheckInError struct {
+	Reason  string
+	Message string
+	// the earlier check-in a duplicate ran into
+	CheckIn *CheckIn
+}
+
+func (e *CheckInError) Error() string {
+	return e.Message
+}
+
+func AsCheckInError(err error) (*CheckInError, bool) {
+	var checkInErr *CheckInError
+	ok := errors.As(err, &checkInErr)
+	return checkInErr, ok
+}
+
+// CheckIn records a ticket being let in to its event. Undoing a check-in keeps
+// the record, a ticket has at most one check-in that hasn't been undone.
+type CheckIn struct {
+	Base
+	RegistrationID uint          `gorm:"not null;index" json:"registration_id"`
+	EventID        uint          `gorm:"not null;index" json:"event_id"`
+	TicketTypeID   uint          `gorm:"not null" json:"ticket_type_id"`
+	StaffID        uint          `gorm:"not null" json:"staff_id"`
+	Gate           string        `gorm:"type:varchar(100)" json:"gate,omitempty"`
+	Method         CheckInMethod `gorm:"type:varchar(20)" json:"method"`
+	CheckedInAt    time.Time     `json:"checked_in_at"`
+	UndoneAt       *time.Time    `json:"undone_at,omitempty"`
+	UndoneBy       *uint         `json:"undone_by,omitempty"`
+	// set for scans made offline and uploaded later, ScanID is the scanner's
+	// own ID for the scan so uploading it twice doesn't count it twice. Scan
+	// IDs only need to be unique per device.
+	ScanID   string     `gorm:"type:varchar(64);index" json:"scan_id,omitempty"`
+	DeviceID string     `gorm:"type:varchar(100)" json:"device_id,omitempty"`
+	SyncedAt *time.Time `json:"synced_at,omitempty"`
+}
+
+func (CheckIn) TableName() string {
+	return "check_ins"
+}
+
+// CheckInTicket lets the ticket in, turning it away if it isn't paid for,
+// has been canceled or refunded, or is already in. checkIn says who let it in
+// and where, and when if the scan was made offline.
+func CheckInTicket(tx *gorm.DB, registration *Registration, checkIn *CheckIn) (*CheckIn, error) {
+	switch registration.Status {
+	case RegistrationStatusPending:
+		return nil, &CheckInError{Reason: CheckInRejectedUnpaid, Message: "This ticket hasn't been paid for"}
+	case RegistrationStatusCanceled:
+		if wasRefunded(tx, registration) {
+			return nil, &CheckInError{Reason: CheckInRejectedRefunded, Message: "This ticket was refunded"}
+		}
+		return nil, &CheckInError{Reason: CheckInRejectedCanceled, Message: "This ticket was canceled"}
+	}
+
+	if existing, err := FindActiveCheckIn(tx, registration.ID); err == nil {
+		return resolveDuplicate(tx, existing, checkIn)
+	}
+
+	checkIn.RegistrationID = registration.ID
+	checkIn.EventID = registration.EventID
+	checkIn.TicketTypeID = registration.TicketTypeID
+	if checkIn.CheckedInAt.IsZero() {
+		checkIn.CheckedInAt = time.Now()
+	}
+	if err := tx.Create(checkIn).Error; err != nil {
+		// scanned at two gates at once, the unique index lets only one in
+		if existing, findErr := FindActiveCheckIn(tx, registration.ID); findErr == nil {
+			return nil, duplicateCheckIn(existing)
+		}
+		return nil, err
+	}
+	return checkIn, nil
+}
+
+// resolveDuplicate turns away a second scan of a ticket. When scanners were
+// offline the scan uploaded first isn't always the one made first, so an
+// earlier offline scan takes over the check-in and the one already recorded
+// becomes the duplicate.
+func resolveDuplicate(tx *gorm.DB, existing, scan *CheckIn) (*CheckIn, error) {
+	if scan.SyncedAt != nil && scan.CheckedInAt.Before(existing.CheckedInAt) {
+		existing.StaffID = scan.StaffID
+		existing.Gate = scan.Gate
+		existing.Method = scan.Method
+		existing.CheckedInAt = scan.CheckedInAt
+		existing.ScanID = scan.ScanID
+		existing.DeviceID = scan.DeviceID
+		existing.SyncedAt = scan.SyncedAt
+		if err := tx.Save(existing).Error; err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	return nil, duplicateCheckIn(existing)
+}
+
+func duplicateCheckIn(existing *CheckIn) error {
+	message := fmt.Sprintf("Already checked in at %s", existing.CheckedInAt.Local().Format("3:04 PM"))
+	if existing.Gate != "" {
+		message += " at " + existing.Gate
+	}
+	return &CheckInError{Reason: CheckInRejectedDuplicate, Message: message, CheckIn: existing}
+}
+
+// wasRefunded reports whether the ticket was canceled by refunding its payment
+func wasRefunded(db *gorm.DB, registration *Registration) bool {
+	query := db.Model(&Payment{}).Where("status = ?", PaymentStatusRefunded)
+	if registration.OrderID != nil {
+		query = query.Where("registration_id = ? OR order_id = ?", registration.ID, *registration.OrderID)
+	} else {
+		query = query.Where("registration_id = ?", registration.ID)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+// Undo takes back a check-in made by mistake so the ticket can be used again
+func (ci *CheckIn) Undo(db *gorm.DB, staffID uint) error {
+	if ci.UndoneAt != nil {
+		return errors.New("this check-in has already been undone")
+	}
+	now := time.Now()
+	ci.UndoneAt = &now
+	ci.UndoneBy = &staffID
+	return db.Model(ci).Updates(map[string]interface{}{"undone_at": ci.UndoneAt, "undone_by": ci.UndoneBy}).Error
+}
+
+// FindActiveCheckIn finds the ticket's check-in that hasn't been undone
+func FindActiveCheckIn(db *gorm.DB, registrationID uint) (*CheckIn, error) {
+	var checkIn CheckIn
+	result := db.Where("registration_id = ? AND undone_at IS NULL", registrationID).First(&checkIn)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &checkIn, nil
+}
@@ -12,6 +12,8 @@ type Event struct {
 	Title          string        `gorm:"type:varchar(255);not null" json:"title"`
 	Description    string        `gorm:"type:text" json:"description"`
 	Venue          string        `gorm:"type:varchar(255)" json:"venue"`
+	VenueID        *uint         `gorm:"index" json:"venue_id"`
+	VenueRoomID    *uint         `json:"venue_room_id"`
 	StartDatetime  time.Time     `json:"start_datetime"`
 	EndDatetime    time.Time     `json:"end_datetime"`
 	City           string        `gorm:"type:varchar(100)" json:"city"`
@@ -22,13 +24,37 @@ type Event struct {
 	IsVirtual      bool          `gorm:"default:false" json:"is_virtual"`
 	IsPublished    bool          `gorm:"default:false" json:"is_published"`
 	IsCanceled     bool          `gorm:"default:false" json:"is_canceled"`
+	Visibility     EventVisibility `gorm:"type:varchar(20);default:'public';index" json:"visibility"`
+	ShareToken     string        `gorm:"type:varchar(64)" json:"-"`
+	// minutes a pending registration keeps its ticket before it's released
+	HoldMinutes    int           `gorm:"not null;default:30" json:"hold_minutes"`
+	// ISO 4217 code every price for the event is in
+	Currency       string        `gorm:"type:varchar(3);not null;default:'AUD'" json:"currency"`
+	// the organizer's own fee on each paid ticket, always charged to the buyer
+	BookingFee     Money         `gorm:"not null;default:0" json:"booking_fee"`
+	// the organizer pays the platform fee out of the ticket price instead of
+	// it being added to the buyer's total
+	AbsorbFees     bool          `gorm:"default:false" json:"absorb_fees"`
+	// how many tickets one buyer can hold across the event, 0 means no limit
+	MinTicketsPerUser int        `gorm:"not null;default:0" json:"min_tickets_per_user"`
+	MaxTicketsPerUser int        `gorm:"not null;default:0" json:"max_tickets_per_user"`
+	// for high demand events tickets for the same email or phone number count
+	// towards the limits together, whichever account bought them
+	LimitByContact bool          `gorm:"default:false" json:"limit_by_contact"`
+	// stops ticket holders passing their tickets on to someone else
+	TransfersDisabled bool       `gorm:"default:false" json:"transfers_disabled"`
 	
 	// Relationships
 	User           User          `gorm:"foreignKey:UserID" json:"-"`
+	VenueDetails   *Venue        `gorm:"foreignKey:VenueID" json:"venue_details,omitempty"`
+	Room           *VenueRoom    `gorm:"foreignKey:VenueRoomID" json:"room,omitempty"`
 	TicketTypes    []TicketType  `gorm:"foreignKey:EventID" json:"ticket_types,omitempty"`
 	Updates        []EventUpdate `gorm:"foreignKey:EventID" json:"updates,omitempty"`
 	Registrations  []Registration `gorm:"foreignKey:EventID" json:"registrations,omitempty"`
 	Feedbacks      []EventFeedback `gorm:"foreignKey:EventID" json:"feedbacks,omitempty"`
+	Images         []EventImage  `gorm:"foreignKey:EventID" json:"images,omitempty"`
+	Sessions       []EventSession `gorm:"foreignKey:EventID" json:"sessions,omitempty"`
+	Speakers       []Speaker     `gorm:"foreignKey:EventID" json:"speakers,omitempty"`
 }
 
 func (Event) TableName() string {
@@ -48,7 +74,7 @@ func (e *Event) Cancel(db *gorm.DB) error {
 }
 
 
-func (e *Event) AddTicketType(db *gorm.DB, name, description string, price float64, quantityAvailable int, isVIP bool, saleStartDate, saleEndDate *time.Time) (*TicketType, error) {
+func (e *Event) AddTicketType(db *gorm.DB, name, description string, price Money, quantityAvailable int, isVIP bool, saleStartDate, saleEndDate *time.Time) (*TicketType, error) {
 	ticketType := TicketType{
 		EventID:           e.ID,
 		Name:              name,
@@ -89,7 +115,7 @@ func FindEventsByUser(db *gorm.DB, userID uint) ([]Event, error) {
 
 func FindPublishedEvents(db *gorm.DB) ([]Event, error) {
 	var events []Event
-	result := db.Where("is_published = ? AND is_canceled = ?", true, false).Find(&events)
+	result := db.Where("is_published = ? AND is_canceled = ? AND visibility = ?", true, false, EventVisibilityPublic).Find(&events)
 	return events, result.Error
 }
 
@@ -98,8 +124,8 @@ func FindUpcomingEvents(db *gorm.DB, limit int) ([]Event, error) {
 	var events []Event
 	
 	now := time.Now()
-	result := db.Where("start_datetime > ? AND is_published = ? AND is_canceled = ?", 
-		now, true, false).
+	result := db.Where("start_datetime > ? AND is_published = ? AND is_canceled = ? AND visibility = ?", 
+		now, true, false, EventVisibilityPublic).
 		Order("start_datetime").
 		Limit(limit).
 		Find(&events)
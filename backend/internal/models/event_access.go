@@ -0,0 +1,167 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EventVisibility string
+
+const (
+	// listed and open to everyone
+	EventVisibilityPublic EventVisibility = "public"
+	// not listed, anyone with the share link can view and register
+	EventVisibilityUnlisted EventVisibility = "unlisted"
+	// only invited users can view and register
+	EventVisibilityPrivate EventVisibility = "private"
+)
+
+var (
+	ErrAccessCodeExpired = errors.New("this access code has expired")
+	ErrAccessCodeUsedUp  = errors.New("this access code has been used the maximum number of times")
+)
+
+func (v EventVisibility) Valid() bool {
+	return v == EventVisibilityPublic || v == EventVisibilityUnlisted || v == EventVisibilityPrivate
+}
+
+// EventInvite puts an email address on a private event's guest list
+type EventInvite struct {
+	Base
+	EventID   uint       `gorm:"not null;uniqueIndex:idx_event_invite_email" json:"event_id"`
+	Email     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_event_invite_email" json:"email"`
+	Name      string     `gorm:"type:varchar(255)" json:"name"`
+	InvitedBy uint       `json:"invited_by"`
+	EmailedAt *time.Time `json:"emailed_at,omitempty"`
+}
+
+func (EventInvite) TableName() string {
+	return "event_invites"
+}
+
+// AccessCode unlocks hidden ticket types of an event
+type AccessCode struct {
+	Base
+	EventID     uint       `gorm:"not null;uniqueIndex:idx_access_code_event_code" json:"event_id"`
+	Code        string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_access_code_event_code" json:"code"`
+	Description string     `gorm:"type:varchar(255)" json:"description"`
+	MaxUses     *int       `json:"max_uses"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+
+	TicketTypeIDs []uint `gorm:"-" json:"ticket_type_ids"`
+	Uses          int64  `gorm:"-" json:"uses"`
+}
+
+func (AccessCode) TableName() string {
+	return "access_codes"
+}
+
+// AccessCodeTicketType links an access code to a ticket type it unlocks
+type AccessCodeTicketType struct {
+	AccessCodeID uint `gorm:"primaryKey"`
+	TicketTypeID uint `gorm:"primaryKey;index"`
+}
+
+func (AccessCodeTicketType) TableName() string {
+	return "access_code_ticket_types"
+}
+
+// NormalizeEmail lowercases and trims an address so invites match regardless of case
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeAccessCode uppercases and trims a code as typed by a buyer
+func NormalizeAccessCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func NewShareToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// BeforeSave gives events that aren't public a share token for their link
+func (e *Event) BeforeSave(tx *gorm.DB) error {
+	if e.Visibility != "" && e.Visibility != EventVisibilityPublic && e.ShareToken == "" {
+		token, err := NewShareToken()
+		if err != nil {
+			return err
+		}
+		e.ShareToken = token
+	}
+	return nil
+}
+
+func IsInvited(db *gorm.DB, eventID uint, email string) bool {
+	var count int64
+	db.Model(&EventInvite{}).Where("event_id = ? AND email = ?", eventID, NormalizeEmail(email)).Count(&count)
+	return count > 0
+}
+
+func HasActiveRegistration(db *gorm.DB, eventID, userID uint) bool {
+	var count int64
+	db.Model(&Registration{}).Where("event_id = ? AND user_id = ? AND status != ?", eventID, userID, RegistrationStatusCanceled).Count(&count)
+	return count > 0
+}
+
+func FindAccessCode(db *gorm.DB, eventID uint, code string) (*AccessCode, error) {
+	var accessCode AccessCode
+	result := db.Where("event_id = ? AND code = ?", eventID, NormalizeAccessCode(code)).First(&accessCode)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if err := LoadAccessCodeDetails(db, []*AccessCode{&accessCode}); err != nil {
+		return nil, err
+	}
+	return &accessCode, nil
+}
+
+// LoadAccessCodeDetails fills in the unlocked ticket types and use counts
+func LoadAccessCodeDetails(db *gorm.DB, codes []*AccessCode) error {
+	for _, code := range codes {
+		code.TicketTypeIDs = []uint{}
+		if err := db.Model(&AccessCodeTicketType{}).Where("access_code_id = ?", code.ID).Pluck("ticket_type_id", &code.TicketTypeIDs).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&Registration{}).Where("access_code_id = ? AND status != ?", code.ID, RegistrationStatusCanceled).Count(&code.Uses).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AccessCode) Unlocks(ticketTypeID uint) bool {
+	for _, id := range a.TicketTypeIDs {
+		if id == ticketTypeID {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckUsable reports why a code can't be used right now. Uses are recounted
+// with db so it can be called inside the transaction that records a new use.
+func (a *AccessCode) CheckUsable(db *gorm.DB) error {
+	if a.ExpiresAt != nil && time.Now().After(*a.ExpiresAt) {
+		return ErrAccessCodeExpired
+	}
+	if a.MaxUses != nil {
+		var uses int64
+		if err := db.Model(&Registration{}).Where("access_code_id = ? AND status != ?", a.ID, RegistrationStatusCanceled).Count(&uses).Error; err != nil {
+			return err
+		}
+		if uses >= int64(*a.MaxUses) {
+			return ErrAccessCodeUsedUp
+		}
+	}
+	return nil
+}
@@ -51,17 +51,18 @@ func FindFeedbackByEvent(db *gorm.DB, eventID uint) ([]EventFeedback, error) {
 	return feedbacks, result.Error
 }
 
-func GetAverageRatingForEvent(db *gorm.DB, eventID uint) (float64, error) {
+func GetRatingSummaryForEvent(db *gorm.DB, eventID uint) (int64, float64, error) {
 	var result struct {
+		Count     int64
 		AvgRating float64
 	}
 	
 	err := db.Model(&EventFeedback{}).
-		Select("COALESCE(AVG(rating), 0) as avg_rating").
+		Select("COUNT(*) as count, COALESCE(AVG(rating), 0) as avg_rating").
 		Where("event_id = ?", eventID).
 		Scan(&result).Error
 	
-	return result.AvgRating, err
+	return result.Count, result.AvgRating, err
 }
 
 func CreateFeedback(db *gorm.DB, eventID, userID uint, rating int, comment string) (*EventFeedback, error) {
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type ImageKind string
+
+const (
+	ImageKindCover   ImageKind = "cover"
+	ImageKindGallery ImageKind = "gallery"
+)
+
+// ImageVariants are the generated thumbnail sizes, keyed by name with the max width in pixels
+var ImageVariants = map[string]int{
+	"small":  160,
+	"medium": 480,
+	"large":  1024,
+}
+
+type EventImage struct {
+	Base
+	EventID     uint      `gorm:"not null;index" json:"event_id"`
+	Kind        ImageKind `gorm:"type:varchar(20);not null" json:"kind"`
+	Position    int       `gorm:"default:0" json:"position"`
+	Caption     string    `gorm:"type:varchar(255)" json:"caption"`
+	ContentType string    `gorm:"type:varchar(50);not null" json:"content_type"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	SizeBytes   int64     `json:"size_bytes"`
+	// files live under "<KeyPrefix>/original.<ext>" and "<KeyPrefix>/<variant>.jpg"
+	KeyPrefix string `gorm:"type:varchar(255);not null" json:"-"`
+	Extension string `gorm:"type:varchar(10);not null" json:"-"`
+
+	URLs map[string]string `gorm:"-" json:"urls,omitempty"`
+
+	Event Event `gorm:"foreignKey:EventID" json:"-"`
+}
+
+func (EventImage) TableName() string {
+	return "event_images"
+}
+
+func (i *EventImage) OriginalKey() string {
+	return i.KeyPrefix + "/original." + i.Extension
+}
+
+func (i *EventImage) VariantKey(name string) string {
+	return i.KeyPrefix + "/" + name + ".jpg"
+}
+
+// StorageKeys lists every stored file belonging to the image
+func (i *EventImage) StorageKeys() []string {
+	keys := []string{i.OriginalKey()}
+	for name := range ImageVariants {
+		keys = append(keys, i.VariantKey(name))
+	}
+	return keys
+}
+
+func FindImagesByEvent(db *gorm.DB, eventID uint) ([]EventImage, error) {
+	var images []EventImage
+	result := db.Where("event_id = ?", eventID).Order("kind, position, id").Find(&images)
+	return images, result.Error
+}
+
+func FindCoverImage(db *gorm.DB, eventID uint) (*EventImage, error) {
+	var image EventImage
+	result := db.Where("event_id = ? AND kind = ?", eventID, ImageKindCover).First(&image)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &image, nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventJoinInfo holds how to join a virtual event. It lives in its own table
+// so it never goes out with the event itself, only to confirmed attendees
+// and the organizer.
+type EventJoinInfo struct {
+	Base
+	EventID      uint   `gorm:"not null;uniqueIndex" json:"event_id"`
+	Platform     string `gorm:"type:varchar(50)" json:"platform"`
+	URL          string `gorm:"type:varchar(500);not null" json:"url"`
+	Passcode     string `gorm:"type:varchar(100)" json:"passcode"`
+	Instructions string `gorm:"type:text" json:"instructions"`
+	// bumped every time the link is rotated
+	Version   int        `gorm:"default:1" json:"version"`
+	RotatedAt *time.Time `json:"rotated_at"`
+}
+
+func (EventJoinInfo) TableName() string {
+	return "event_join_infos"
+}
+
+func FindJoinInfoByEvent(db *gorm.DB, eventID uint) (*EventJoinInfo, error) {
+	var info EventJoinInfo
+	result := db.Where("event_id = ?", eventID).First(&info)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &info, nil
+}
+
+// JoinInfoForRegistration returns the join details a registration entitles
+// its holder to, or nil if there are none to give out yet
+func JoinInfoForRegistration(db *gorm.DB, registration *Registration, event *Event) *EventJoinInfo {
+	if !event.IsVirtual || registration.Status != RegistrationStatusConfirmed {
+		return nil
+	}
+	info, err := FindJoinInfoByEvent(db, event.ID)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// NewPasscode makes a random numeric passcode for when the organizer rotates
+// the link without picking one
+func NewPasscode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionFull     = errors.New("this session is full")
+	ErrAlreadySignedUp = errors.New("you have already signed up for this session")
+)
+
+// EventSession is a single slot on an event's agenda
+type EventSession struct {
+	Base
+	EventID     uint      `gorm:"not null;index" json:"event_id"`
+	Title       string    `gorm:"type:varchar(255);not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	StartTime   time.Time `gorm:"not null" json:"start_time"`
+	EndTime     time.Time `gorm:"not null" json:"end_time"`
+	Room        string    `gorm:"type:varchar(100)" json:"room"`
+	Track       string    `gorm:"type:varchar(100)" json:"track"`
+	// nil means attendees don't need to sign up for the session
+	Capacity *int `json:"capacity"`
+
+	SignedUp int64 `gorm:"-" json:"signed_up"`
+
+	Event    Event           `gorm:"foreignKey:EventID" json:"-"`
+	Speakers []Speaker       `gorm:"many2many:session_speakers" json:"speakers"`
+	Signups  []SessionSignup `gorm:"foreignKey:SessionID" json:"-"`
+}
+
+func (EventSession) TableName() string {
+	return "event_sessions"
+}
+
+func (s *EventSession) Validate(event *Event) error {
+	if !s.EndTime.After(s.StartTime) {
+		return errors.New("session end time must be after its start time")
+	}
+	if s.StartTime.Before(event.StartDatetime) || s.EndTime.After(event.EndDatetime) {
+		return errors.New("session must take place within the event's start and end time")
+	}
+	if s.Capacity != nil && *s.Capacity <= 0 {
+		return errors.New("session capacity must be positive")
+	}
+	return nil
+}
+
+func (s *EventSession) RequiresSignup() bool {
+	return s.Capacity != nil
+}
+
+// SessionSignup reserves a place in a capacity limited session for an attendee
+type SessionSignup struct {
+	Base
+	SessionID      uint `gorm:"not null;uniqueIndex:idx_session_signup_user" json:"session_id"`
+	UserID         uint `gorm:"not null;uniqueIndex:idx_session_signup_user" json:"user_id"`
+	RegistrationID uint `gorm:"not null;index" json:"registration_id"`
+
+	Session EventSession `gorm:"foreignKey:SessionID" json:"-"`
+	User    User         `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (SessionSignup) TableName() string {
+	return "session_signups"
+}
+
+func FindSessionsByEvent(db *gorm.DB, eventID uint) ([]EventSession, error) {
+	var sessions []EventSession
+	result := db.Preload("Speakers").Where("event_id = ?", eventID).Order("start_time, id").Find(&sessions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return sessions, CountSessionSignups(db, sessions)
+}
+
+// CountSessionSignups fills in SignedUp for each session
+func CountSessionSignups(db *gorm.DB, sessions []EventSession) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+
+	var counts []struct {
+		SessionID uint
+		Count     int64
+	}
+	err := db.Model(&SessionSignup{}).
+		Select("session_id, COUNT(*) as count").
+		Where("session_id IN ?", ids).
+		Group("session_id").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	bySession := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		bySession[c.SessionID] = c.Count
+	}
+	for i := range sessions {
+		sessions[i].SignedUp = bySession[sessions[i].ID]
+	}
+	return nil
+}
+
+// SignUpForSession places the user in the session if there is room. The count
+// and insert run in one transaction so the capacity check sees a stable count.
+func SignUpForSession(db *gorm.DB, session *EventSession, userID, registrationID uint) (*SessionSignup, error) {
+	signup := SessionSignup{
+		SessionID:      session.ID,
+		UserID:         userID,
+		RegistrationID: registrationID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&SessionSignup{}).Where("session_id = ? AND user_id = ?", session.ID, userID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadySignedUp
+		}
+
+		if session.Capacity != nil {
+			var taken int64
+			if err := tx.Model(&SessionSignup{}).Where("session_id = ?", session.ID).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken >= int64(*session.Capacity) {
+				return ErrSessionFull
+			}
+		}
+
+		if err := tx.Create(&signup).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadySignedUp
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &signup, nil
+}
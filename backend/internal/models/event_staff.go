@@ -0,0 +1,24 @@
+package models
+
+import "gorm.io/gorm"
+
+// EventStaff lets a user work an event's door. Staff can check attendees in
+// but can't change the event.
+type EventStaff struct {
+	Base
+	EventID uint `gorm:"not null;uniqueIndex:idx_event_staff_user" json:"event_id"`
+	UserID  uint `gorm:"not null;uniqueIndex:idx_event_staff_user" json:"user_id"`
+	AddedBy uint `json:"added_by"`
+
+	User User `gorm:"foreignKey:UserID" json:"user"`
+}
+
+func (EventStaff) TableName() string {
+	return "event_staff"
+}
+
+func IsEventStaff(db *gorm.DB, eventID, userID uint) bool {
+	var count int64
+	db.Model(&EventStaff{}).Where("event_id = ? AND user_id = ?", eventID, userID).Count(&count)
+	return count > 0
+}
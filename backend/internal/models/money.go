@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the minor unit of its currency, e.g. cents, so adding
+// up prices never drifts the way floats do. In JSON it's written as a decimal
+// in major units, 1250 is 12.50, so clients don't need to know about it.
+type Money int64
+
+// DefaultCurrency is what events are priced in unless the organizer picks
+// another one
+const DefaultCurrency = "AUD"
+
+var ErrInvalidAmount = errors.New("amounts must be a number with at most 2 decimal places")
+
+// currencySymbols are the ISO 4217 currencies events can be priced in. They
+// all have two decimal places, which Money relies on.
+var currencySymbols = map[string]string{
+	"AUD": "A$",
+	"NZD": "NZ$",
+	"USD": "US$",
+	"CAD": "C$",
+	"SGD": "S$",
+	"HKD": "HK$",
+	"EUR": "€",
+	"GBP": "£",
+}
+
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidCurrency(code string) bool {
+	_, ok := currencySymbols[code]
+	return ok
+}
+
+// ParseMoney reads a decimal amount such as "12.5" exactly
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if whole == "" || len(fraction) > 2 || strings.ContainsAny(whole+fraction, "+-eE") {
+		return 0, ErrInvalidAmount
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	var cents int64
+	if fraction != "" {
+		if cents, err = strconv.ParseInt(fraction+strings.Repeat("0", 2-len(fraction)), 10, 64); err != nil {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	amount := Money(units*100 + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// MoneyFromFloat rounds a float amount in major units to the nearest cent,
+// for settings that are read as floats such as the platform's fixed fee
+func MoneyFromFloat(amount float64) Money {
+	return Money(math.Round(amount * 100))
+}
+
+// Percent is rate percent of the amount, rounded to the nearest cent
+func (m Money) Percent(rate float64) Money {
+	return Money(math.Round(float64(m) * rate / 100))
+}
+
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+// Format writes the amount for people to read, e.g. A$12.50
+func (m Money) Format(currency string) string {
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		return m.String() + " " + currency
+	}
+	if m < 0 {
+		return "-" + symbol + (-m).String()
+	}
+	return symbol + m.String()
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Scan reads amounts from columns sqlite may hand back as floats, such as
+// those that held dollar amounts before they were converted
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		*m = Money(math.Round(v))
+	case []byte:
+		return m.Scan(string(v))
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("can't read %q as an amount: %w", v, err)
+		}
+		*m = Money(math.Round(f))
+	default:
+		return fmt.Errorf("can't read %T as an amount", value)
+	}
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
@@ -11,6 +11,10 @@ const (
 	NotificationTypeRegistration NotificationType = "registration"
 	NotificationTypePayment NotificationType = "payment"
 	NotificationTypeReminder NotificationType = "reminder"
+	NotificationTypeHold NotificationType = "hold"
+	NotificationTypeWaitlist NotificationType = "waitlist"
+	NotificationTypeTransfer NotificationType = "transfer"
+	NotificationTypeCertificate NotificationType = "certificate"
 )
 
 type Notification struct {
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPending  OrderStatus = "pending"
+	OrderStatusPaid     OrderStatus = "paid"
+	OrderStatusCanceled OrderStatus = "canceled"
+)
+
+var ErrOrderPaid = errors.New("Paid orders can't be canceled, ask the organizer for a refund")
+
+// Order is one checkout. Every ticket bought in it is a Registration with the
+// order's id, held by the buyer and optionally naming someone else as the
+// attendee.
+type Order struct {
+	Base
+	UserID     uint        `gorm:"not null;index" json:"user_id"`
+	EventID    uint        `gorm:"not null;index" json:"event_id"`
+	Status     OrderStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	TotalPrice Money       `gorm:"not null;default:0" json:"total_price"`
+	// the discount is recorded on each ticket, this is their sum
+	PromoCodeID    *uint  `json:"promo_code_id,omitempty"`
+	DiscountAmount Money  `gorm:"not null;default:0" json:"discount_amount"`
+	AddOnAmount    Money  `gorm:"not null;default:0" json:"add_on_amount"`
+	FeeAmount      Money  `gorm:"not null;default:0" json:"fee_amount"`
+	TaxAmount      Money  `gorm:"not null;default:0" json:"tax_amount"`
+	AmountDue      Money  `gorm:"not null;default:0" json:"amount_due"`
+	Currency       string `gorm:"type:varchar(3);not null;default:'AUD'" json:"currency"`
+
+	// loaded with LoadTickets, registrations is a raw SQL table so gorm
+	// mustn't try to migrate a relation to it
+	Tickets []Registration `gorm:"-" json:"tickets,omitempty"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+func FindOrderByID(db *gorm.DB, id uint) (*Order, error) {
+	var order Order
+	result := db.First(&order, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &order, nil
+}
+
+func (o *Order) LoadTickets(db *gorm.DB) error {
+	return db.Preload("TicketType").Where("order_id = ?", o.ID).Order("id").Find(&o.Tickets).Error
+}
+
+// ActiveTickets returns the tickets of the order that haven't been canceled
+func (o *Order) ActiveTickets(db *gorm.DB) ([]Registration, error) {
+	var tickets []Registration
+	result := db.Where("order_id = ? AND status != ?", o.ID, RegistrationStatusCanceled).Order("id").Find(&tickets)
+	return tickets, result.Error
+}
+
+// Cancel cancels the order and every ticket in it, releasing their seats.
+// Paid orders give ErrOrderPaid, their money has to be refunded instead.
+func (o *Order) Cancel(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// it may have been paid for since it was loaded
+		var status OrderStatus
+		if err := tx.Model(&Order{}).Where("id = ?", o.ID).Select("status").Scan(&status).Error; err != nil {
+			return err
+		}
+		if status == OrderStatusPaid {
+			return ErrOrderPaid
+		}
+
+		tickets, err := o.ActiveTickets(tx)
+		if err != nil {
+			return err
+		}
+		for i := range tickets {
+			// a ticket transferred to someone else is theirs to cancel
+			if tickets[i].UserID != o.UserID {
+				continue
+			}
+			if err := tickets[i].Cancel(tx); err != nil {
+				return err
+			}
+		}
+		o.Status = OrderStatusCanceled
+		return tx.Save(o).Error
+	})
+}
@@ -28,11 +28,21 @@ const (
 type Payment struct {
 	Base
 	RegistrationID uint          `json:"registration_id"`
-	Amount         float64       `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Amount         Money         `gorm:"not null" json:"amount"`
 	Status         PaymentStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	Method         PaymentMethod `gorm:"type:varchar(20)" json:"method"`
 	TransactionID  string        `gorm:"type:varchar(255)" json:"transaction_id"`
 	PaymentDate    *time.Time    `json:"payment_date,omitempty"`
+	// payments for an order cover all of its tickets, RegistrationID is then
+	// just the order's first ticket, so find a ticket's payments with
+	// Registration.GetPayments which also matches on the order
+	OrderID *uint `gorm:"index" json:"order_id,omitempty"`
+	// how Amount is made up, copied from the tickets when the payment is taken
+	Subtotal    Money  `gorm:"not null;default:0" json:"subtotal"`
+	AddOnAmount Money  `gorm:"not null;default:0" json:"add_on_amount"`
+	FeeAmount   Money  `gorm:"not null;default:0" json:"fee_amount"`
+	TaxAmount   Money  `gorm:"not null;default:0" json:"tax_amount"`
+	Currency    string `gorm:"type:varchar(3);not null;default:'AUD'" json:"currency"`
 
 	Registration Registration `gorm:"foreignKey:RegistrationID" json:"-"`
 }
@@ -43,6 +53,9 @@ func (Payment) TableName() string {
 }
 
 
+// Process completes the payment and confirms what it pays for. The tickets
+// are only confirmed while still pending, so a hold that lapsed after the
+// caller checked it gives ErrHoldExpired and the payment is marked failed.
 func (p *Payment) Process(db *gorm.DB) error {
 	p.Status = PaymentStatusCompleted
 	now := time.Now()
@@ -53,20 +66,49 @@ func (p *Payment) Process(db *gorm.DB) error {
 			return err
 		}
 
-		var registration Registration
-		if err := tx.First(&registration, p.RegistrationID).Error; err != nil {
-			return err
+		return p.confirmTickets(tx)
+	})
+	if errors.Is(err, ErrHoldExpired) {
+		p.Status = PaymentStatusFailed
+		p.PaymentDate = nil
+		if updateErr := db.Model(p).Updates(map[string]interface{}{"status": p.Status, "payment_date": nil}).Error; updateErr != nil {
+			return updateErr
 		}
+	}
 
-		registration.Status = RegistrationStatusConfirmed
-		if err := tx.Save(&registration).Error; err != nil {
-			return err
-		}
+	return err
+}
 
+// confirmTickets confirms the paid for registration, or the paid for order
+// and its tickets, with updates that only apply while they're still pending
+func (p *Payment) confirmTickets(tx *gorm.DB) error {
+	confirm := map[string]interface{}{"status": RegistrationStatusConfirmed, "expires_at": nil}
+	if p.OrderID != nil {
+		result := tx.Model(&Order{}).Where("id = ? AND status = ?", *p.OrderID, OrderStatusPending).Update("status", OrderStatusPaid)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrHoldExpired
+		}
+		result = tx.Model(&Registration{}).Where("order_id = ? AND status = ?", *p.OrderID, RegistrationStatusPending).Updates(confirm)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrHoldExpired
+		}
 		return nil
-	})
+	}
 
-	return err
+	result := tx.Model(&Registration{}).Where("id = ? AND status = ?", p.RegistrationID, RegistrationStatusPending).Updates(confirm)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrHoldExpired
+	}
+	return nil
 }
 
 func (p *Payment) Refund(db *gorm.DB) error {
@@ -82,20 +124,38 @@ func (p *Payment) Refund(db *gorm.DB) error {
 			return err
 		}
 
+		return p.setTicketStatus(tx, RegistrationStatusCanceled, OrderStatusCanceled)
+	})
+
+	return err
+}
+
+// setTicketStatus moves the refunded registration, or every ticket of the
+// refunded order, to the given status
+func (p *Payment) setTicketStatus(tx *gorm.DB, status RegistrationStatus, orderStatus OrderStatus) error {
+	var registrations []Registration
+	if p.OrderID != nil {
+		if err := tx.Where("order_id = ? AND status != ?", *p.OrderID, RegistrationStatusCanceled).Find(&registrations).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Order{}).Where("id = ?", *p.OrderID).Update("status", orderStatus).Error; err != nil {
+			return err
+		}
+	} else {
 		var registration Registration
 		if err := tx.First(&registration, p.RegistrationID).Error; err != nil {
 			return err
 		}
+		registrations = append(registrations, registration)
+	}
 
-		registration.Status = RegistrationStatusCanceled
-		if err := tx.Save(&registration).Error; err != nil {
+	for i := range registrations {
+		registrations[i].Status = status
+		if err := tx.Save(&registrations[i]).Error; err != nil {
 			return err
 		}
-
-		return nil
-	})
-
-	return err
+	}
+	return nil
 }
 
 func FindPaymentByID(db *gorm.DB, id uint) (*Payment, error) {
@@ -107,16 +167,25 @@ func FindPaymentByID(db *gorm.DB, id uint) (*Payment, error) {
 	return &payment, nil
 }
 
+// FindPaymentsByRegistration finds the payments for a ticket, including the
+// payment for its order
 func FindPaymentsByRegistration(db *gorm.DB, registrationID uint) ([]Payment, error) {
-	var payments []Payment
-	result := db.Where("registration_id = ?", registrationID).Order("created_at DESC").Find(&payments)
-	return payments, result.Error
+	registration, err := FindRegistrationByID(db, registrationID)
+	if err != nil {
+		return nil, err
+	}
+	return registration.GetPayments(db)
 }
 
-func CreatePayment(db *gorm.DB, registrationID uint, amount float64, method PaymentMethod, transactionID string) (*Payment, error) {
+func CreatePayment(db *gorm.DB, registrationID uint, breakdown PriceBreakdown, method PaymentMethod, transactionID string) (*Payment, error) {
 	payment := Payment{
 		RegistrationID: registrationID,
-		Amount:         amount,
+		Amount:         breakdown.Total,
+		Subtotal:       breakdown.Subtotal,
+		AddOnAmount:    breakdown.AddOns,
+		FeeAmount:      breakdown.Fees,
+		TaxAmount:      breakdown.Tax,
+		Currency:       breakdown.Currency,
 		Status:         PaymentStatusPending,
 		Method:         method,
 		TransactionID:  transactionID,
@@ -0,0 +1,119 @@
+package models_test
+
+import (
+	"errors"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"testing"
+)
+
+// a hold the sweeper releases between the handler's check and Process must
+// not be confirmed, and the payment for it must not be left completed
+func TestProcessAfterHoldReleased(t *testing.T) {
+	db := openTestDB(t)
+	event, ticketType := createTicketType(t, db, 1)
+	users := createUsers(t, db, 1)
+
+	registration, err := models.CreateRegistration(db, users[0].ID, event.ID, ticketType.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := models.CreatePayment(db, registration.ID, registration.Breakdown(), models.PaymentMethodCreditCard, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registration.Cancel(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := payment.Process(db); !errors.Is(err, models.ErrHoldExpired) {
+		t.Fatalf("Process returned %v, want ErrHoldExpired", err)
+	}
+
+	stored, err := models.FindPaymentByID(db, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.PaymentStatusFailed {
+		t.Errorf("payment status = %s, want failed", stored.Status)
+	}
+	current, err := models.FindRegistrationByID(db, registration.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != models.RegistrationStatusCanceled {
+		t.Errorf("registration status = %s, want canceled", current.Status)
+	}
+	if sold, _ := soldAndRegistered(t, db, ticketType.ID); sold != 0 {
+		t.Errorf("quantity_sold = %d, want the released ticket to stay released", sold)
+	}
+}
+
+func TestProcessOrderAfterHoldReleased(t *testing.T) {
+	db := openTestDB(t)
+	event, ticketType := createTicketType(t, db, 2)
+	users := createUsers(t, db, 1)
+
+	order := models.Order{UserID: users[0].ID, EventID: event.ID, Status: models.OrderStatusPending}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	registration := models.Registration{UserID: users[0].ID, EventID: event.ID, TicketTypeID: ticketType.ID, OrderID: &order.ID}
+	if err := db.Create(&registration).Error; err != nil {
+		t.Fatal(err)
+	}
+	payment := models.Payment{RegistrationID: registration.ID, OrderID: &order.ID, Status: models.PaymentStatusPending, Method: models.PaymentMethodCreditCard}
+	if err := db.Create(&payment).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := order.Cancel(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := payment.Process(db); !errors.Is(err, models.ErrHoldExpired) {
+		t.Fatalf("Process returned %v, want ErrHoldExpired", err)
+	}
+
+	current, err := models.FindOrderByID(db, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != models.OrderStatusCanceled {
+		t.Errorf("order status = %s, want canceled", current.Status)
+	}
+	stored, err := models.FindPaymentByID(db, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.PaymentStatusFailed {
+		t.Errorf("payment status = %s, want failed", stored.Status)
+	}
+}
+
+func TestProcessConfirmsPendingTickets(t *testing.T) {
+	db := openTestDB(t)
+	event, ticketType := createTicketType(t, db, 1)
+	users := createUsers(t, db, 1)
+
+	registration, err := models.CreateRegistration(db, users[0].ID, event.ID, ticketType.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := models.CreatePayment(db, registration.ID, registration.Breakdown(), models.PaymentMethodCreditCard, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := payment.Process(db); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := models.FindRegistrationByID(db, registration.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != models.RegistrationStatusConfirmed || current.ExpiresAt != nil {
+		t.Errorf("registration status = %s expiring %v, want confirmed with no expiry", current.Status, current.ExpiresAt)
+	}
+	if sold, _ := soldAndRegistered(t, db, ticketType.ID); sold != 1 {
+		t.Errorf("quantity_sold = %d, want 1", sold)
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PriceTier is a price a ticket type sells at for a while, e.g. early bird
+// until a date or the first 100 tickets. Tiers are checked in order and the
+// first one that applies sets the price; when none do the ticket type's own
+// Price is used.
+type PriceTier struct {
+	Base
+	TicketTypeID uint       `gorm:"not null;index" json:"ticket_type_id"`
+	Name         string     `gorm:"type:varchar(100);not null" json:"name"`
+	Price        Money      `gorm:"not null;default:0" json:"price"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	// the tier stops applying once this many tickets of the type are sold
+	MaxSold  *int `json:"max_sold,omitempty"`
+	Position int  `gorm:"not null;default:0" json:"position"`
+}
+
+func (PriceTier) TableName() string {
+	return "price_tiers"
+}
+
+// PriceChange is when and to what a ticket type's price changes next. A tier
+// capped by tickets sold reports how many are left at its price, otherwise
+// the date the price changes is given.
+type PriceChange struct {
+	Price       Money      `json:"price"`
+	Tier        string     `json:"tier,omitempty"`
+	At          *time.Time `json:"at,omitempty"`
+	TicketsLeft *int       `json:"tickets_left,omitempty"`
+}
+
+func (p *PriceTier) appliesAt(sold int, at time.Time) bool {
+	if p.StartsAt != nil && at.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !at.Before(*p.EndsAt) {
+		return false
+	}
+	return p.MaxSold == nil || sold < *p.MaxSold
+}
+
+// CheckPriceTiers reports what's wrong with tiers an organizer is saving
+func CheckPriceTiers(tiers []PriceTier) error {
+	for _, tier := range tiers {
+		if tier.Name == "" {
+			return errors.New("Every price tier needs a name")
+		}
+		if tier.Price < 0 {
+			return errors.New("Price tier prices cannot be negative")
+		}
+		if tier.StartsAt != nil && tier.EndsAt != nil && !tier.EndsAt.After(*tier.StartsAt) {
+			return errors.New("Price tier end must be after its start")
+		}
+		if tier.MaxSold != nil && *tier.MaxSold < 1 {
+			return errors.New("Price tier max_sold must be at least 1")
+		}
+	}
+	return nil
+}
+
+// PriceAt works out what a ticket costs when sold tickets of the type have
+// already gone, and the tier that price comes from if any
+func (t *TicketType) PriceAt(tiers []PriceTier, sold int, at time.Time) (Money, *PriceTier) {
+	for i := range tiers {
+		if tiers[i].appliesAt(sold, at) {
+			return tiers[i].Price, &tiers[i]
+		}
+	}
+	return t.Price, nil
+}
+
+// NextPriceChange finds the next time the price moves away from what it is
+// now, or nil if it stays the same
+func (t *TicketType) NextPriceChange(tiers []PriceTier, sold int, now time.Time) *PriceChange {
+	current, tier := t.PriceAt(tiers, sold, now)
+	if tier != nil && tier.MaxSold != nil {
+		left := *tier.MaxSold - sold
+		price, next := t.PriceAt(tiers, *tier.MaxSold, now)
+		return &PriceChange{Price: price, Tier: tierName(next), TicketsLeft: &left}
+	}
+
+	var dates []time.Time
+	for _, tier := range tiers {
+		for _, date := range []*time.Time{tier.StartsAt, tier.EndsAt} {
+			if date != nil && date.After(now) {
+				dates = append(dates, *date)
+			}
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	for _, date := range dates {
+		price, next := t.PriceAt(tiers, sold, date)
+		if next != tier || price != current {
+			at := date
+			return &PriceChange{Price: price, Tier: tierName(next), At: &at}
+		}
+	}
+	return nil
+}
+
+func tierName(tier *PriceTier) string {
+	if tier == nil {
+		return ""
+	}
+	return tier.Name
+}
+
+func FindPriceTiers(db *gorm.DB, ticketTypeID uint) ([]PriceTier, error) {
+	var tiers []PriceTier
+	result := db.Where("ticket_type_id = ?", ticketTypeID).Order("position, id").Find(&tiers)
+	return tiers, result.Error
+}
+
+// FindPriceTiersByTicketTypes loads the tiers of several ticket types at once
+func FindPriceTiersByTicketTypes(db *gorm.DB, ticketTypeIDs []uint) (map[uint][]PriceTier, error) {
+	var tiers []PriceTier
+	if err := db.Where("ticket_type_id IN ?", ticketTypeIDs).Order("position, id").Find(&tiers).Error; err != nil {
+		return nil, err
+	}
+	byTicketType := make(map[uint][]PriceTier, len(ticketTypeIDs))
+	for _, tier := range tiers {
+		byTicketType[tier.TicketTypeID] = append(byTicketType[tier.TicketTypeID], tier)
+	}
+	return byTicketType, nil
+}
+
+// ReplacePriceTiers swaps the ticket type's tiers for the given ones, in the
+// order given. Registrations keep the name of the tier they were sold at.
+func ReplacePriceTiers(tx *gorm.DB, ticketTypeID uint, tiers []PriceTier) error {
+	if err := tx.Unscoped().Where("ticket_type_id = ?", ticketTypeID).Delete(&PriceTier{}).Error; err != nil {
+		return err
+	}
+	if len(tiers) == 0 {
+		return nil
+	}
+	for i := range tiers {
+		tiers[i].ID = 0
+		tiers[i].TicketTypeID = ticketTypeID
+		tiers[i].Position = i
+	}
+	return tx.Create(&tiers).Error
+}
+
+// CurrentPrice is what a ticket of the type costs right now
+func (t *TicketType) CurrentPrice(db *gorm.DB) (Money, *PriceTier, error) {
+	tiers, err := FindPriceTiers(db, t.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := t.GetAvailableQuantity(db); err != nil {
+		return 0, nil, err
+	}
+	price, tier := t.PriceAt(tiers, t.QuantitySold, time.Now())
+	return price, tier, nil
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TaxRate is the tax charged on tickets for events in a country, or in one
+// state of it. A state's own rate is used instead of its country's.
+type TaxRate struct {
+	Base
+	Country string `gorm:"type:varchar(100);not null;uniqueIndex:idx_tax_rate_region" json:"country"`
+	// empty for the rate that covers the whole country
+	State string `gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_tax_rate_region" json:"state"`
+	// shown on the breakdown and receipts, e.g. GST
+	Name string  `gorm:"type:varchar(50);not null" json:"name"`
+	Rate float64 `gorm:"not null" json:"rate"`
+	// prices already include the tax, the breakdown just shows how much of
+	// the total it is
+	Inclusive bool `gorm:"default:false" json:"inclusive"`
+}
+
+func (TaxRate) TableName() string {
+	return "tax_rates"
+}
+
+func (t *TaxRate) Check() error {
+	if strings.TrimSpace(t.Country) == "" || strings.TrimSpace(t.Name) == "" {
+		return errors.New("Country and name are required")
+	}
+	if t.Rate <= 0 || t.Rate > 100 {
+		return errors.New("Rate must be a percentage above 0 and at most 100")
+	}
+	return nil
+}
+
+// FindTaxRate finds the rate for an event's location, nil if no tax is set up
+// for it
+func FindTaxRate(db *gorm.DB, country, state string) (*TaxRate, error) {
+	country, state = strings.TrimSpace(country), strings.TrimSpace(state)
+	if country == "" {
+		return nil, nil
+	}
+
+	var rates []TaxRate
+	err := db.Where("LOWER(country) = LOWER(?) AND (state = '' OR LOWER(state) = LOWER(?))", country, state).
+		Order("state DESC").Limit(1).Find(&rates).Error
+	if err != nil || len(rates) == 0 {
+		return nil, err
+	}
+	return &rates[0], nil
+}
+
+// FeeSchedule is what the platform takes from each paid ticket
+type FeeSchedule struct {
+	Percent float64
+	Fixed   Money
+}
+
+// PlatformFees is set from the config when the server starts
+var PlatformFees FeeSchedule
+
+// PriceBreakdown is how a ticket's or order's total is made up
+type PriceBreakdown struct {
+	Currency string `json:"currency"`
+	// the ticket price after any discount
+	Subtotal     Money  `json:"subtotal"`
+	Discount     Money  `json:"discount"`
+	AddOns       Money  `json:"add_ons"`
+	Fees         Money  `json:"fees"`
+	Tax          Money  `json:"tax"`
+	TaxName      string `json:"tax_name,omitempty"`
+	TaxInclusive bool   `json:"tax_inclusive"`
+	Total        Money  `json:"total"`
+}
+
+// Add totals up the breakdowns of tickets paid for together
+func (b *PriceBreakdown) Add(other PriceBreakdown) {
+	b.Subtotal += other.Subtotal
+	b.Discount += other.Discount
+	b.AddOns += other.AddOns
+	b.Fees += other.Fees
+	b.Tax += other.Tax
+	b.Total += other.Total
+	if b.Currency == "" {
+		b.Currency = other.Currency
+	}
+	if b.TaxName == "" {
+		b.TaxName = other.TaxName
+		b.TaxInclusive = other.TaxInclusive
+	}
+}
+
+// OrderBreakdown adds up the breakdowns of the tickets
+func OrderBreakdown(tickets []Registration) PriceBreakdown {
+	var breakdown PriceBreakdown
+	for i := range tickets {
+		breakdown.Add(tickets[i].Breakdown())
+	}
+	return breakdown
+}
+
+func (r *Registration) Breakdown() PriceBreakdown {
+	return PriceBreakdown{
+		Currency:     r.Currency,
+		Subtotal:     r.TotalPrice,
+		Discount:     r.DiscountAmount,
+		AddOns:       r.AddOnAmount,
+		Fees:         r.FeeAmount,
+		Tax:          r.TaxAmount,
+		TaxName:      r.TaxName,
+		TaxInclusive: r.TaxInclusive,
+		Total:        r.AmountDue,
+	}
+}
+
+// applyCharges works out the fees and tax on the registration's price and
+// add-ons, and what the buyer has to pay. Free tickets have neither.
+func (r *Registration) applyCharges(tx *gorm.DB) error {
+	var event Event
+	err := tx.Session(&gorm.Session{NewDB: true}).Select("id", "country", "state", "currency", "booking_fee", "absorb_fees").First(&event, r.EventID).Error
+	if err != nil {
+		return err
+	}
+	rate, err := FindTaxRate(tx.Session(&gorm.Session{NewDB: true}), event.Country, event.State)
+	if err != nil {
+		return err
+	}
+
+	r.Currency = event.Currency
+	r.FeeAmount, r.AbsorbedFee, r.TaxAmount = 0, 0, 0
+	r.TaxName, r.TaxInclusive = "", false
+	charged := r.TotalPrice + r.AddOnAmount
+	if charged > 0 {
+		platformFee := charged.Percent(PlatformFees.Percent) + PlatformFees.Fixed
+		if event.AbsorbFees {
+			r.AbsorbedFee = platformFee
+		} else {
+			r.FeeAmount = platformFee
+		}
+		r.FeeAmount += event.BookingFee
+
+		if rate != nil {
+			taxed := charged + r.FeeAmount
+			r.TaxName, r.TaxInclusive = rate.Name, rate.Inclusive
+			if rate.Inclusive {
+				r.TaxAmount = taxed.Percent(100 * rate.Rate / (100 + rate.Rate))
+			} else {
+				r.TaxAmount = taxed.Percent(rate.Rate)
+			}
+		}
+	}
+
+	r.AmountDue = charged + r.FeeAmount
+	if !r.TaxInclusive {
+		r.AmountDue += r.TaxAmount
+	}
+	return nil
+}
+
+// chargeColumns are the columns applyCharges sets, for saving them with Updates
+func (r *Registration) chargeColumns() map[string]interface{} {
+	return map[string]interface{}{
+		"currency":      r.Currency,
+		"fee_amount":    r.FeeAmount,
+		"absorbed_fee":  r.AbsorbedFee,
+		"tax_amount":    r.TaxAmount,
+		"tax_name":      r.TaxName,
+		"tax_inclusive": r.TaxInclusive,
+		"amount_due":    r.AmountDue,
+	}
+}
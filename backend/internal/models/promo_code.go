@@ -0,0 +1,237 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DiscountType string
+
+const (
+	DiscountTypePercentage DiscountType = "percentage"
+	// a fixed amount off each ticket
+	DiscountTypeFixed DiscountType = "fixed"
+)
+
+var (
+	ErrPromoCodeNotStarted    = errors.New("this promo code isn't valid yet")
+	ErrPromoCodeExpired       = errors.New("this promo code has expired")
+	ErrPromoCodeUsedUp        = errors.New("this promo code has been used the maximum number of times")
+	ErrPromoCodeUserLimit     = errors.New("you've already used this promo code the maximum number of times")
+	ErrPromoCodeTicketType    = errors.New("this promo code can't be used for this ticket type")
+	ErrPromoCodeMinQuantity   = errors.New("not enough tickets for this promo code")
+	ErrInvalidPromoCodeAmount = errors.New("Discount must be above 0, and at most 100 for a percentage or whole cents for a fixed amount")
+)
+
+// PromoCode takes money off tickets of an event. Every discounted ticket
+// counts as one use.
+type PromoCode struct {
+	Base
+	EventID      uint         `gorm:"not null;uniqueIndex:idx_promo_code_event_code" json:"event_id"`
+	Code         string       `gorm:"type:varchar(50);not null;uniqueIndex:idx_promo_code_event_code" json:"code"`
+	Description  string       `gorm:"type:varchar(255)" json:"description"`
+	DiscountType DiscountType `gorm:"type:varchar(20);not null" json:"discount_type"`
+	// a percentage, or an amount in the event's currency for a fixed discount
+	DiscountValue  float64 `gorm:"not null" json:"discount_value"`
+	MaxUses        *int    `json:"max_uses"`
+	MaxUsesPerUser *int    `json:"max_uses_per_user"`
+	// tickets of the code's ticket types needed in one purchase
+	MinQuantity int        `gorm:"not null;default:1" json:"min_quantity"`
+	StartsAt    *time.Time `json:"starts_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	// codes generated together, e.g. for one partner, share a batch name
+	Batch string `gorm:"type:varchar(100);index" json:"batch,omitempty"`
+
+	// no ticket types means the code works for all of them
+	TicketTypeIDs []uint `gorm:"-" json:"ticket_type_ids"`
+	Uses          int64  `gorm:"-" json:"uses"`
+	DiscountGiven Money  `gorm:"-" json:"discount_given"`
+}
+
+func (PromoCode) TableName() string {
+	return "promo_codes"
+}
+
+// PromoCodeTicketType limits a promo code to a ticket type
+type PromoCodeTicketType struct {
+	PromoCodeID  uint `gorm:"primaryKey"`
+	TicketTypeID uint `gorm:"primaryKey;index"`
+}
+
+func (PromoCodeTicketType) TableName() string {
+	return "promo_code_ticket_types"
+}
+
+// CheckDefinition reports what's wrong with a code an organizer is saving
+func (p *PromoCode) CheckDefinition() error {
+	if p.DiscountType != DiscountTypePercentage && p.DiscountType != DiscountTypeFixed {
+		return errors.New("Discount type must be 'percentage' or 'fixed'")
+	}
+	if p.DiscountValue <= 0 || (p.DiscountType == DiscountTypePercentage && p.DiscountValue > 100) ||
+		(p.DiscountType == DiscountTypeFixed && math.Abs(p.DiscountValue*100-math.Round(p.DiscountValue*100)) > 1e-6) {
+		return ErrInvalidPromoCodeAmount
+	}
+	if p.MinQuantity < 1 {
+		return errors.New("Minimum quantity must be at least 1")
+	}
+	if p.MaxUses != nil && *p.MaxUses < 1 {
+		return errors.New("Max uses must be at least 1")
+	}
+	if p.MaxUsesPerUser != nil && *p.MaxUsesPerUser < 1 {
+		return errors.New("Max uses per user must be at least 1")
+	}
+	if p.StartsAt != nil && p.ExpiresAt != nil && p.ExpiresAt.Before(*p.StartsAt) {
+		return errors.New("Expiry must be after the start")
+	}
+	return nil
+}
+
+func (p *PromoCode) AppliesTo(ticketTypeID uint) bool {
+	if len(p.TicketTypeIDs) == 0 {
+		return true
+	}
+	for _, id := range p.TicketTypeIDs {
+		if id == ticketTypeID {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountFor works out how much comes off one ticket at the given price
+func (p *PromoCode) DiscountFor(price Money) Money {
+	discount := MoneyFromFloat(p.DiscountValue)
+	if p.DiscountType == DiscountTypePercentage {
+		discount = price.Percent(p.DiscountValue)
+	}
+	if discount > price {
+		return price
+	}
+	return discount
+}
+
+// CheckUsable reports why the code can't discount quantity more tickets for
+// the user. Uses are recounted with db so it can be called inside the
+// transaction that records them.
+func (p *PromoCode) CheckUsable(db *gorm.DB, userID uint, quantity int) error {
+	now := time.Now()
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return ErrPromoCodeNotStarted
+	}
+	if p.ExpiresAt != nil && now.After(*p.ExpiresAt) {
+		return ErrPromoCodeExpired
+	}
+	if quantity < p.MinQuantity {
+		return fmt.Errorf("%w, it needs at least %d", ErrPromoCodeMinQuantity, p.MinQuantity)
+	}
+
+	if p.MaxUses != nil {
+		var uses int64
+		if err := db.Model(&Registration{}).Where("promo_code_id = ? AND status != ?", p.ID, RegistrationStatusCanceled).Count(&uses).Error; err != nil {
+			return err
+		}
+		if uses+int64(quantity) > int64(*p.MaxUses) {
+			return ErrPromoCodeUsedUp
+		}
+	}
+	if p.MaxUsesPerUser != nil {
+		var uses int64
+		if err := db.Model(&Registration{}).Where("promo_code_id = ? AND user_id = ? AND status != ?", p.ID, userID, RegistrationStatusCanceled).Count(&uses).Error; err != nil {
+			return err
+		}
+		if uses+int64(quantity) > int64(*p.MaxUsesPerUser) {
+			return ErrPromoCodeUserLimit
+		}
+	}
+	return nil
+}
+
+// IsPromoCodeError reports whether err is a reason a buyer can't use a code
+func IsPromoCodeError(err error) bool {
+	for _, target := range []error{ErrPromoCodeNotStarted, ErrPromoCodeExpired, ErrPromoCodeUsedUp,
+		ErrPromoCodeUserLimit, ErrPromoCodeTicketType, ErrPromoCodeMinQuantity} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPromoCode takes the code's discount off a registration that has
+// already been created at full price
+func (r *Registration) ApplyPromoCode(tx *gorm.DB, promo *PromoCode) error {
+	discount := promo.DiscountFor(r.TotalPrice)
+	r.PromoCodeID = &promo.ID
+	r.DiscountAmount = discount
+	r.TotalPrice -= discount
+	if err := r.applyCharges(tx); err != nil {
+		return err
+	}
+
+	columns := r.chargeColumns()
+	columns["promo_code_id"] = promo.ID
+	columns["discount_amount"] = r.DiscountAmount
+	columns["total_price"] = r.TotalPrice
+	return tx.Model(r).Updates(columns).Error
+}
+
+func FindPromoCode(db *gorm.DB, eventID uint, code string) (*PromoCode, error) {
+	var promo PromoCode
+	result := db.Where("event_id = ? AND code = ?", eventID, NormalizeAccessCode(code)).First(&promo)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if err := LoadPromoCodeDetails(db, []*PromoCode{&promo}); err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// LoadPromoCodeDetails fills in the ticket types, uses and total discount given
+func LoadPromoCodeDetails(db *gorm.DB, codes []*PromoCode) error {
+	for _, code := range codes {
+		code.TicketTypeIDs = []uint{}
+		if err := db.Model(&PromoCodeTicketType{}).Where("promo_code_id = ?", code.ID).Pluck("ticket_type_id", &code.TicketTypeIDs).Error; err != nil {
+			return err
+		}
+		var usage struct {
+			Uses          int64
+			DiscountGiven Money
+		}
+		err := db.Model(&Registration{}).
+			Select("COUNT(*) AS uses, COALESCE(SUM(discount_amount), 0) AS discount_given").
+			Where("promo_code_id = ? AND status != ?", code.ID, RegistrationStatusCanceled).
+			Scan(&usage).Error
+		if err != nil {
+			return err
+		}
+		code.Uses = usage.Uses
+		code.DiscountGiven = usage.DiscountGiven
+	}
+	return nil
+}
+
+// no 0/O or 1/I so codes read out over the phone don't get mixed up
+const promoCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewPromoCode makes a random code, starting with prefix if there is one
+func NewPromoCode(prefix string, length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(promoCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = promoCodeAlphabet[n.Int64()]
+	}
+	if prefix != "" {
+		return NormalizeAccessCode(prefix) + "-" + string(code), nil
+	}
+	return string(code), nil
+}
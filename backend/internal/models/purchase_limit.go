@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurchaseLimitError says how a purchase goes over or under what one buyer is
+// allowed to hold
+type PurchaseLimitError struct {
+	Message string
+}
+
+func (e *PurchaseLimitError) Error() string {
+	return e.Message
+}
+
+func IsPurchaseLimitError(err error) bool {
+	var limitErr *PurchaseLimitError
+	return errors.As(err, &limitErr)
+}
+
+// Purchase is what one buyer is about to register for at an event
+type Purchase struct {
+	UserID uint
+	// tickets being bought of each ticket type
+	Quantities map[uint]int
+	// who the tickets are for, only used when the event limits by contact
+	AttendeeEmails []string
+}
+
+// CheckPurchaseLimitSettings reports what's wrong with a minimum and maximum
+// an organizer is setting
+func CheckPurchaseLimitSettings(min, max int) error {
+	if min < 0 || max < 0 {
+		return errors.New("Purchase limits cannot be negative")
+	}
+	if max > 0 && min > max {
+		return errors.New("The minimum per buyer cannot be more than the maximum")
+	}
+	return nil
+}
+
+// CheckPurchaseLimits makes sure the purchase keeps the buyer within the
+// event's and each ticket type's limits, counting the tickets they already
+// hold. It's run in the transaction that creates the tickets.
+func CheckPurchaseLimits(tx *gorm.DB, event *Event, purchase Purchase) error {
+	buying := 0
+	ids := make([]uint, 0, len(purchase.Quantities))
+	for id, quantity := range purchase.Quantities {
+		buying += quantity
+		ids = append(ids, id)
+	}
+	if buying == 0 {
+		return nil
+	}
+
+	var ticketTypes []TicketType
+	if err := tx.Where("id IN ?", ids).Find(&ticketTypes).Error; err != nil {
+		return err
+	}
+	limited := event.MinTicketsPerUser > 0 || event.MaxTicketsPerUser > 0
+	for _, ticketType := range ticketTypes {
+		limited = limited || ticketType.MinPerUser > 0 || ticketType.MaxPerUser > 0
+	}
+	if !limited {
+		return nil
+	}
+
+	held, err := heldTickets(tx, event, purchase)
+	if err != nil {
+		return err
+	}
+	heldTotal := 0
+	for _, count := range held {
+		heldTotal += count
+	}
+
+	for _, ticketType := range ticketTypes {
+		after := held[ticketType.ID] + purchase.Quantities[ticketType.ID]
+		if ticketType.MaxPerUser > 0 && after > ticketType.MaxPerUser {
+			return limitError(ticketType.MaxPerUser, held[ticketType.ID], ticketType.Name+" tickets")
+		}
+		if after < ticketType.MinPerUser {
+			return &PurchaseLimitError{fmt.Sprintf("%s tickets must be bought at least %d at a time", ticketType.Name, ticketType.MinPerUser-held[ticketType.ID])}
+		}
+	}
+
+	after := heldTotal + buying
+	if event.MaxTicketsPerUser > 0 && after > event.MaxTicketsPerUser {
+		return limitError(event.MaxTicketsPerUser, heldTotal, "tickets to this event")
+	}
+	if after < event.MinTicketsPerUser {
+		return &PurchaseLimitError{fmt.Sprintf("Tickets to this event must be bought at least %d at a time", event.MinTicketsPerUser-heldTotal)}
+	}
+	return nil
+}
+
+func limitError(max, held int, what string) error {
+	if held > 0 {
+		return &PurchaseLimitError{fmt.Sprintf("You can have at most %d %s and already have %d", max, what, held)}
+	}
+	return &PurchaseLimitError{fmt.Sprintf("You can have at most %d %s", max, what)}
+}
+
+// heldTickets counts the tickets of each ticket type the buyer already holds
+// at the event. When the event limits by contact that includes tickets for
+// the buyer's or any attendee's email, and tickets bought by accounts with
+// one of those emails or sharing a phone number with one of those accounts.
+func heldTickets(tx *gorm.DB, event *Event, purchase Purchase) (map[uint]int, error) {
+	query := tx.Model(&Registration{}).Where("event_id = ? AND status != ?", event.ID, RegistrationStatusCanceled)
+	if event.LimitByContact {
+		var buyer User
+		if err := tx.Select("id, email").First(&buyer, purchase.UserID).Error; err != nil {
+			return nil, err
+		}
+		emails := []string{NormalizeEmail(buyer.Email)}
+		for _, email := range purchase.AttendeeEmails {
+			if email != "" {
+				emails = append(emails, NormalizeEmail(email))
+			}
+		}
+		var phones []string
+		if err := tx.Model(&User{}).Where("LOWER(email) IN ? AND phone != ''", emails).Distinct().Pluck("phone", &phones).Error; err != nil {
+			return nil, err
+		}
+		accounts := tx.Model(&User{}).Select("id").Where("LOWER(email) IN ?", emails)
+		if len(phones) > 0 {
+			accounts = accounts.Or("phone IN ?", phones)
+		}
+		query = query.Where("(user_id IN (?) OR LOWER(attendee_email) IN ?)", accounts, emails)
+	} else {
+		query = query.Where("user_id = ?", purchase.UserID)
+	}
+
+	var rows []struct {
+		TicketTypeID uint
+		Count        int
+	}
+	if err := query.Select("ticket_type_id, COUNT(*) as count").Group("ticket_type_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	held := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		held[row.TicketTypeID] = row.Count
+	}
+	return held, nil
+}
+
+// FindPendingRegistration finds a registration the user already has waiting
+// to be paid for a ticket type, so a retried or double submitted request gets
+// back the one it already made
+func FindPendingRegistration(db *gorm.DB, userID, ticketTypeID uint) (*Registration, error) {
+	var registration Registration
+	result := db.Where("user_id = ? AND ticket_type_id = ? AND status = ? AND order_id IS NULL AND (expires_at IS NULL OR expires_at > ?)",
+		userID, ticketTypeID, RegistrationStatusPending, time.Now()).
+		Order("created_at DESC").First(&registration)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &registration, nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,13 +15,50 @@ const (
 	RegistrationStatusCanceled  RegistrationStatus = "canceled"
 )
 
+var ErrHoldExpired = errors.New("your hold on these tickets has expired")
+
 type Registration struct {
 	Base
 	UserID       uint               `json:"user_id"`
 	EventID      uint               `json:"event_id"`
 	TicketTypeID uint               `json:"ticket_type_id"`
-	TotalPrice   float64            `gorm:"type:decimal(10,2);not null" json:"total_price"`
+	TotalPrice   Money              `gorm:"not null" json:"total_price"`
 	Status       RegistrationStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	AccessCodeID *uint              `json:"access_code_id,omitempty"`
+	// TotalPrice is after the promo code's discount
+	PromoCodeID    *uint `json:"promo_code_id,omitempty"`
+	DiscountAmount Money `gorm:"not null;default:0" json:"discount_amount"`
+	// the price tier the ticket was sold at, the name is kept for reporting
+	// in case the tier is changed later
+	PriceTierID   *uint  `json:"price_tier_id,omitempty"`
+	PriceTierName string `gorm:"type:varchar(100)" json:"price_tier,omitempty"`
+	// set when the ticket was bought as part of a bundle, TotalPrice is then
+	// its share of the bundle's price
+	BundleID *uint `gorm:"index" json:"bundle_id,omitempty"`
+	// add-ons bought with the ticket, charged on top of TotalPrice
+	AddOnAmount Money               `gorm:"not null;default:0" json:"add_on_amount"`
+	AddOns      []RegistrationAddOn `gorm:"-" json:"add_ons,omitempty"`
+	// charges on top of TotalPrice and AddOnAmount, AmountDue is what the
+	// buyer pays. An absorbed fee comes out of the organizer's share instead.
+	FeeAmount    Money  `gorm:"not null;default:0" json:"fee_amount"`
+	AbsorbedFee  Money  `gorm:"not null;default:0" json:"absorbed_fee"`
+	TaxAmount    Money  `gorm:"not null;default:0" json:"tax_amount"`
+	TaxName      string `gorm:"type:varchar(50)" json:"tax_name,omitempty"`
+	TaxInclusive bool   `gorm:"default:false" json:"tax_inclusive"`
+	AmountDue    Money  `gorm:"not null;default:0" json:"amount_due"`
+	// the event's currency, which every amount above is in
+	Currency string `gorm:"type:varchar(3);not null;default:'AUD'" json:"currency"`
+	// set when the ticket was bought as part of an order, possibly for
+	// someone other than the buyer
+	OrderID       *uint  `gorm:"index" json:"order_id,omitempty"`
+	AttendeeName  string `gorm:"type:varchar(255)" json:"attendee_name,omitempty"`
+	AttendeeEmail string `gorm:"type:varchar(255)" json:"attendee_email,omitempty"`
+	// the ticket's secret, replaced when the ticket changes hands so the old
+	// holder's copy stops working
+	TicketCode string `gorm:"type:varchar(32);index" json:"-"`
+	// a pending registration is canceled if it isn't paid for by ExpiresAt
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	HoldReminderSentAt *time.Time `json:"-"`
 
 	// Relationships
 	User       User       `gorm:"foreignKey:UserID" json:"-"`
@@ -35,29 +73,123 @@ func (Registration) TableName() string {
 
 
 func (r *Registration) BeforeCreate(tx *gorm.DB) error {
+	if r.TicketCode == "" {
+		code, err := NewTicketCode()
+		if err != nil {
+			return err
+		}
+		r.TicketCode = code
+	}
+
 	var ticketType TicketType
 	if err := tx.First(&ticketType, r.TicketTypeID).Error; err != nil {
 		return err
 	}
 
-	availableQuantity, err := ticketType.GetAvailableQuantity(tx)
+	// Check if the ticket is on sale
+	if !ticketType.IsOnSale() {
+		return errors.New("tickets not on sale")
+	}
+
+	if r.Status == RegistrationStatusCanceled {
+		if err := r.setTierPrice(tx, &ticketType, ticketType.QuantitySold); err != nil {
+			return err
+		}
+		return r.applyCharges(tx)
+	}
+	if err := ReserveTicket(tx, r.TicketTypeID); err != nil {
+		return err
+	}
+	// priced after reserving so a quantity tier is decided by the ticket's
+	// own place in the sales
+	if _, err := ticketType.GetAvailableQuantity(tx); err != nil {
+		return err
+	}
+	if err := r.setTierPrice(tx, &ticketType, ticketType.QuantitySold-1); err != nil {
+		return err
+	}
+	if err := r.applyCharges(tx); err != nil {
+		return err
+	}
+
+	if r.Status == "" || r.Status == RegistrationStatusPending {
+		var holdMinutes int
+		if err := tx.Model(&Event{}).Where("id = ?", r.EventID).Select("hold_minutes").Scan(&holdMinutes).Error; err != nil {
+			return err
+		}
+		if holdMinutes > 0 {
+			expiresAt := time.Now().Add(time.Duration(holdMinutes) * time.Minute)
+			r.ExpiresAt = &expiresAt
+		}
+	}
+	return nil
+}
+
+// NewTicketCode makes the secret a ticket is checked with
+func NewTicketCode() (string, error) {
+	return NewShareToken()
+}
+
+// setTierPrice prices the registration at the tier that applies when sold
+// tickets have gone before it
+func (r *Registration) setTierPrice(tx *gorm.DB, ticketType *TicketType, sold int) error {
+	tiers, err := FindPriceTiers(tx, ticketType.ID)
 	if err != nil {
 		return err
 	}
+	price, tier := ticketType.PriceAt(tiers, sold, time.Now())
+	r.TotalPrice = price
+	if tier != nil {
+		r.PriceTierID = &tier.ID
+		r.PriceTierName = tier.Name
+	}
+	return nil
+}
 
-	if availableQuantity <= 0 {
-		return errors.New("no tickets available")
+// BeforeUpdate keeps the ticket type's and add-ons' sold counts in step when a
+// registration is canceled or brought back
+func (r *Registration) BeforeUpdate(tx *gorm.DB) error {
+	// nothing to compare against when only some columns are being updated
+	if r.ID == 0 || r.Status == "" {
+		return nil
+	}
+	if r.Status != RegistrationStatusPending {
+		r.ExpiresAt = nil
 	}
 
-	// Check if the ticket is on sale
-	if !ticketType.IsOnSale() {
-		return errors.New("tickets not on sale")
+	var old Registration
+	if err := tx.Session(&gorm.Session{NewDB: true}).Select("status", "ticket_type_id").First(&old, r.ID).Error; err != nil {
+		return err
 	}
-	r.TotalPrice = ticketType.Price
 
+	wasCanceled := old.Status == RegistrationStatusCanceled
+	isCanceled := r.Status == RegistrationStatusCanceled
+	switch {
+	case !wasCanceled && isCanceled:
+		if err := ReleaseTicket(tx, old.TicketTypeID); err != nil {
+			return err
+		}
+		return changeAddOnStock(tx, r.ID, true)
+	case wasCanceled && !isCanceled:
+		if err := ReserveTicket(tx, r.TicketTypeID); err != nil {
+			return err
+		}
+		return changeAddOnStock(tx, r.ID, false)
+	}
 	return nil
 }
 
+// AfterSave releases any seat and session places held by a registration once it is canceled
+func (r *Registration) AfterSave(tx *gorm.DB) error {
+	if r.Status != RegistrationStatusCanceled {
+		return nil
+	}
+	if err := tx.Where("registration_id = ?", r.ID).Delete(&SeatAssignment{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("registration_id = ?", r.ID).Delete(&SessionSignup{}).Error
+}
+
 func (r *Registration) Confirm(db *gorm.DB) error {
 	r.Status = RegistrationStatusConfirmed
 	return db.Save(r).Error
@@ -68,9 +200,63 @@ func (r *Registration) Cancel(db *gorm.DB) error {
 	return db.Save(r).Error
 }
 
+// HoldExpired reports whether the registration was left unpaid past its hold
+func (r *Registration) HoldExpired() bool {
+	return r.Status == RegistrationStatusPending && r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now())
+}
+
+// ExpireHold releases the tickets of an unpaid registration and reports
+// whether it did. Tickets bought in one order are held together, so the whole
+// order goes.
+func (r *Registration) ExpireHold(db *gorm.DB) (bool, error) {
+	expired := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// it may have been paid for since it was loaded
+		var current Registration
+		if err := tx.First(&current, r.ID).Error; err != nil {
+			return err
+		}
+		if !current.HoldExpired() {
+			return nil
+		}
+		expired = true
+
+		if current.OrderID != nil {
+			order, err := FindOrderByID(tx, *current.OrderID)
+			if err != nil {
+				return err
+			}
+			return order.Cancel(tx)
+		}
+		return current.Cancel(tx)
+	})
+	return expired && err == nil, err
+}
+
+// FindExpiredHolds finds pending registrations whose hold has run out
+func FindExpiredHolds(db *gorm.DB, now time.Time) ([]Registration, error) {
+	var registrations []Registration
+	result := db.Where("status = ? AND expires_at <= ?", RegistrationStatusPending, now).Order("expires_at").Find(&registrations)
+	return registrations, result.Error
+}
+
+// FindHoldsExpiringBefore finds pending registrations whose hold runs out
+// before t and whose holder hasn't been warned yet
+func FindHoldsExpiringBefore(db *gorm.DB, now, t time.Time) ([]Registration, error) {
+	var registrations []Registration
+	result := db.Where("status = ? AND expires_at > ? AND expires_at <= ? AND hold_reminder_sent_at IS NULL", RegistrationStatusPending, now, t).
+		Order("expires_at").Find(&registrations)
+	return registrations, result.Error
+}
+
+// GetPayments includes payments made for the whole order the registration is part of
 func (r *Registration) GetPayments(db *gorm.DB) ([]Payment, error) {
 	var payments []Payment
-	result := db.Where("registration_id = ?", r.ID).Find(&payments)
+	query := db.Where("registration_id = ?", r.ID)
+	if r.OrderID != nil {
+		query = db.Where("registration_id = ? OR order_id = ?", r.ID, *r.OrderID)
+	}
+	result := query.Order("created_at DESC").Find(&payments)
 	return payments, result.Error
 }
 
@@ -107,7 +293,6 @@ func CreateRegistration(db *gorm.DB, userID, eventID, ticketTypeID uint) (*Regis
 		UserID:       userID,
 		EventID:      eventID,
 		TicketTypeID: ticketTypeID,
-		TotalPrice:   ticketType.Price,
 		Status:       RegistrationStatusPending,
 	}
 
@@ -118,3 +303,12 @@ func CreateRegistration(db *gorm.DB, userID, eventID, ticketTypeID uint) (*Regis
 
 	return &registration, nil
 }
+
+// NameOnTicket is the attendee's name as printed on their ticket and badge,
+// the name they were registered under or else the account holder's
+func (r *Registration) NameOnTicket(user *User) string {
+	if r.AttendeeName != "" || user == nil {
+		return r.AttendeeName
+	}
+	return fullName(user)
+}
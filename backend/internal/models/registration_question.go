@@ -0,0 +1,278 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+type QuestionType string
+
+const (
+	QuestionTypeText     QuestionType = "text"
+	QuestionTypeChoice   QuestionType = "choice"
+	QuestionTypeCheckbox QuestionType = "checkbox"
+	QuestionTypeDietary  QuestionType = "dietary"
+	QuestionTypeTShirt   QuestionType = "tshirt_size"
+)
+
+const maxAnswerLength = 2000
+
+// options used when the organizer doesn't give their own
+var (
+	DefaultDietaryOptions = []string{"None", "Vegetarian", "Vegan", "Gluten free", "Dairy free", "Halal", "Kosher", "Nut allergy"}
+	DefaultTShirtSizes    = []string{"XS", "S", "M", "L", "XL", "XXL"}
+)
+
+func (t QuestionType) Valid() bool {
+	switch t {
+	case QuestionTypeText, QuestionTypeChoice, QuestionTypeCheckbox, QuestionTypeDietary, QuestionTypeTShirt:
+		return true
+	}
+	return false
+}
+
+// RegistrationQuestion is asked when registering for an event, either for
+// every ticket type or only for one of them
+type RegistrationQuestion struct {
+	Base
+	EventID      uint         `gorm:"not null;index" json:"event_id"`
+	TicketTypeID *uint        `gorm:"index" json:"ticket_type_id"`
+	Label        string       `gorm:"type:varchar(255);not null" json:"label"`
+	HelpText     string       `gorm:"type:varchar(500)" json:"help_text"`
+	Type         QuestionType `gorm:"type:varchar(20);not null" json:"type"`
+	Options      []string     `gorm:"type:text;serializer:json" json:"options"`
+	Required     bool         `gorm:"default:false" json:"required"`
+	// validation for text answers, zero values mean no limit
+	MinLength int    `json:"min_length"`
+	MaxLength int    `json:"max_length"`
+	Pattern   string `gorm:"type:varchar(255)" json:"pattern"`
+	Position  int    `json:"position"`
+}
+
+func (RegistrationQuestion) TableName() string {
+	return "registration_questions"
+}
+
+// RegistrationAnswer is one attendee's answer to a question. Value holds text
+// and single choice answers, Selected the options ticked on multi select ones.
+type RegistrationAnswer struct {
+	ID             uint     `gorm:"primaryKey" json:"id"`
+	RegistrationID uint     `gorm:"not null;uniqueIndex:idx_registration_answer" json:"registration_id"`
+	QuestionID     uint     `gorm:"not null;uniqueIndex:idx_registration_answer" json:"question_id"`
+	Value          string   `gorm:"type:text" json:"value"`
+	Selected       []string `gorm:"type:text;serializer:json" json:"selected,omitempty"`
+
+	// filled in from the question when answers are loaded
+	Label string `gorm:"->;-:migration" json:"label"`
+}
+
+func (RegistrationAnswer) TableName() string {
+	return "registration_answers"
+}
+
+// AnswerInput is an answer as sent by the attendee
+type AnswerInput struct {
+	QuestionID uint     `json:"question_id"`
+	Value      string   `json:"value"`
+	Selected   []string `json:"selected"`
+}
+
+// Text is the answer written out for exports
+func (a *RegistrationAnswer) Text() string {
+	parts := append([]string{}, a.Selected...)
+	if a.Value != "" {
+		parts = append(parts, a.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// FillDefaultOptions gives dietary and t-shirt questions their standard options
+func (q *RegistrationQuestion) FillDefaultOptions() {
+	if len(q.Options) > 0 {
+		return
+	}
+	switch q.Type {
+	case QuestionTypeDietary:
+		q.Options = append([]string{}, DefaultDietaryOptions...)
+	case QuestionTypeTShirt:
+		q.Options = append([]string{}, DefaultTShirtSizes...)
+	}
+}
+
+// CheckDefinition reports what's wrong with a question as set up by the organizer
+func (q *RegistrationQuestion) CheckDefinition() error {
+	if !q.Type.Valid() {
+		return errors.New("Question type must be one of text, choice, checkbox, dietary or tshirt_size")
+	}
+	if q.Type == QuestionTypeChoice && len(q.Options) < 2 {
+		return errors.New("Choice questions need at least two options")
+	}
+	seen := make(map[string]bool, len(q.Options))
+	for _, option := range q.Options {
+		if strings.TrimSpace(option) == "" || seen[option] {
+			return errors.New("Options must be unique and not empty")
+		}
+		seen[option] = true
+	}
+	if q.MinLength < 0 || q.MaxLength < 0 || (q.MaxLength > 0 && q.MinLength > q.MaxLength) {
+		return errors.New("min_length and max_length don't make a valid range")
+	}
+	if q.Pattern != "" {
+		if _, err := regexp.Compile(q.Pattern); err != nil {
+			return errors.New("Pattern is not a valid regular expression")
+		}
+	}
+	return nil
+}
+
+// multiSelect reports whether several options can be ticked. A checkbox
+// without options is a single yes/no box.
+func (q *RegistrationQuestion) multiSelect() bool {
+	return q.Type == QuestionTypeDietary || (q.Type == QuestionTypeCheckbox && len(q.Options) > 0)
+}
+
+func (q *RegistrationQuestion) hasOption(value string) bool {
+	for _, option := range q.Options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAnswer validates an answer and returns it ready to be saved. A nil
+// answer means the question was left blank.
+func (q *RegistrationQuestion) CheckAnswer(input *AnswerInput) (*RegistrationAnswer, error) {
+	var value string
+	var selected []string
+	if input != nil {
+		value = strings.TrimSpace(input.Value)
+		selected = input.Selected
+	}
+
+	switch {
+	case q.multiSelect():
+		seen := make(map[string]bool, len(selected))
+		for _, option := range selected {
+			if !q.hasOption(option) {
+				return nil, fmt.Errorf("'%s' is not an option for '%s'", option, q.Label)
+			}
+			if seen[option] {
+				return nil, fmt.Errorf("'%s' is selected twice for '%s'", option, q.Label)
+			}
+			seen[option] = true
+		}
+		// dietary questions take extra requirements as free text
+		if q.Type != QuestionTypeDietary {
+			value = ""
+		}
+	case q.Type == QuestionTypeCheckbox:
+		selected = nil
+		if value != "" {
+			checked, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' must be true or false", q.Label)
+			}
+			// an unticked box counts as no answer so required boxes have to be ticked
+			value = ""
+			if checked {
+				value = "true"
+			}
+		}
+	case q.Type == QuestionTypeChoice || q.Type == QuestionTypeTShirt:
+		selected = nil
+		if value != "" && !q.hasOption(value) {
+			return nil, fmt.Errorf("'%s' is not an option for '%s'", value, q.Label)
+		}
+	default:
+		selected = nil
+		length := utf8.RuneCountInString(value)
+		if value != "" {
+			if q.MinLength > 0 && length < q.MinLength {
+				return nil, fmt.Errorf("'%s' must be at least %d characters", q.Label, q.MinLength)
+			}
+			if q.Pattern != "" && !regexp.MustCompile(q.Pattern).MatchString(value) {
+				return nil, fmt.Errorf("'%s' is not in the expected format", q.Label)
+			}
+		}
+		if (q.MaxLength > 0 && length > q.MaxLength) || length > maxAnswerLength {
+			return nil, fmt.Errorf("'%s' is too long", q.Label)
+		}
+	}
+
+	if value == "" && len(selected) == 0 {
+		if q.Required {
+			return nil, fmt.Errorf("'%s' is required", q.Label)
+		}
+		return nil, nil
+	}
+
+	return &RegistrationAnswer{QuestionID: q.ID, Value: value, Selected: selected}, nil
+}
+
+// FindQuestionsForTicketType lists the questions asked when buying the ticket type
+func FindQuestionsForTicketType(db *gorm.DB, eventID, ticketTypeID uint) ([]RegistrationQuestion, error) {
+	var questions []RegistrationQuestion
+	result := db.Where("event_id = ? AND (ticket_type_id IS NULL OR ticket_type_id = ?)", eventID, ticketTypeID).
+		Order("position, id").Find(&questions)
+	return questions, result.Error
+}
+
+// CheckAnswers validates a registration's answers against the questions it
+// has to answer
+func CheckAnswers(questions []RegistrationQuestion, inputs []AnswerInput) ([]RegistrationAnswer, error) {
+	byQuestion := make(map[uint]*AnswerInput, len(inputs))
+	for i := range inputs {
+		if _, dup := byQuestion[inputs[i].QuestionID]; dup {
+			return nil, fmt.Errorf("Question %d is answered twice", inputs[i].QuestionID)
+		}
+		byQuestion[inputs[i].QuestionID] = &inputs[i]
+	}
+
+	answers := []RegistrationAnswer{}
+	for i := range questions {
+		answer, err := questions[i].CheckAnswer(byQuestion[questions[i].ID])
+		if err != nil {
+			return nil, err
+		}
+		delete(byQuestion, questions[i].ID)
+		if answer != nil {
+			answers = append(answers, *answer)
+		}
+	}
+
+	for id := range byQuestion {
+		return nil, fmt.Errorf("Question %d is not asked for this ticket", id)
+	}
+	return answers, nil
+}
+
+// FindAnswersByRegistrations loads the answers of several registrations keyed
+// by registration, with the question labels filled in
+func FindAnswersByRegistrations(db *gorm.DB, registrationIDs []uint) (map[uint][]RegistrationAnswer, error) {
+	byRegistration := make(map[uint][]RegistrationAnswer, len(registrationIDs))
+	if len(registrationIDs) == 0 {
+		return byRegistration, nil
+	}
+
+	var answers []RegistrationAnswer
+	err := db.Select("registration_answers.*, registration_questions.label AS label").
+		Joins("JOIN registration_questions ON registration_questions.id = registration_answers.question_id").
+		Where("registration_answers.registration_id IN ?", registrationIDs).
+		Order("registration_questions.position, registration_questions.id").
+		Find(&answers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, answer := range answers {
+		byRegistration[answer.RegistrationID] = append(byRegistration[answer.RegistrationID], answer)
+	}
+	return byRegistration, nil
+}
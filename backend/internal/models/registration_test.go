@@ -0,0 +1,170 @@
+package models_test
+
+import (
+	"errors"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/database"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// bcrypt hash length, so saving a user doesn't hash it again
+var testPasswordHash = strings.Repeat("x", 60)
+
+// openTestDB migrates a fresh SQLite database in a temp dir, connected the
+// same way the server connects so writers queue for the lock
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	database.DB = db
+	if err := database.MigrateSchema(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createTicketType makes a published event with one ticket type of quantity tickets
+func createTicketType(t *testing.T, db *gorm.DB, quantity int) (*models.Event, *models.TicketType) {
+	t.Helper()
+	user := models.User{FirstName: "Org", LastName: "Aniser", Email: "organizer@example.com", PasswordHash: testPasswordHash}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(7 * 24 * time.Hour)
+	event := models.Event{
+		UserID:        user.ID,
+		Title:         "Race",
+		Venue:         "Hall",
+		StartDatetime: start,
+		EndDatetime:   start.Add(2 * time.Hour),
+		IsPublished:   true,
+	}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	ticketType, err := models.CreateTicketType(db, event.ID, "GA", "", 1000, quantity, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &event, ticketType
+}
+
+func createUsers(t *testing.T, db *gorm.DB, n int) []models.User {
+	t.Helper()
+	users := make([]models.User, n)
+	for i := range users {
+		users[i] = models.User{FirstName: "User", LastName: fmt.Sprint(i), Email: fmt.Sprintf("user%d@example.com", i), PasswordHash: testPasswordHash}
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	return users
+}
+
+func soldAndRegistered(t *testing.T, db *gorm.DB, ticketTypeID uint) (int, int64) {
+	t.Helper()
+	ticketType, err := models.FindTicketTypeByID(db, ticketTypeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registered int64
+	if err := db.Model(&models.Registration{}).Where("ticket_type_id = ? AND status != ?", ticketTypeID, models.RegistrationStatusCanceled).Count(&registered).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ticketType.QuantitySold, registered
+}
+
+func TestReserveTicketConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	_, ticketType := createTicketType(t, db, 5)
+
+	const buyers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, soldOut := 0, 0
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := models.ReserveTicket(db, ticketType.ID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, models.ErrSoldOut):
+				soldOut++
+			default:
+				t.Errorf("ReserveTicket: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 5 || soldOut != buyers-5 {
+		t.Errorf("reserved %d and sold out %d, want 5 and %d", reserved, soldOut, buyers-5)
+	}
+	if sold, _ := soldAndRegistered(t, db, ticketType.ID); sold != 5 {
+		t.Errorf("quantity_sold = %d, want 5", sold)
+	}
+}
+
+func TestCreateRegistrationConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	event, ticketType := createTicketType(t, db, 5)
+	users := createUsers(t, db, 20)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created, soldOut := 0, 0
+	for i := range users {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := models.CreateRegistration(tx, userID, event.ID, ticketType.ID)
+				return err
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case errors.Is(err, models.ErrSoldOut):
+				soldOut++
+			default:
+				t.Errorf("CreateRegistration: %v", err)
+			}
+		}(users[i].ID)
+	}
+	wg.Wait()
+
+	if created != 5 || soldOut != len(users)-5 {
+		t.Errorf("created %d and sold out %d, want 5 and %d", created, soldOut, len(users)-5)
+	}
+	sold, registered := soldAndRegistered(t, db, ticketType.ID)
+	if sold != 5 || registered != 5 {
+		t.Errorf("quantity_sold = %d with %d registrations, want 5 and 5", sold, registered)
+	}
+}
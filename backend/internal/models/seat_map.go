@@ -0,0 +1,259 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SeatStatus string
+
+const (
+	SeatStatusAvailable SeatStatus = "available"
+	SeatStatusTaken     SeatStatus = "taken"
+	SeatStatusBlocked   SeatStatus = "blocked"
+)
+
+var (
+	ErrSeatTaken        = errors.New("seat is already taken")
+	ErrSeatUnavailable  = errors.New("seat is not available")
+	ErrSeatZoneMismatch = errors.New("seat is not sold with this ticket type")
+	ErrSeatRequired     = errors.New("this ticket type has reserved seating, a seat_id is required")
+)
+
+// SeatMap is the reserved seating layout of one event. Seats are grouped into
+// sections and rows, and each seat belongs to a price zone that decides which
+// ticket type it is sold as.
+type SeatMap struct {
+	Base
+	EventID uint   `gorm:"not null;uniqueIndex" json:"event_id"`
+	Name    string `gorm:"type:varchar(255)" json:"name"`
+
+	Event      Event         `gorm:"foreignKey:EventID" json:"-"`
+	Sections   []SeatSection `gorm:"foreignKey:SeatMapID" json:"sections,omitempty"`
+	PriceZones []PriceZone   `gorm:"foreignKey:SeatMapID" json:"price_zones,omitempty"`
+}
+
+func (SeatMap) TableName() string {
+	return "seat_maps"
+}
+
+type SeatSection struct {
+	Base
+	SeatMapID uint   `gorm:"not null;index" json:"seat_map_id"`
+	Name      string `gorm:"type:varchar(100);not null" json:"name"`
+	Position  int    `json:"position"`
+
+	Seats []Seat `gorm:"foreignKey:SectionID" json:"seats,omitempty"`
+}
+
+func (SeatSection) TableName() string {
+	return "seat_sections"
+}
+
+// PriceZone maps a group of seats to the ticket type they are sold as
+type PriceZone struct {
+	Base
+	SeatMapID    uint   `gorm:"not null;index" json:"seat_map_id"`
+	Name         string `gorm:"type:varchar(100);not null" json:"name"`
+	Color        string `gorm:"type:varchar(20)" json:"color"`
+	TicketTypeID uint   `gorm:"not null;index" json:"ticket_type_id"`
+}
+
+func (PriceZone) TableName() string {
+	return "price_zones"
+}
+
+type Seat struct {
+	Base
+	SeatMapID   uint   `gorm:"not null;index" json:"-"`
+	SectionID   uint   `gorm:"not null;uniqueIndex:idx_seat_position" json:"section_id"`
+	PriceZoneID uint   `gorm:"not null;index" json:"price_zone_id"`
+	Row         string `gorm:"type:varchar(10);not null;uniqueIndex:idx_seat_position" json:"row"`
+	Number      int    `gorm:"not null;uniqueIndex:idx_seat_position" json:"number"`
+	Label       string `gorm:"type:varchar(50)" json:"label"`
+	// grid position within the section, for rendering
+	X          int  `json:"x"`
+	Y          int  `json:"y"`
+	Accessible bool `gorm:"default:false" json:"accessible"`
+	Blocked    bool `gorm:"default:false" json:"-"`
+
+	Status SeatStatus `gorm:"-" json:"status"`
+}
+
+func (Seat) TableName() string {
+	return "seats"
+}
+
+// SeatAssignment holds a seat for a registration. The unique index on seat_id
+// is what stops two buyers getting the same seat.
+type SeatAssignment struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	EventID        uint      `gorm:"not null;index" json:"event_id"`
+	SeatID         uint      `gorm:"not null;uniqueIndex" json:"seat_id"`
+	RegistrationID uint      `gorm:"not null;index" json:"registration_id"`
+	UserID         uint      `gorm:"not null" json:"user_id"`
+
+	Seat Seat `gorm:"foreignKey:SeatID" json:"seat"`
+}
+
+func (SeatAssignment) TableName() string {
+	return "seat_assignments"
+}
+
+func FindSeatMapByEvent(db *gorm.DB, eventID uint) (*SeatMap, error) {
+	var seatMap SeatMap
+	result := db.Preload("PriceZones").Preload("Sections", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Where("event_id = ?", eventID).First(&seatMap)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &seatMap, nil
+}
+
+// LoadSeatStatus fills in the seats of every section with their current status
+func (m *SeatMap) LoadSeatStatus(db *gorm.DB) error {
+	var seats []Seat
+	if err := db.Where("seat_map_id = ?", m.ID).Order("row, number").Find(&seats).Error; err != nil {
+		return err
+	}
+
+	taken, err := takenSeatIDs(db, m.EventID)
+	if err != nil {
+		return err
+	}
+
+	bySection := make(map[uint][]Seat)
+	for _, seat := range seats {
+		seat.Status = seatStatus(&seat, taken)
+		bySection[seat.SectionID] = append(bySection[seat.SectionID], seat)
+	}
+	for i := range m.Sections {
+		m.Sections[i].Seats = bySection[m.Sections[i].ID]
+	}
+	return nil
+}
+
+// SeatAvailability counts available seats per price zone and lists the ids of
+// seats that can't be picked
+func (m *SeatMap) SeatAvailability(db *gorm.DB) (map[uint]int, []uint, error) {
+	var seats []Seat
+	if err := db.Select("id, price_zone_id, blocked").Where("seat_map_id = ?", m.ID).Find(&seats).Error; err != nil {
+		return nil, nil, err
+	}
+
+	taken, err := takenSeatIDs(db, m.EventID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	available := make(map[uint]int, len(m.PriceZones))
+	for _, zone := range m.PriceZones {
+		available[zone.ID] = 0
+	}
+	unavailable := []uint{}
+	for _, seat := range seats {
+		if seatStatus(&seat, taken) == SeatStatusAvailable {
+			available[seat.PriceZoneID]++
+		} else {
+			unavailable = append(unavailable, seat.ID)
+		}
+	}
+	return available, unavailable, nil
+}
+
+func takenSeatIDs(db *gorm.DB, eventID uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := db.Model(&SeatAssignment{}).Where("event_id = ?", eventID).Pluck("seat_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	taken := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		taken[id] = true
+	}
+	return taken, nil
+}
+
+func seatStatus(seat *Seat, taken map[uint]bool) SeatStatus {
+	switch {
+	case seat.Blocked:
+		return SeatStatusBlocked
+	case taken[seat.ID]:
+		return SeatStatusTaken
+	default:
+		return SeatStatusAvailable
+	}
+}
+
+// IsSeatedTicketType reports whether the ticket type is sold through a price zone
+func IsSeatedTicketType(db *gorm.DB, ticketTypeID uint) (bool, error) {
+	var count int64
+	err := db.Model(&PriceZone{}).Where("ticket_type_id = ?", ticketTypeID).Count(&count).Error
+	return count > 0, err
+}
+
+// AssignSeat holds the seat for the registration. It should run in the same
+// transaction that creates the registration.
+func AssignSeat(tx *gorm.DB, registration *Registration, seatID uint) (*SeatAssignment, error) {
+	var seat Seat
+	err := tx.Joins("JOIN seat_maps ON seat_maps.id = seats.seat_map_id AND seat_maps.deleted_at IS NULL").
+		Where("seats.id = ? AND seat_maps.event_id = ?", seatID, registration.EventID).
+		First(&seat).Error
+	if err != nil {
+		return nil, ErrSeatUnavailable
+	}
+	if seat.Blocked {
+		return nil, ErrSeatUnavailable
+	}
+
+	var zone PriceZone
+	if err := tx.First(&zone, seat.PriceZoneID).Error; err != nil {
+		return nil, err
+	}
+	if zone.TicketTypeID != registration.TicketTypeID {
+		return nil, ErrSeatZoneMismatch
+	}
+
+	assignment := SeatAssignment{
+		EventID:        registration.EventID,
+		SeatID:         seat.ID,
+		RegistrationID: registration.ID,
+		UserID:         registration.UserID,
+	}
+	if err := tx.Create(&assignment).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrSeatTaken
+		}
+		return nil, err
+	}
+
+	assignment.Seat = seat
+	return &assignment, nil
+}
+
+func FindSeatAssignment(db *gorm.DB, registrationID uint) (*SeatAssignment, error) {
+	var assignment SeatAssignment
+	result := db.Preload("Seat").Where("registration_id = ?", registrationID).First(&assignment)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &assignment, nil
+}
+
+// DeleteSeatMap removes the layout of an event. Rows are hard deleted so the
+// unique indexes don't trip over soft deleted seats when a map is replaced.
+func DeleteSeatMap(tx *gorm.DB, seatMap *SeatMap) error {
+	if err := tx.Unscoped().Where("seat_map_id = ?", seatMap.ID).Delete(&Seat{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("seat_map_id = ?", seatMap.ID).Delete(&SeatSection{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("seat_map_id = ?", seatMap.ID).Delete(&PriceZone{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(seatMap).Error
+}
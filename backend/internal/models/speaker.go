@@ -0,0 +1,39 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Speaker struct {
+	Base
+	EventID  uint   `gorm:"not null;index" json:"event_id"`
+	Name     string `gorm:"type:varchar(255);not null" json:"name"`
+	JobTitle string `gorm:"type:varchar(255)" json:"job_title"`
+	Company  string `gorm:"type:varchar(255)" json:"company"`
+	Bio      string `gorm:"type:text" json:"bio"`
+	PhotoURL string `gorm:"type:varchar(500)" json:"photo_url"`
+	Website  string `gorm:"type:varchar(500)" json:"website"`
+
+	Event    Event          `gorm:"foreignKey:EventID" json:"-"`
+	Sessions []EventSession `gorm:"many2many:session_speakers" json:"-"`
+}
+
+func (Speaker) TableName() string {
+	return "speakers"
+}
+
+func FindSpeakersByEvent(db *gorm.DB, eventID uint) ([]Speaker, error) {
+	var speakers []Speaker
+	result := db.Where("event_id = ?", eventID).Order("name").Find(&speakers)
+	return speakers, result.Error
+}
+
+// FindSpeakersForEvent loads the speakers with the given ids, ignoring any that belong to another event
+func FindSpeakersForEvent(db *gorm.DB, eventID uint, ids []uint) ([]Speaker, error) {
+	var speakers []Speaker
+	if len(ids) == 0 {
+		return speakers, nil
+	}
+	result := db.Where("event_id = ? AND id IN ?", eventID, ids).Find(&speakers)
+	return speakers, result.Error
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TransferStatus string
+
+const (
+	TransferStatusPending  TransferStatus = "pending"
+	TransferStatusAccepted TransferStatus = "accepted"
+	TransferStatusCanceled TransferStatus = "canceled"
+	TransferStatusExpired  TransferStatus = "expired"
+)
+
+// how long a recipient has to accept, cut short by the event starting
+const transferWindow = 7 * 24 * time.Hour
+
+var (
+	ErrTransferNotPending = errors.New("this transfer is no longer pending")
+	ErrTransferExpired    = errors.New("this transfer has expired")
+	ErrTicketChanged      = errors.New("this ticket can no longer be transferred")
+)
+
+// TicketTransfer moves a confirmed ticket from its holder to someone else by
+// email. The recipient accepts with Token. Transfers are kept once done as the
+// ticket's history of owners.
+type TicketTransfer struct {
+	Base
+	RegistrationID uint           `gorm:"not null;index" json:"registration_id"`
+	EventID        uint           `gorm:"not null;index" json:"event_id"`
+	FromUserID     uint           `gorm:"not null;index" json:"from_user_id"`
+	ToEmail        string         `gorm:"type:varchar(255);not null" json:"to_email"`
+	ToName         string         `gorm:"type:varchar(255)" json:"to_name"`
+	ToUserID       *uint          `json:"to_user_id,omitempty"`
+	Status         TransferStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	Token          string         `gorm:"type:varchar(64);index" json:"-"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	AcceptedAt     *time.Time     `json:"accepted_at,omitempty"`
+}
+
+func (TicketTransfer) TableName() string {
+	return "ticket_transfers"
+}
+
+// NewTicketTransfer starts a transfer of the registration to toEmail
+func NewTicketTransfer(registration *Registration, event *Event, toEmail, toName string) (*TicketTransfer, error) {
+	token, err := NewShareToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(transferWindow)
+	if event.StartDatetime.Before(expiresAt) {
+		expiresAt = event.StartDatetime
+	}
+	return &TicketTransfer{
+		RegistrationID: registration.ID,
+		EventID:        registration.EventID,
+		FromUserID:     registration.UserID,
+		ToEmail:        NormalizeEmail(toEmail),
+		ToName:         toName,
+		Status:         TransferStatusPending,
+		Token:          token,
+		ExpiresAt:      expiresAt,
+	}, nil
+}
+
+func (t *TicketTransfer) IsExpired() bool {
+	return t.Status == TransferStatusPending && !t.ExpiresAt.After(time.Now())
+}
+
+// Close ends a pending transfer without moving the ticket. Only the status
+// and token are written, and only while the transfer is still pending, so a
+// stale copy can't undo an accept that happened in the meantime.
+func (t *TicketTransfer) Close(db *gorm.DB, status TransferStatus) error {
+	result := db.Model(&TicketTransfer{}).
+		Where("id = ? AND status = ?", t.ID, TransferStatusPending).
+		Updates(map[string]interface{}{"status": status, "token": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTransferNotPending
+	}
+	t.Status = status
+	t.Token = ""
+	return nil
+}
+
+// Accept hands the ticket over to user. The old holder's ticket code stops
+// working and the seat and session places go with the ticket.
+func (t *TicketTransfer) Accept(tx *gorm.DB, user *User) (*Registration, error) {
+	if t.Status != TransferStatusPending {
+		return nil, ErrTransferNotPending
+	}
+	if t.IsExpired() {
+		return nil, ErrTransferExpired
+	}
+
+	// claim the transfer first, a second accept of the same transfer finds
+	// it's no longer pending and leaves it alone
+	now := time.Now()
+	result := tx.Model(&TicketTransfer{}).
+		Where("id = ? AND status = ?", t.ID, TransferStatusPending).
+		Updates(map[string]interface{}{
+			"status":      TransferStatusAccepted,
+			"token":       "",
+			"to_user_id":  user.ID,
+			"accepted_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTransferNotPending
+	}
+
+	var registration Registration
+	if err := tx.First(&registration, t.RegistrationID).Error; err != nil {
+		return nil, err
+	}
+	if registration.UserID != t.FromUserID || registration.Status != RegistrationStatusConfirmed {
+		return nil, ErrTicketChanged
+	}
+
+	code, err := NewTicketCode()
+	if err != nil {
+		return nil, err
+	}
+	registration.UserID = user.ID
+	registration.AttendeeName = fullName(user)
+	registration.AttendeeEmail = NormalizeEmail(user.Email)
+	registration.TicketCode = code
+	if err := tx.Model(&registration).Updates(map[string]interface{}{
+		"user_id":        registration.UserID,
+		"attendee_name":  registration.AttendeeName,
+		"attendee_email": registration.AttendeeEmail,
+		"ticket_code":    registration.TicketCode,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&SeatAssignment{}).Where("registration_id = ?", registration.ID).Update("user_id", user.ID).Error; err != nil {
+		return nil, err
+	}
+	// a session the recipient is already signed up for keeps their own place
+	if err := tx.Model(&SessionSignup{}).
+		Where("registration_id = ? AND session_id NOT IN (?)", registration.ID,
+			tx.Unscoped().Model(&SessionSignup{}).Select("session_id").Where("user_id = ?", user.ID)).
+		Update("user_id", user.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("registration_id = ? AND user_id != ?", registration.ID, user.ID).Delete(&SessionSignup{}).Error; err != nil {
+		return nil, err
+	}
+
+	t.Status = TransferStatusAccepted
+	t.Token = ""
+	t.ToUserID = &user.ID
+	t.AcceptedAt = &now
+	return &registration, nil
+}
+
+func fullName(user *User) string {
+	if user.LastName == "" {
+		return user.FirstName
+	}
+	return user.FirstName + " " + user.LastName
+}
+
+func FindTicketTransferByToken(db *gorm.DB, token string) (*TicketTransfer, error) {
+	var transfer TicketTransfer
+	result := db.Where("token = ? AND token != ''", token).First(&transfer)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &transfer, nil
+}
+
+// FindPendingTransfer finds the transfer waiting to be accepted for a ticket
+func FindPendingTransfer(db *gorm.DB, registrationID uint) (*TicketTransfer, error) {
+	var transfer TicketTransfer
+	result := db.Where("registration_id = ? AND status = ?", registrationID, TransferStatusPending).First(&transfer)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &transfer, nil
+}
+
+func FindTransfersByRegistration(db *gorm.DB, registrationID uint) ([]TicketTransfer, error) {
+	var transfers []TicketTransfer
+	result := db.Where("registration_id = ?", registrationID).Order("created_at").Find(&transfers)
+	return transfers, result.Error
+}
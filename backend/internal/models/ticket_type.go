@@ -1,21 +1,37 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+var ErrSoldOut = errors.New("no tickets available")
+
 type TicketType struct {
 	Base
 	EventID           uint       `json:"event_id"`
 	Name              string     `gorm:"type:varchar(100);not null" json:"name"`
 	Description       string     `gorm:"type:text" json:"description"`
-	Price             float64    `gorm:"type:numeric(10,2);not null" json:"price"`
+	Price             Money      `gorm:"not null" json:"price"`
 	QuantityAvailable int        `gorm:"not null" json:"quantity_available"`
 	IsVIP             bool       `gorm:"column:is_vip;default:false" json:"is_vip"`
 	SaleStartDate     *time.Time `gorm:"type:datetime" json:"sale_start_date,omitempty"`
 	SaleEndDate       *time.Time `gorm:"type:datetime" json:"sale_end_date,omitempty"`
+	// hidden ticket types are only offered to buyers with an access code
+	IsHidden bool `gorm:"default:false" json:"is_hidden"`
+	// how many tickets of the type one buyer can hold, 0 means no limit
+	MinPerUser int `gorm:"not null;default:0" json:"min_per_user"`
+	MaxPerUser int `gorm:"not null;default:0" json:"max_per_user"`
+	// tickets currently held by registrations that aren't canceled. It's only
+	// ever changed by Reserve and Release so saving a ticket type can't
+	// overwrite a sale made in the meantime.
+	QuantitySold int `gorm:"<-:false;not null;default:0" json:"quantity_sold"`
+
+	// loaded separately, ticket_types is a raw SQL table so gorm mustn't try
+	// to migrate a relation from it
+	PriceTiers []PriceTier `gorm:"-" json:"price_tiers,omitempty"`
 
 	//Relationships
 	Event         Event          `gorm:"foreignKey:EventID" json:"-"`
@@ -44,18 +60,36 @@ func (t *TicketType) IsOnSale() bool {
 	return true
 }
 
+// GetAvailableQuantity reads the sold count again so it's current even if t
+// was loaded a while ago
 func (t *TicketType) GetAvailableQuantity(db *gorm.DB) (int, error) {
-	var soldCount int64
-
-	err := db.Model(&Registration{}).
-		Where("ticket_type_id = ? AND status != ?", t.ID, "canceled").
-		Count(&soldCount).Error
-
+	var sold int
+	err := db.Model(&TicketType{}).Where("id = ?", t.ID).Select("quantity_sold").Scan(&sold).Error
 	if err != nil {
 		return 0, err
 	}
+	t.QuantitySold = sold
+
+	return t.QuantityAvailable - sold, nil
+}
+
+// ReserveTicket takes one ticket of the type if any are left. The check and
+// the increment are a single statement so two buyers can't both get the last
+// ticket.
+func ReserveTicket(db *gorm.DB, ticketTypeID uint) error {
+	result := db.Exec("UPDATE ticket_types SET quantity_sold = quantity_sold + 1 WHERE id = ? AND quantity_sold < quantity_available", ticketTypeID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSoldOut
+	}
+	return nil
+}
 
-	return t.QuantityAvailable - int(soldCount), nil
+// ReleaseTicket gives back a ticket taken with ReserveTicket
+func ReleaseTicket(db *gorm.DB, ticketTypeID uint) error {
+	return db.Exec("UPDATE ticket_types SET quantity_sold = quantity_sold - 1 WHERE id = ? AND quantity_sold > 0", ticketTypeID).Error
 }
 
 func FindTicketTypeByID(db *gorm.DB, id uint) (*TicketType, error) {
@@ -73,7 +107,7 @@ func FindTicketTypesByEvent(db *gorm.DB, eventID uint) ([]TicketType, error) {
 	return ticketTypes, result.Error
 }
 
-func CreateTicketType(db *gorm.DB, eventID uint, name, description string, price float64, quantity int, isVIP bool, saleStart, saleEnd *time.Time) (*TicketType, error) {
+func CreateTicketType(db *gorm.DB, eventID uint, name, description string, price Money, quantity int, isVIP bool, saleStart, saleEnd *time.Time) (*TicketType, error) {
 	ticketType := TicketType{
 		EventID:           eventID,
 		Name:              name,
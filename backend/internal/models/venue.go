@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Venue is a reusable location record. Any organizer can hold events at a
+// venue, only the organizer who created it (or an admin) can edit it.
+type Venue struct {
+	Base
+	UserID        uint     `gorm:"not null;index" json:"user_id"`
+	Name          string   `gorm:"type:varchar(255);not null;index" json:"name"`
+	Address       string   `gorm:"type:varchar(255)" json:"address"`
+	City          string   `gorm:"type:varchar(100);index" json:"city"`
+	State         string   `gorm:"type:varchar(100)" json:"state"`
+	ZipCode       string   `gorm:"type:varchar(20)" json:"zip_code"`
+	Country       string   `gorm:"type:varchar(100)" json:"country"`
+	Latitude      *float64 `json:"latitude"`
+	Longitude     *float64 `json:"longitude"`
+	Capacity      int      `gorm:"default:0" json:"capacity"` // 0 means unknown / unlimited
+	Accessibility string   `gorm:"type:text" json:"accessibility"`
+	StepFree      bool     `gorm:"default:false" json:"step_free"`
+	HearingLoop   bool     `gorm:"default:false" json:"hearing_loop"`
+	Website       string   `gorm:"type:varchar(500)" json:"website"`
+
+	User  User        `gorm:"foreignKey:UserID" json:"-"`
+	Rooms []VenueRoom `gorm:"foreignKey:VenueID" json:"rooms,omitempty"`
+}
+
+func (Venue) TableName() string {
+	return "venues"
+}
+
+type VenueRoom struct {
+	Base
+	VenueID       uint   `gorm:"not null;index" json:"venue_id"`
+	Name          string `gorm:"type:varchar(100);not null" json:"name"`
+	Capacity      int    `gorm:"default:0" json:"capacity"`
+	Floor         string `gorm:"type:varchar(50)" json:"floor"`
+	Accessibility string `gorm:"type:text" json:"accessibility"`
+
+	Venue Venue `gorm:"foreignKey:VenueID" json:"-"`
+}
+
+func (VenueRoom) TableName() string {
+	return "venue_rooms"
+}
+
+// FullAddress joins the non-empty address parts for display
+func (v *Venue) FullAddress() string {
+	address := v.Address
+	for _, part := range []string{v.City, v.State, v.ZipCode, v.Country} {
+		if part == "" {
+			continue
+		}
+		if address != "" {
+			address += ", "
+		}
+		address += part
+	}
+	return address
+}
+
+// ApplyTo copies the venue's location onto the event's free text fields so
+// search and older clients keep working for events linked to a venue
+func (v *Venue) ApplyTo(e *Event) {
+	e.VenueID = &v.ID
+	e.Venue = v.Name
+	e.Address = v.Address
+	e.City = v.City
+	e.State = v.State
+	e.ZipCode = v.ZipCode
+	e.Country = v.Country
+}
+
+func FindVenueByID(db *gorm.DB, id uint) (*Venue, error) {
+	var venue Venue
+	result := db.First(&venue, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &venue, nil
+}
+
+func FindVenueRoom(db *gorm.DB, venueID, roomID uint) (*VenueRoom, error) {
+	var room VenueRoom
+	result := db.Where("id = ? AND venue_id = ?", roomID, venueID).First(&room)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &room, nil
+}
+
+// EventCapacity returns the number of attendees the event's venue can hold.
+// A room's capacity takes precedence over the venue's. Zero means no limit is known.
+func EventCapacity(db *gorm.DB, e *Event) (int, string, error) {
+	if e.VenueRoomID != nil {
+		var room VenueRoom
+		if err := db.First(&room, *e.VenueRoomID).Error; err != nil {
+			return 0, "", err
+		}
+		if room.Capacity > 0 {
+			return room.Capacity, fmt.Sprintf("room '%s'", room.Name), nil
+		}
+	}
+
+	if e.VenueID != nil {
+		venue, err := FindVenueByID(db, *e.VenueID)
+		if err != nil {
+			return 0, "", err
+		}
+		if venue.Capacity > 0 {
+			return venue.Capacity, fmt.Sprintf("venue '%s'", venue.Name), nil
+		}
+	}
+
+	return 0, "", nil
+}
+
+// TotalTicketQuantity sums quantity_available over the event's ticket types,
+// leaving out excludeID so an update can be checked with its new quantity
+func TotalTicketQuantity(db *gorm.DB, eventID, excludeID uint) (int, error) {
+	var total int
+	err := db.Model(&TicketType{}).
+		Where("event_id = ? AND id != ?", eventID, excludeID).
+		Select("COALESCE(SUM(quantity_available), 0)").
+		Scan(&total).Error
+	return total, err
+}
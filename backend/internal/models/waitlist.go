@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WaitlistStatus string
+
+const (
+	WaitlistStatusWaiting WaitlistStatus = "waiting"
+	// a ticket is being kept for the user until OfferExpiresAt
+	WaitlistStatusOffered WaitlistStatus = "offered"
+	WaitlistStatusClaimed WaitlistStatus = "claimed"
+	WaitlistStatusExpired WaitlistStatus = "expired"
+	WaitlistStatusRemoved WaitlistStatus = "removed"
+)
+
+var (
+	ErrOfferExpired = errors.New("this waitlist offer has expired")
+	ErrNotOffered   = errors.New("no ticket has been offered for this waitlist entry")
+)
+
+// WaitlistEntry queues a user for a sold out ticket type. When a ticket is
+// released it's reserved for the first user waiting, who gets a limited time
+// to claim it with ClaimToken.
+type WaitlistEntry struct {
+	Base
+	EventID        uint           `gorm:"not null;index" json:"event_id"`
+	TicketTypeID   uint           `gorm:"not null;index" json:"ticket_type_id"`
+	UserID         uint           `gorm:"not null;index" json:"user_id"`
+	Status         WaitlistStatus `gorm:"type:varchar(20);default:'waiting';index" json:"status"`
+	ClaimToken     string         `gorm:"type:varchar(64);index" json:"-"`
+	OfferedAt      *time.Time     `json:"offered_at,omitempty"`
+	OfferExpiresAt *time.Time     `json:"offer_expires_at,omitempty"`
+	RegistrationID *uint          `json:"registration_id,omitempty"`
+}
+
+func (WaitlistEntry) TableName() string {
+	return "waitlist_entries"
+}
+
+// IsActive reports whether the entry is still in the queue or holding an offer
+func (w *WaitlistEntry) IsActive() bool {
+	return w.Status == WaitlistStatusWaiting || w.Status == WaitlistStatusOffered
+}
+
+// Position is the entry's place in the queue for its ticket type, starting at
+// 1, or 0 once it has left the queue
+func (w *WaitlistEntry) Position(db *gorm.DB) (int, error) {
+	if w.Status != WaitlistStatusWaiting {
+		return 0, nil
+	}
+	var ahead int64
+	err := db.Model(&WaitlistEntry{}).
+		Where("ticket_type_id = ? AND status = ? AND id < ?", w.TicketTypeID, WaitlistStatusWaiting, w.ID).
+		Count(&ahead).Error
+	return int(ahead) + 1, err
+}
+
+// Offer reserves a ticket for the entry, failing with ErrSoldOut if there
+// isn't one free
+func (w *WaitlistEntry) Offer(tx *gorm.DB, window time.Duration) error {
+	if err := ReserveTicket(tx, w.TicketTypeID); err != nil {
+		return err
+	}
+	token, err := NewShareToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expiresAt := now.Add(window)
+	w.Status = WaitlistStatusOffered
+	w.ClaimToken = token
+	w.OfferedAt = &now
+	w.OfferExpiresAt = &expiresAt
+	return tx.Save(w).Error
+}
+
+// Close takes the entry off the waitlist, giving back the ticket kept for it
+// if it had an offer
+func (w *WaitlistEntry) Close(tx *gorm.DB, status WaitlistStatus) error {
+	if w.Status == WaitlistStatusOffered {
+		if err := ReleaseTicket(tx, w.TicketTypeID); err != nil {
+			return err
+		}
+	}
+	w.Status = status
+	w.ClaimToken = ""
+	return tx.Save(w).Error
+}
+
+// Claim turns the offered ticket into a pending registration. The ticket kept
+// for the offer is handed over to the registration in the same transaction so
+// no one else can take it in between.
+func (w *WaitlistEntry) Claim(tx *gorm.DB) (*Registration, error) {
+	if w.Status != WaitlistStatusOffered {
+		return nil, ErrNotOffered
+	}
+	if w.OfferExpiresAt != nil && time.Now().After(*w.OfferExpiresAt) {
+		return nil, ErrOfferExpired
+	}
+
+	if err := ReleaseTicket(tx, w.TicketTypeID); err != nil {
+		return nil, err
+	}
+	registration, err := CreateRegistration(tx, w.UserID, w.EventID, w.TicketTypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Status = WaitlistStatusClaimed
+	w.ClaimToken = ""
+	w.RegistrationID = &registration.ID
+	if err := tx.Save(w).Error; err != nil {
+		return nil, err
+	}
+	return registration, nil
+}
+
+func FindWaitlistEntryByToken(db *gorm.DB, token string) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	result := db.Where("claim_token = ? AND claim_token != ''", token).First(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &entry, nil
+}
+
+// FindActiveWaitlistEntry finds the user's place on the waitlist of a ticket type
+func FindActiveWaitlistEntry(db *gorm.DB, userID, ticketTypeID uint) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	result := db.Where("user_id = ? AND ticket_type_id = ? AND status IN ?", userID, ticketTypeID,
+		[]WaitlistStatus{WaitlistStatusWaiting, WaitlistStatusOffered}).First(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &entry, nil
+}
+
+// NextOnWaitlist finds the entry that has been waiting longest for the ticket type
+func NextOnWaitlist(db *gorm.DB, ticketTypeID uint) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	result := db.Where("ticket_type_id = ? AND status = ?", ticketTypeID, WaitlistStatusWaiting).Order("id").First(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &entry, nil
+}
+
+// FindExpiredOffers finds offers that weren't claimed in time
+func FindExpiredOffers(db *gorm.DB, now time.Time) ([]WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	result := db.Where("status = ? AND offer_expires_at <= ?", WaitlistStatusOffered, now).Find(&entries)
+	return entries, result.Error
+}
+
+// WaitlistedTicketTypes lists the ticket types that have someone waiting
+func WaitlistedTicketTypes(db *gorm.DB) ([]uint, error) {
+	var ids []uint
+	result := db.Model(&WaitlistEntry{}).Where("status = ?", WaitlistStatusWaiting).Distinct().Pluck("ticket_type_id", &ids)
+	return ids, result.Error
+}
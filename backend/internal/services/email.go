@@ -4,20 +4,31 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"io"
+	"log"
 	"lujke-dunn/314-group-project/backend/internal/config"
 	"lujke-dunn/314-group-project/backend/internal/models"
+	"strconv"
+	"time"
 
 	"gopkg.in/gomail.v2"
 )
 
 type EmailService struct {
-	config *config.SMTPConfig
+	config  *config.SMTPConfig
+	tickets *TicketSigner
+	printer *Printer
 }
 
-func NewEmailService(cfg *config.SMTPConfig) *EmailService {
-	return &EmailService{
-		config: cfg,
+func NewEmailService(cfg *config.SMTPConfig, tickets *TicketSigner) *EmailService {
+	service := &EmailService{
+		config:  cfg,
+		tickets: tickets,
 	}
+	if tickets != nil {
+		service.printer = NewPrinter(tickets)
+	}
+	return service
 }
 
 func (s *EmailService) SendNotificationEmail(user *models.User, notification *models.Notification) error {
@@ -42,12 +53,43 @@ func (s *EmailService) sendEmail(to, subject, body string) error {
 	return d.DialAndSend(m)
 }
 
-func (s *EmailService) sendEmailWithQR(to, subject, body string) error {
+// sendTicketEmail sends the ticket's QR code inline, the body shows it with
+// cid:ticket.png, and the printable ticket as an attachment
+func (s *EmailService) sendTicketEmail(to, subject, body string, qr []byte, ticketPDF []byte) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", body)
+	if qr != nil {
+		m.Embed("ticket.png", gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(qr)
+			return err
+		}))
+	}
+	if ticketPDF != nil {
+		m.Attach("ticket.pdf", gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(ticketPDF)
+			return err
+		}))
+	}
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// sendEmailWithAttachment sends the body with one file attached
+func (s *EmailService) sendEmailWithAttachment(to, subject, body, filename string, data []byte) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.config.From)
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
+	m.Attach(filename, gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}))
 
 	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
 
@@ -146,8 +188,35 @@ func (s *EmailService) renderEmailBody(notification *models.Notification) (strin
 	return buf.String(), nil
 }
 
-func (s *EmailService) SendRegistrationConfirmation(user *models.User, eventName string, ticketType string) error {
+// SendRegistrationConfirmation sends the attendee their ticket, with its QR
+// code and a printable PDF copy when there's a registration to sign
+func (s *EmailService) SendRegistrationConfirmation(user *models.User, event *models.Event, ticketType string, joinInfo *models.EventJoinInfo, registration *models.Registration) error {
+	eventName := event.Title
 	subject := "Registration Confirmation - " + eventName
+
+	var qr, ticketPDF []byte
+	qrSection := ""
+	if s.tickets != nil && registration != nil {
+		var err error
+		if qr, err = s.tickets.QRCode(registration); err != nil {
+			log.Printf("Failed to render QR code for registration %d: %v", registration.ID, err)
+		} else {
+			ticketPDF, err = s.printer.TicketPDF(TicketDocument{
+				Event:        event,
+				Registration: registration,
+				TicketType:   ticketType,
+				AttendeeName: registration.NameOnTicket(user),
+			})
+			if err != nil {
+				log.Printf("Failed to render PDF ticket for registration %d: %v", registration.ID, err)
+			}
+			qrSection = `<div class="qr-section">
+            <h3>Your Ticket QR Code</h3>
+            <img src="cid:ticket.png" alt="Ticket QR Code" class="qr-code">
+            <p><small>Present this QR code at the event entrance, or print the attached ticket</small></p>
+        </div>`
+		}
+	}
 	body := fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
@@ -213,11 +282,8 @@ func (s *EmailService) SendRegistrationConfirmation(user *models.User, eventName
             <p><strong>Ticket Type:</strong> %s</p>
             <p><strong>Status:</strong> Confirmed</p>
         </div>
-        <div class="qr-section">
-            <h3>Your Ticket QR Code</h3>
-            <img src="https://i.imgur.com/9zQX5jE.png" alt="Ticket QR Code" class="qr-code">
-            <p><small>Present this QR code at the event entrance</small></p>
-        </div>
+        %s
+        %s
         <p>We look forward to seeing you at the event!</p>
     </div>
     <div class="footer">
@@ -226,8 +292,11 @@ func (s *EmailService) SendRegistrationConfirmation(user *models.User, eventName
     </div>
 </body>
 </html>
-`, user.FirstName, eventName, eventName, ticketType)
+`, user.FirstName, eventName, eventName, ticketType, joinInfoHTML(joinInfo), qrSection)
 
+	if qr != nil {
+		return s.sendTicketEmail(user.Email, subject, body, qr, ticketPDF)
+	}
 	return s.sendEmail(user.Email, subject, body)
 }
 
@@ -297,8 +366,29 @@ func (s *EmailService) SendEventUpdateNotification(user *models.User, eventName
 	return s.sendEmail(user.Email, subject, body)
 }
 
-func (s *EmailService) SendPaymentConfirmation(user *models.User, eventName string, amount float64) error {
+func (s *EmailService) SendPaymentConfirmation(user *models.User, eventName string, breakdown models.PriceBreakdown, addOns []models.RegistrationAddOn) error {
 	subject := "Payment Confirmation - " + eventName
+
+	// only the charges that apply are listed. Subtotal already has the
+	// discount taken off, so tickets show at full price above the discount line
+	currency := breakdown.Currency
+	charges := fmt.Sprintf("<p><strong>Tickets:</strong> %s</p>", (breakdown.Subtotal + breakdown.Discount).Format(currency))
+	for _, item := range addOns {
+		charges += fmt.Sprintf("<p><strong>%s &times; %d:</strong> %s</p>", template.HTMLEscapeString(item.Name), item.Quantity, item.Total.Format(currency))
+	}
+	if breakdown.Discount > 0 {
+		charges += fmt.Sprintf("<p><strong>Discount:</strong> -%s</p>", breakdown.Discount.Format(currency))
+	}
+	if breakdown.Fees > 0 {
+		charges += fmt.Sprintf("<p><strong>Booking Fees:</strong> %s</p>", breakdown.Fees.Format(currency))
+	}
+	if breakdown.Tax > 0 {
+		if breakdown.TaxInclusive {
+			charges += fmt.Sprintf("<p><strong>Includes %s:</strong> %s</p>", template.HTMLEscapeString(breakdown.TaxName), breakdown.Tax.Format(currency))
+		} else {
+			charges += fmt.Sprintf("<p><strong>%s:</strong> %s</p>", template.HTMLEscapeString(breakdown.TaxName), breakdown.Tax.Format(currency))
+		}
+	}
 	body := fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
@@ -336,18 +426,6 @@ func (s *EmailService) SendPaymentConfirmation(user *models.User, eventName stri
             color: #2196F3;
             font-weight: bold;
         }
-        .qr-section {
-            text-align: center;
-            margin: 20px 0;
-            padding: 20px;
-            background-color: white;
-            border-radius: 8px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        .qr-code {
-            max-width: 200px;
-            height: auto;
-        }
         .footer {
             margin-top: 20px;
             text-align: center;
@@ -366,15 +444,11 @@ func (s *EmailService) SendPaymentConfirmation(user *models.User, eventName stri
         <div class="payment-details">
             <h3>Payment Details:</h3>
             <p><strong>Event:</strong> %s</p>
-            <p><strong>Amount Paid:</strong> <span class="amount">$%.2f</span></p>
+            %s
+            <p><strong>Amount Paid:</strong> <span class="amount">%s</span></p>
             <p><strong>Status:</strong> Completed</p>
         </div>
-        <div class="qr-section">
-            <h3>Your Ticket QR Code</h3>
-            <img src="cid:qrcode" alt="Ticket QR Code" class="qr-code">
-            <p><small>Present this QR code at the event entrance</small></p>
-        </div>
-        <p>Thank you for your payment. Your registration is now complete.</p>
+        <p>Thank you for your payment. Your registration is now complete. Your ticket QR code is in its confirmation email.</p>
     </div>
     <div class="footer">
         <p>This is an automated payment confirmation.</p>
@@ -382,9 +456,9 @@ func (s *EmailService) SendPaymentConfirmation(user *models.User, eventName stri
     </div>
 </body>
 </html>
-`, user.FirstName, eventName, amount)
+`, user.FirstName, eventName, charges, breakdown.Total.Format(currency))
 
-	return s.sendEmailWithQR(user.Email, subject, body)
+	return s.sendEmail(user.Email, subject, body)
 }
 
 func (s *EmailService) SendEventCreatedConfirmation(user *models.User, event *models.Event) error {
@@ -633,4 +707,565 @@ func (s *EmailService) SendRegistrationCancellationNotification(user *models.Use
 	}())
 
 	return s.sendEmail(user.Email, subject, body)
-}
\ No newline at end of file
+}
+func (s *EmailService) SendEventInvitation(email, name string, event *models.Event, link string) error {
+	subject := "You're Invited - " + event.Title
+	if name == "" {
+		name = "there"
+	}
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #6F42C1;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #6F42C1;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+        .button {
+            display: inline-block;
+            padding: 10px 20px;
+            background-color: #6F42C1;
+            color: white;
+            text-decoration: none;
+            border-radius: 5px;
+            margin-top: 15px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>You're Invited! &#x1F48C;</h1>
+    </div>
+    <div class="content">
+        <p>Hi %s,</p>
+        <p>You have been invited to <strong>%s</strong>.</p>
+        <div class="details">
+            <h3>Event Details:</h3>
+            <p><strong>Event:</strong> %s</p>
+            <p><strong>Date:</strong> %s</p>
+            <p><strong>Venue:</strong> %s</p>
+        </div>
+        <p>Sign in with this email address to view the event and register.</p>
+        <center>
+            <a href="%s" class="button">View Event</a>
+        </center>
+    </div>
+    <div class="footer">
+        <p>This is an automated notification from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(name), template.HTMLEscapeString(event.Title), template.HTMLEscapeString(event.Title),
+		event.StartDatetime.Format("Monday, January 2, 2006 at 3:04 PM"), template.HTMLEscapeString(event.Venue), link)
+
+	return s.sendEmail(email, subject, body)
+}
+
+// joinInfoHTML renders the joining details block of a virtual event, or
+// nothing when there are none to send
+func joinInfoHTML(joinInfo *models.EventJoinInfo) string {
+	if joinInfo == nil {
+		return ""
+	}
+
+	details := ""
+	if joinInfo.Platform != "" {
+		details += fmt.Sprintf("<p><strong>Platform:</strong> %s</p>", template.HTMLEscapeString(joinInfo.Platform))
+	}
+	details += fmt.Sprintf(`<p><strong>Link:</strong> <a href="%s">%s</a></p>`, template.HTMLEscapeString(joinInfo.URL), template.HTMLEscapeString(joinInfo.URL))
+	if joinInfo.Passcode != "" {
+		details += fmt.Sprintf("<p><strong>Passcode:</strong> %s</p>", template.HTMLEscapeString(joinInfo.Passcode))
+	}
+	if joinInfo.Instructions != "" {
+		details += fmt.Sprintf("<p>%s</p>", template.HTMLEscapeString(joinInfo.Instructions))
+	}
+
+	return fmt.Sprintf(`<div class="details">
+            <h3>How to Join:</h3>
+            %s
+            <p><small>This link is for you only, please don't share it.</small></p>
+        </div>`, details)
+}
+
+func (s *EmailService) SendEventReminder(user *models.User, event *models.Event, joinInfo *models.EventJoinInfo) error {
+	subject := "Event Reminder - " + event.Title
+
+	location := "Virtual Event"
+	if !event.IsVirtual {
+		location = fmt.Sprintf("%s, %s", event.Venue, event.City)
+	}
+
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #2196F3;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #2196F3;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>See You Soon! &#x23F0;</h1>
+    </div>
+    <div class="content">
+        <p>Dear %s,</p>
+        <p>This is a reminder that <strong>%s</strong> is coming up.</p>
+        <div class="details">
+            <h3>Event Details:</h3>
+            <p><strong>Event:</strong> %s</p>
+            <p><strong>Date:</strong> %s</p>
+            <p><strong>Location:</strong> %s</p>
+        </div>
+        %s
+        <p>We look forward to seeing you there!</p>
+    </div>
+    <div class="footer">
+        <p>This is an automated reminder from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, user.FirstName, template.HTMLEscapeString(event.Title), template.HTMLEscapeString(event.Title),
+		event.StartDatetime.Format("Monday, January 2, 2006 at 3:04 PM"), template.HTMLEscapeString(location), joinInfoHTML(joinInfo))
+
+	return s.sendEmail(user.Email, subject, body)
+}
+
+// SendJoinInfoUpdated tells an attendee the old joining details no longer work
+func (s *EmailService) SendJoinInfoUpdated(user *models.User, event *models.Event, joinInfo *models.EventJoinInfo) error {
+	subject := "New Joining Details - " + event.Title
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #FF9800;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #FF9800;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>Joining Details Changed &#x1F511;</h1>
+    </div>
+    <div class="content">
+        <p>Dear %s,</p>
+        <p>The organizer of <strong>%s</strong> has changed how to join the event. The previous link and passcode will no longer work.</p>
+        %s
+        <p><strong>Date:</strong> %s</p>
+    </div>
+    <div class="footer">
+        <p>This is an automated notification from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, user.FirstName, template.HTMLEscapeString(event.Title), joinInfoHTML(joinInfo),
+		event.StartDatetime.Format("Monday, January 2, 2006 at 3:04 PM"))
+
+	return s.sendEmail(user.Email, subject, body)
+}
+
+// SendHoldExpiring warns a buyer that their unpaid tickets are about to be released
+func (s *EmailService) SendHoldExpiring(user *models.User, eventName string, tickets int, expiresAt time.Time) error {
+	subject := "Complete Your Registration - " + eventName
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #FF9800;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #FF9800;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>Your Tickets Are Waiting &#x23F3;</h1>
+    </div>
+    <div class="content">
+        <p>Dear %s,</p>
+        <p>We're holding tickets for <strong>%s</strong> for you, but they haven't been paid for yet.</p>
+        <div class="details">
+            <h3>Hold Details:</h3>
+            <p><strong>Event:</strong> %s</p>
+            <p><strong>Tickets:</strong> %d</p>
+            <p><strong>Held Until:</strong> %s</p>
+        </div>
+        <p>Please complete your payment before then, otherwise the tickets will be released to other buyers.</p>
+    </div>
+    <div class="footer">
+        <p>This is an automated reminder from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, user.FirstName, template.HTMLEscapeString(eventName), template.HTMLEscapeString(eventName),
+		tickets, expiresAt.Format("Monday, January 2, 2006 at 3:04 PM MST"))
+
+	return s.sendEmail(user.Email, subject, body)
+}
+
+// SendWaitlistOffer tells a user on the waitlist a ticket is being kept for them
+func (s *EmailService) SendWaitlistOffer(user *models.User, eventName, ticketType, claimLink string, expiresAt time.Time) error {
+	subject := "A Ticket Is Available - " + eventName
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #4CAF50;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #4CAF50;
+        }
+        .button {
+            display: inline-block;
+            padding: 10px 20px;
+            background-color: #4CAF50;
+            color: white;
+            text-decoration: none;
+            border-radius: 5px;
+            margin-top: 15px;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>You're Off the Waitlist! &#x1F389;</h1>
+    </div>
+    <div class="content">
+        <p>Dear %s,</p>
+        <p>A ticket for <strong>%s</strong> has become available and we're keeping it for you.</p>
+        <div class="details">
+            <h3>Offer Details:</h3>
+            <p><strong>Event:</strong> %s</p>
+            <p><strong>Ticket Type:</strong> %s</p>
+            <p><strong>Claim By:</strong> %s</p>
+        </div>
+        <p>If you don't claim it in time, it will be offered to the next person on the waitlist.</p>
+        <center>
+            <a href="%s" class="button">Claim Your Ticket</a>
+        </center>
+    </div>
+    <div class="footer">
+        <p>This is an automated notification from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, user.FirstName, template.HTMLEscapeString(eventName), template.HTMLEscapeString(eventName),
+		template.HTMLEscapeString(ticketType), expiresAt.Format("Monday, January 2, 2006 at 3:04 PM MST"),
+		template.HTMLEscapeString(claimLink))
+
+	return s.sendEmail(user.Email, subject, body)
+}
+
+// SendTicketTransfer tells someone a ticket is being passed on to them and how
+// to accept it
+func (s *EmailService) SendTicketTransfer(email, name, fromName string, event *models.Event, ticketType, link string, expiresAt time.Time) error {
+	subject := "You've Been Sent a Ticket - " + event.Title
+	if name == "" {
+		name = "there"
+	}
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #17A2B8;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #17A2B8;
+        }
+        .button {
+            display: inline-block;
+            padding: 10px 20px;
+            background-color: #17A2B8;
+            color: white;
+            text-decoration: none;
+            border-radius: 5px;
+            margin-top: 15px;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>A Ticket Is Waiting for You &#x1F39F;</h1>
+    </div>
+    <div class="content">
+        <p>Hi %s,</p>
+        <p>%s has sent you their ticket to <strong>%s</strong>.</p>
+        <div class="details">
+            <h3>Ticket Details:</h3>
+            <p><strong>Event:</strong> %s</p>
+            <p><strong>Date:</strong> %s</p>
+            <p><strong>Ticket Type:</strong> %s</p>
+            <p><strong>Accept By:</strong> %s</p>
+        </div>
+        <p>Accept it with this email address. If you don't have an account yet you can create one when you accept.</p>
+        <center>
+            <a href="%s" class="button">Accept Ticket</a>
+        </center>
+    </div>
+    <div class="footer">
+        <p>This is an automated notification from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(name), template.HTMLEscapeString(fromName), template.HTMLEscapeString(event.Title),
+		template.HTMLEscapeString(event.Title), event.StartDatetime.Format("Monday, January 2, 2006 at 3:04 PM"),
+		template.HTMLEscapeString(ticketType), expiresAt.Format("Monday, January 2, 2006 at 3:04 PM MST"),
+		template.HTMLEscapeString(link))
+
+	return s.sendEmail(email, subject, body)
+}
+
+// SendCertificate sends an attendee their certificate of attendance
+func (s *EmailService) SendCertificate(certificate *models.Certificate, event *models.Event, pdf []byte, verifyURL string) error {
+	subject := "Your Certificate of Attendance - " + event.Title
+	hours := ""
+	if certificate.CPDHours > 0 {
+		hours = fmt.Sprintf("<p><strong>CPD Hours:</strong> %s</p>", strconv.FormatFloat(certificate.CPDHours, 'f', -1, 64))
+	}
+	body := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #2196F3;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 8px 8px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 20px;
+            border: 1px solid #ddd;
+            border-radius: 0 0 8px 8px;
+        }
+        .details {
+            background-color: white;
+            padding: 15px;
+            margin: 15px 0;
+            border-left: 4px solid #2196F3;
+        }
+        .footer {
+            margin-top: 20px;
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>Thanks for Attending &#x1F393;</h1>
+    </div>
+    <div class="content">
+        <p>Hi %s,</p>
+        <p>Your certificate of attendance for <strong>%s</strong> is attached.</p>
+        <div class="details">
+            <h3>Certificate Details:</h3>
+            <p><strong>Event:</strong> %s</p>
+            <p><strong>Date:</strong> %s</p>
+            %s
+            <p><strong>Verification Code:</strong> %s</p>
+        </div>
+        <p>Anyone you share it with can check it's genuine at <a href="%s">%s</a>.</p>
+    </div>
+    <div class="footer">
+        <p>This is an automated notification from the Event Management System.</p>
+    </div>
+</body>
+</html>
+`, template.HTMLEscapeString(certificate.RecipientName), template.HTMLEscapeString(event.Title),
+		template.HTMLEscapeString(event.Title), event.StartDatetime.Format("Monday, January 2, 2006"),
+		hours, certificate.Code, template.HTMLEscapeString(verifyURL), template.HTMLEscapeString(verifyURL))
+
+	return s.sendEmailWithAttachment(certificate.RecipientEmail, subject, body, "certificate.pdf", pdf)
+}
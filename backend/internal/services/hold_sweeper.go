@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/config"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HoldSweeper releases tickets held by pending registrations that weren't
+// paid for in time, and warns buyers shortly before that happens. Released
+// tickets and unclaimed waitlist offers go to the next user on the waitlist.
+type HoldSweeper struct {
+	db           *gorm.DB
+	emailService *EmailService
+	waitlist     *Waitlist
+	interval     time.Duration
+	warnBefore   time.Duration
+}
+
+func NewHoldSweeper(db *gorm.DB, emailService *EmailService, waitlist *Waitlist, cfg *config.HoldConfig) *HoldSweeper {
+	return &HoldSweeper{
+		db:           db,
+		emailService: emailService,
+		waitlist:     waitlist,
+		interval:     cfg.SweepInterval,
+		warnBefore:   cfg.WarnBefore,
+	}
+}
+
+// Start sweeps in the background every interval for as long as the server runs
+func (s *HoldSweeper) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.Sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *HoldSweeper) Sweep() {
+	now := time.Now()
+	s.warnExpiring(now)
+	s.expireHolds(now)
+	s.waitlist.ExpireOffers(now)
+	s.waitlist.PromoteAll()
+}
+
+// hold is the tickets of one registration, or of a whole order, that lapse together
+type hold struct {
+	registration models.Registration
+	tickets      int
+}
+
+// groupHolds merges the tickets of an order into a single hold so buyers get
+// one warning per checkout rather than one per ticket
+func groupHolds(registrations []models.Registration) []*hold {
+	var holds []*hold
+	orders := make(map[uint]*hold)
+	for _, registration := range registrations {
+		if registration.OrderID != nil {
+			if h, ok := orders[*registration.OrderID]; ok {
+				h.tickets++
+				continue
+			}
+		}
+		h := &hold{registration: registration, tickets: 1}
+		if registration.OrderID != nil {
+			orders[*registration.OrderID] = h
+		}
+		holds = append(holds, h)
+	}
+	return holds
+}
+
+func (s *HoldSweeper) warnExpiring(now time.Time) {
+	registrations, err := models.FindHoldsExpiringBefore(s.db, now, now.Add(s.warnBefore))
+	if err != nil {
+		log.Printf("Failed to find expiring holds: %v", err)
+		return
+	}
+
+	for _, h := range groupHolds(registrations) {
+		r := h.registration
+		query := s.db.Model(&models.Registration{}).Where("id = ?", r.ID)
+		if r.OrderID != nil {
+			query = s.db.Model(&models.Registration{}).Where("order_id = ? AND status = ?", *r.OrderID, models.RegistrationStatusPending)
+		}
+		if err := query.Update("hold_reminder_sent_at", now).Error; err != nil {
+			log.Printf("Failed to mark hold of registration %d as warned: %v", r.ID, err)
+			continue
+		}
+
+		event, err := models.FindEventByID(s.db, r.EventID)
+		if err != nil {
+			continue
+		}
+		models.CreateNotification(s.db, r.UserID, &r.EventID, "Your tickets are about to be released",
+			fmt.Sprintf("Your tickets for %s are held until %s. Complete your payment before then to keep them.",
+				event.Title, r.ExpiresAt.Format("3:04 PM")),
+			models.NotificationTypeHold)
+
+		user, err := models.FindUserByID(s.db, r.UserID)
+		if err != nil || s.emailService == nil {
+			continue
+		}
+		if err := s.emailService.SendHoldExpiring(user, event.Title, h.tickets, *r.ExpiresAt); err != nil {
+			log.Printf("Failed to send hold warning to %s: %v", user.Email, err)
+		}
+	}
+}
+
+func (s *HoldSweeper) expireHolds(now time.Time) {
+	registrations, err := models.FindExpiredHolds(s.db, now)
+	if err != nil {
+		log.Printf("Failed to find expired holds: %v", err)
+		return
+	}
+
+	for _, h := range groupHolds(registrations) {
+		r := h.registration
+		expired, err := r.ExpireHold(s.db)
+		if err != nil {
+			log.Printf("Failed to release hold of registration %d: %v", r.ID, err)
+			continue
+		}
+		if !expired {
+			continue
+		}
+
+		title := "your event"
+		if event, err := models.FindEventByID(s.db, r.EventID); err == nil {
+			title = event.Title
+		}
+		models.CreateNotification(s.db, r.UserID, &r.EventID, "Your tickets were released",
+			fmt.Sprintf("Your hold on %d ticket(s) for %s expired before payment was received, so they've been released.", h.tickets, title),
+			models.NotificationTypeHold)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const maxImagePixels = 40_000_000
+
+var (
+	ErrUnsupportedImageType = errors.New("unsupported image type, allowed types are JPEG, PNG, GIF and WebP")
+	ErrImageTooLarge        = errors.New("image dimensions are too large")
+)
+
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// ProcessedImage holds a validated upload and its generated thumbnails
+type ProcessedImage struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Variants    map[string][]byte // jpeg encoded, keyed by variant name
+}
+
+// ProcessImage sniffs the real type of an uploaded image, rejects anything
+// that isn't a supported image and renders a JPEG thumbnail for each entry in
+// variants (name -> max width). Images narrower than a variant are not upscaled.
+func ProcessImage(data []byte, variants map[string]int) (*ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedImageType
+	}
+
+	// check the header before decoding so huge images can't exhaust memory
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImageType
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	processed := &ProcessedImage{
+		ContentType: contentType,
+		Extension:   ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Variants:    make(map[string][]byte, len(variants)),
+	}
+
+	for name, maxWidth := range variants {
+		thumb, err := resizeToJPEG(src, maxWidth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s thumbnail: %w", name, err)
+		}
+		processed.Variants[name] = thumb
+	}
+
+	return processed, nil
+}
+
+func resizeToJPEG(src image.Image, maxWidth int) ([]byte, error) {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	// paint onto white so transparent PNGs don't come out black
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Printer renders tickets and name badges as PDFs
+type Printer struct {
+	tickets *TicketSigner
+}
+
+func NewPrinter(tickets *TicketSigner) *Printer {
+	return &Printer{tickets: tickets}
+}
+
+// TicketDocument is what's printed on a ticket
+type TicketDocument struct {
+	Event        *models.Event
+	Registration *models.Registration
+	TicketType   string
+	AttendeeName string
+}
+
+// CertificateDocument is what's printed on a certificate of attendance
+type CertificateDocument struct {
+	Event       *models.Event
+	Certificate *models.Certificate
+	Organizer   string
+	// where the certificate's code can be checked
+	VerifyURL string
+}
+
+// Badge is one attendee's name badge
+type Badge struct {
+	Registration *models.Registration
+	Name         string
+	TicketType   string
+}
+
+// TicketPDF renders a single A4 page ticket with the event's details and the
+// ticket's QR code
+func (p *Printer) TicketPDF(doc TicketDocument) ([]byte, error) {
+	qr, err := p.tickets.QRCode(doc.Registration)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(doc.Event.Title+" ticket", true)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	// header band
+	pdf.SetFillColor(33, 150, 243)
+	pdf.Rect(0, 0, 210, 40, "F")
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont("Helvetica", "B", 22)
+	pdf.SetXY(15, 12)
+	pdf.CellFormat(180, 10, tr(truncate(pdf, doc.Event.Title, 180)), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetX(15)
+	pdf.CellFormat(180, 8, "Admission ticket", "", 1, "L", false, 0, "")
+
+	pdf.SetTextColor(51, 51, 51)
+	y := 55.0
+	field := func(label, value string) {
+		if value == "" {
+			return
+		}
+		pdf.SetXY(15, y)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(100, 5, strings.ToUpper(label), "", 1, "L", false, 0, "")
+		pdf.SetX(15)
+		pdf.SetFont("Helvetica", "", 13)
+		pdf.MultiCell(100, 6, tr(value), "", "L", false)
+		y = pdf.GetY() + 5
+	}
+	field("Attendee", doc.AttendeeName)
+	field("Ticket type", doc.TicketType)
+	field("Date", doc.Event.StartDatetime.Format("Monday, January 2, 2006 at 3:04 PM"))
+	if doc.Event.IsVirtual {
+		field("Location", "Online")
+	} else {
+		field("Venue", doc.Event.Venue)
+		field("Address", joinNonEmpty(", ", doc.Event.Address, doc.Event.City, doc.Event.State, doc.Event.Country))
+	}
+	field("Ticket number", strconv.FormatUint(uint64(doc.Registration.ID), 10))
+
+	pdf.RegisterImageOptionsReader("qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qr))
+	pdf.ImageOptions("qr", 125, 52, 70, 70, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	pdf.SetXY(125, 124)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(70, 5, "Present this QR code at the entrance", "", 0, "C", false, 0, "")
+
+	// below whichever of the details and the QR code runs longer
+	footer := max(y, 135)
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.Line(15, footer, 195, footer)
+	pdf.SetXY(15, footer+3)
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(102, 102, 102)
+	pdf.MultiCell(180, 4, "This ticket is valid for one entry. It stops working if it's transferred to someone else "+
+		"or canceled, so keep it to yourself.", "", "L", false)
+
+	return output(pdf)
+}
+
+// BadgesPDF lays out a name badge for each attendee using the template
+func (p *Printer) BadgesPDF(event *models.Event, template *models.BadgeTemplate, badges []Badge) ([]byte, error) {
+	layout, ok := models.BadgeLayouts[template.Layout]
+	if !ok {
+		return nil, fmt.Errorf("unknown badge layout %q", template.Layout)
+	}
+	r, g, b := hexRGB(template.AccentColor)
+	heading := template.Heading
+	if heading == "" {
+		heading = event.Title
+	}
+
+	// always portrait, fpdf swaps a custom size's sides for landscape and the
+	// layout already gives them the way round they're printed
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           fpdf.SizeType{Wd: layout.PageWidth, Ht: layout.PageHeight},
+	})
+	pdf.SetTitle(event.Title+" badges", true)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// badges are centred on the page in a grid
+	marginX := (layout.PageWidth - layout.BadgeWidth*float64(layout.Columns)) / 2
+	marginY := (layout.PageHeight - layout.BadgeHeight*float64(layout.Rows)) / 2
+	perPage := layout.Columns * layout.Rows
+	band := layout.BadgeHeight * 0.22
+
+	if len(badges) == 0 {
+		pdf.AddPage()
+	}
+	for i, badge := range badges {
+		if i%perPage == 0 {
+			pdf.AddPage()
+		}
+		slot := i % perPage
+		x := marginX + float64(slot%layout.Columns)*layout.BadgeWidth
+		y := marginY + float64(slot/layout.Columns)*layout.BadgeHeight
+
+		// cut lines
+		pdf.SetDrawColor(200, 200, 200)
+		pdf.SetLineWidth(0.2)
+		pdf.Rect(x, y, layout.BadgeWidth, layout.BadgeHeight, "D")
+
+		pdf.SetFillColor(r, g, b)
+		pdf.Rect(x, y, layout.BadgeWidth, band, "F")
+		pdf.SetTextColor(255, 255, 255)
+		pdf.SetFont("Helvetica", "B", band*1.4)
+		pdf.SetXY(x+4, y)
+		pdf.CellFormat(layout.BadgeWidth-8, band, tr(truncate(pdf, heading, layout.BadgeWidth-8)), "", 0, "C", false, 0, "")
+
+		// the QR code takes the right of the badge, the name the rest
+		textWidth := layout.BadgeWidth - 8
+		qrSize := 0.0
+		if template.ShowQRCode && badge.Registration != nil {
+			qrSize = min(layout.BadgeHeight-band-16, layout.BadgeWidth*0.35)
+			if qr, err := p.tickets.QRCode(badge.Registration); err == nil {
+				name := fmt.Sprintf("qr-%d", badge.Registration.ID)
+				pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qr))
+				pdf.ImageOptions(name, x+layout.BadgeWidth-qrSize-4, y+band+4, qrSize, qrSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+				textWidth -= qrSize + 2
+			}
+		}
+
+		pdf.SetTextColor(33, 33, 33)
+		nameSize := fitFontSize(pdf, tr(badge.Name), textWidth, layout.BadgeHeight*0.55)
+		pdf.SetFont("Helvetica", "B", nameSize)
+		pdf.SetXY(x+4, y+band+(layout.BadgeHeight-band)*0.3)
+		pdf.CellFormat(textWidth, nameSize*0.45, tr(truncate(pdf, badge.Name, textWidth)), "", 2, "C", false, 0, "")
+
+		if template.ShowTicketType && badge.TicketType != "" {
+			pdf.SetFont("Helvetica", "", 11)
+			pdf.SetTextColor(r, g, b)
+			pdf.SetX(x + 4)
+			pdf.CellFormat(textWidth, 8, tr(truncate(pdf, badge.TicketType, textWidth)), "", 0, "C", false, 0, "")
+		}
+
+		if template.Footer != "" {
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetTextColor(102, 102, 102)
+			pdf.SetXY(x+4, y+layout.BadgeHeight-8)
+			pdf.CellFormat(layout.BadgeWidth-8, 5, tr(truncate(pdf, template.Footer, layout.BadgeWidth-8)), "", 0, "C", false, 0, "")
+		}
+	}
+
+	return output(pdf)
+}
+
+// CertificatePDF renders a landscape A4 certificate of attendance
+func (p *Printer) CertificatePDF(doc CertificateDocument) ([]byte, error) {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetTitle("Certificate of attendance - "+doc.Event.Title, true)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	// double border
+	pdf.SetDrawColor(33, 150, 243)
+	pdf.SetLineWidth(1.5)
+	pdf.Rect(10, 10, 277, 190, "D")
+	pdf.SetLineWidth(0.4)
+	pdf.Rect(14, 14, 269, 182, "D")
+
+	centered := func(y, size float64, style, text string) {
+		pdf.SetFont("Helvetica", style, size)
+		pdf.SetXY(25, y)
+		pdf.CellFormat(247, size*0.5, tr(truncate(pdf, text, 247)), "", 0, "C", false, 0, "")
+	}
+
+	pdf.SetTextColor(33, 150, 243)
+	centered(32, 32, "B", "Certificate of Attendance")
+	pdf.SetTextColor(102, 102, 102)
+	centered(58, 13, "", "This is to certify that")
+	pdf.SetTextColor(33, 33, 33)
+	centered(72, 30, "B", doc.Certificate.RecipientName)
+	pdf.SetTextColor(102, 102, 102)
+	centered(96, 13, "", "attended")
+	pdf.SetTextColor(33, 33, 33)
+	centered(108, 20, "B", doc.Event.Title)
+
+	when := doc.Event.StartDatetime.Format("January 2, 2006")
+	if end := doc.Event.EndDatetime.Format("January 2, 2006"); end != when {
+		when += " to " + end
+	}
+	if !doc.Event.IsVirtual && doc.Event.Venue != "" {
+		when += " at " + joinNonEmpty(", ", doc.Event.Venue, doc.Event.City)
+	}
+	pdf.SetTextColor(102, 102, 102)
+	centered(124, 12, "", when)
+	if doc.Certificate.CPDHours > 0 {
+		pdf.SetTextColor(33, 33, 33)
+		centered(136, 13, "B", fmt.Sprintf("%s CPD hours", strconv.FormatFloat(doc.Certificate.CPDHours, 'f', -1, 64)))
+	}
+
+	// organizer on the left, verification on the right
+	pdf.SetDrawColor(150, 150, 150)
+	pdf.SetLineWidth(0.3)
+	pdf.Line(35, 170, 115, 170)
+	pdf.SetTextColor(51, 51, 51)
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetXY(35, 172)
+	pdf.CellFormat(80, 6, tr(truncate(pdf, doc.Organizer, 80)), "", 2, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(102, 102, 102)
+	pdf.CellFormat(80, 5, "Organizer", "", 0, "C", false, 0, "")
+
+	pdf.SetXY(170, 164)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(95, 5, "Issued "+doc.Certificate.IssuedAt.Format("January 2, 2006"), "", 2, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.SetTextColor(51, 51, 51)
+	pdf.CellFormat(95, 6, "Verification code: "+doc.Certificate.Code, "", 2, "R", false, 0, "")
+	if doc.VerifyURL != "" {
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(102, 102, 102)
+		pdf.CellFormat(95, 5, tr(truncate(pdf, "Verify at "+doc.VerifyURL, 95)), "", 0, "R", false, 0, "")
+	}
+
+	return output(pdf)
+}
+
+func output(pdf *fpdf.Fpdf) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitFontSize is the largest font size up to largest points that fits text in
+// width, names too long even at 10 points are truncated
+func fitFontSize(pdf *fpdf.Fpdf, text string, width, largest float64) float64 {
+	size := largest
+	for size > 10 {
+		pdf.SetFont("Helvetica", "B", size)
+		if pdf.GetStringWidth(text) <= width {
+			break
+		}
+		size--
+	}
+	return size
+}
+
+// truncate shortens text with an ellipsis to fit width in the current font
+func truncate(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+func hexRGB(color string) (int, int, int) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return 33, 150, 243
+	}
+	return int(value >> 16 & 0xff), int(value >> 8 & 0xff), int(value & 0xff)
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/config"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// ticketPrefix starts every ticket credential, and changes if the format does
+const ticketPrefix = "T1"
+
+var ErrInvalidTicket = errors.New("this ticket isn't valid")
+
+// TicketClaims is what a ticket credential says about the ticket. Scanners
+// can trust it without asking the server once the signature checks out, but
+// only the server knows if the ticket has since been canceled or transferred
+// and given a new code.
+type TicketClaims struct {
+	RegistrationID uint   `json:"rid"`
+	EventID        uint   `json:"eid"`
+	TicketTypeID   uint   `json:"tid"`
+	Code           string `json:"code"`
+	Name           string `json:"name,omitempty"`
+	IssuedAt       int64  `json:"iat"`
+}
+
+// TicketSigner issues the signed credentials in ticket QR codes. A credential
+// is "T1.<key id>.<claims>.<signature>" with the claims as base64url JSON and
+// an Ed25519 signature over everything before it.
+type TicketSigner struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewTicketSigner signs with the configured key, or the one in the key file.
+// If there's neither a key is generated and saved to the key file so tickets
+// already sent keep working after a restart.
+func NewTicketSigner(cfg *config.TicketConfig) (*TicketSigner, error) {
+	seed, err := loadTicketKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("ticket signing key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &TicketSigner{key: key, keyID: hex.EncodeToString(sum[:4])}, nil
+}
+
+func loadTicketKey(cfg *config.TicketConfig) ([]byte, error) {
+	if cfg.SigningKey != "" {
+		return base64.StdEncoding.DecodeString(cfg.SigningKey)
+	}
+
+	data, err := os.ReadFile(cfg.KeyFile)
+	if err == nil {
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read ticket key file: %w", err)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(cfg.KeyFile, []byte(base64.StdEncoding.EncodeToString(seed)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save ticket key file: %w", err)
+	}
+	log.Printf("Generated a new ticket signing key in %s", cfg.KeyFile)
+	return seed, nil
+}
+
+// KeyID identifies the key tickets are signed with
+func (s *TicketSigner) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey is what scanners check ticket signatures against
+func (s *TicketSigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign issues the credential for the registration's current ticket code
+func (s *TicketSigner) Sign(registration *models.Registration) (string, error) {
+	if registration.TicketCode == "" {
+		return "", errors.New("registration has no ticket code")
+	}
+	claims, err := json.Marshal(TicketClaims{
+		RegistrationID: registration.ID,
+		EventID:        registration.EventID,
+		TicketTypeID:   registration.TicketTypeID,
+		Code:           registration.TicketCode,
+		Name:           registration.AttendeeName,
+		IssuedAt:       time.Now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := ticketPrefix + "." + s.keyID + "." + base64.RawURLEncoding.EncodeToString(claims)
+	signature := ed25519.Sign(s.key, []byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks a credential was signed by this server and returns its claims
+func (s *TicketSigner) Verify(credential string) (*TicketClaims, error) {
+	parts := strings.Split(strings.TrimSpace(credential), ".")
+	if len(parts) != 4 || parts[0] != ticketPrefix || parts[1] != s.keyID {
+		return nil, ErrInvalidTicket
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrInvalidTicket
+	}
+	if !ed25519.Verify(s.PublicKey(), []byte(strings.Join(parts[:3], ".")), signature) {
+		return nil, ErrInvalidTicket
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidTicket
+	}
+	var claims TicketClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, ErrInvalidTicket
+	}
+	return &claims, nil
+}
+
+// SignData signs anything else scanners need to trust, such as check-in
+// manifests, returning the signature as unpadded base64url
+func (s *TicketSigner) SignData(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.key, data))
+}
+
+// QRCode renders the registration's credential as a PNG
+func (s *TicketSigner) QRCode(registration *models.Registration) ([]byte, error) {
+	credential, err := s.Sign(registration)
+	if err != nil {
+		return nil, err
+	}
+	return qrcode.Encode(credential, qrcode.Medium, 320)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"lujke-dunn/314-group-project/backend/internal/config"
+	"lujke-dunn/314-group-project/backend/internal/models"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Waitlist offers released tickets to the users waiting for them
+type Waitlist struct {
+	db           *gorm.DB
+	emailService *EmailService
+	frontendURL  string
+	claimWindow  time.Duration
+}
+
+func NewWaitlist(db *gorm.DB, emailService *EmailService, frontendURL string, cfg *config.WaitlistConfig) *Waitlist {
+	return &Waitlist{
+		db:           db,
+		emailService: emailService,
+		frontendURL:  frontendURL,
+		claimWindow:  cfg.ClaimWindow,
+	}
+}
+
+// Promote offers every free ticket of the type to the users who have been
+// waiting longest
+func (w *Waitlist) Promote(ticketTypeID uint) {
+	for {
+		var entry *models.WaitlistEntry
+		err := w.db.Transaction(func(tx *gorm.DB) error {
+			next, err := models.NextOnWaitlist(tx, ticketTypeID)
+			if err != nil {
+				return err
+			}
+			if err := next.Offer(tx, w.claimWindow); err != nil {
+				return err
+			}
+			entry = next
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, models.ErrSoldOut) {
+				log.Printf("Failed to promote waitlist of ticket type %d: %v", ticketTypeID, err)
+			}
+			return
+		}
+		w.sendOffer(entry)
+	}
+}
+
+// PromoteAll promotes every ticket type someone is waiting for, picking up
+// tickets released outside a request such as by an expired hold
+func (w *Waitlist) PromoteAll() {
+	ids, err := models.WaitlistedTicketTypes(w.db)
+	if err != nil {
+		log.Printf("Failed to find waitlisted ticket types: %v", err)
+		return
+	}
+	for _, id := range ids {
+		w.Promote(id)
+	}
+}
+
+// OfferTo offers a ticket to the entry straight away, ahead of its place in the queue
+func (w *Waitlist) OfferTo(entry *models.WaitlistEntry) error {
+	if err := w.db.Transaction(func(tx *gorm.DB) error {
+		return entry.Offer(tx, w.claimWindow)
+	}); err != nil {
+		return err
+	}
+	w.sendOffer(entry)
+	return nil
+}
+
+// ExpireOffers takes back tickets that weren't claimed in time and offers
+// them to the next in line
+func (w *Waitlist) ExpireOffers(now time.Time) {
+	entries, err := models.FindExpiredOffers(w.db, now)
+	if err != nil {
+		log.Printf("Failed to find expired waitlist offers: %v", err)
+		return
+	}
+
+	released := make(map[uint]bool)
+	for i := range entries {
+		entry := &entries[i]
+		if err := entry.Close(w.db, models.WaitlistStatusExpired); err != nil {
+			log.Printf("Failed to expire waitlist offer %d: %v", entry.ID, err)
+			continue
+		}
+		models.CreateNotification(w.db, entry.UserID, &entry.EventID, "Your waitlist offer expired",
+			"The ticket offered to you from the waitlist wasn't claimed in time, so it's been offered to the next person.",
+			models.NotificationTypeWaitlist)
+		released[entry.TicketTypeID] = true
+	}
+
+	for ticketTypeID := range released {
+		w.Promote(ticketTypeID)
+	}
+}
+
+func (w *Waitlist) sendOffer(entry *models.WaitlistEntry) {
+	event, err := models.FindEventByID(w.db, entry.EventID)
+	if err != nil {
+		return
+	}
+	ticketType, err := models.FindTicketTypeByID(w.db, entry.TicketTypeID)
+	if err != nil {
+		return
+	}
+
+	models.CreateNotification(w.db, entry.UserID, &entry.EventID, "A ticket is available",
+		fmt.Sprintf("A %s ticket for %s has been kept for you until %s. Claim it before then or it goes to the next person on the waitlist.",
+			ticketType.Name, event.Title, entry.OfferExpiresAt.Format("Jan 2 3:04 PM")),
+		models.NotificationTypeWaitlist)
+
+	user, err := models.FindUserByID(w.db, entry.UserID)
+	if err != nil || w.emailService == nil {
+		return
+	}
+	link := fmt.Sprintf("%s/waitlist/claim?token=%s", w.frontendURL, url.QueryEscape(entry.ClaimToken))
+	go func() {
+		if err := w.emailService.SendWaitlistOffer(user, event.Title, ticketType.Name, link, *entry.OfferExpiresAt); err != nil {
+			log.Printf("Failed to send waitlist offer to %s: %v", user.Email, err)
+		}
+	}()
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a stand-in for an S3 compatible API that keeps objects in memory
+// and rejects requests that aren't signed the way S3 expects
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{t: t, objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") ||
+		!strings.Contains(auth, "/ap-southeast-2/s3/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=") || !strings.Contains(auth, "Signature=") {
+		http.Error(w, "bad authorization "+auth, http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		http.Error(w, "bad payload hash", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestS3(t *testing.T, endpoint, publicURL string) *S3Storage {
+	t.Helper()
+	s, err := NewS3Storage(S3Options{
+		Endpoint:      endpoint + "/",
+		Region:        "ap-southeast-2",
+		Bucket:        "media",
+		AccessKey:     "access",
+		SecretKey:     "secret",
+		PublicBaseURL: publicURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestS3PutAndDelete(t *testing.T) {
+	fake, server := newFakeS3(t)
+	s := newTestS3(t, server.URL, "")
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "events/1/cover.jpg", []byte("image"), "image/jpeg"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got := string(fake.objects["/media/events/1/cover.jpg"]); got != "image" {
+		t.Fatalf("stored %q, want %q", got, "image")
+	}
+	if got := fake.types["/media/events/1/cover.jpg"]; got != "image/jpeg" {
+		t.Fatalf("content type %q, want image/jpeg", got)
+	}
+
+	if err := s.Delete(ctx, "events/1/cover.jpg"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.Delete(ctx, "events/1/cover.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting a missing object returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3ErrorResponse(t *testing.T) {
+	_, server := newFakeS3(t)
+	s := newTestS3(t, server.URL, "")
+	s.opts.Region = "us-east-1"
+
+	err := s.Put(context.Background(), "events/1/cover.jpg", []byte("image"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("put with the wrong region returned %v, want a 403 error", err)
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	s := newTestS3(t, "http://localhost:9000", "")
+	if got, want := s.URL("events/1/cover.jpg"), "http://localhost:9000/media/events/1/cover.jpg"; got != want {
+		t.Errorf("URL without a public base = %q, want %q", got, want)
+	}
+
+	s = newTestS3(t, "http://localhost:9000", "https://cdn.example.com/")
+	if got, want := s.URL("events/1/cover.jpg"), "https://cdn.example.com/events/1/cover.jpg"; got != want {
+		t.Errorf("URL with a public base = %q, want %q", got, want)
+	}
+}
+
+func TestNewS3StorageRequiresBucket(t *testing.T) {
+	if _, err := NewS3Storage(S3Options{Endpoint: "http://localhost:9000"}); err == nil {
+		t.Fatal("expected an error without a bucket")
+	}
+}
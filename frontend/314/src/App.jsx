@@ -9,6 +9,8 @@ import EventList from './components/EventList';
 import TicketTypeForm from './components/TicketTypeForm';
 import RegistrationConfirmation from './components/RegistrationConfirmation';
 import RegistrationList from './components/RegistrationList';
+import TransferAccept from './components/TransferAccept';
+import WaitlistClaim from './components/WaitlistClaim';
 
 function App() {
   return (
@@ -25,6 +27,8 @@ function App() {
         <Route path="/events/:id/ticket-types/create" element={<TicketTypeForm />} />
         <Route path="/registrations" element={<RegistrationList />} />
         <Route path="/registrations/:id" element={<RegistrationConfirmation />} />
+        <Route path="/transfers/accept" element={<TransferAccept />} />
+        <Route path="/waitlist/claim" element={<WaitlistClaim />} />
       </Routes>
     </div>
   );
@@ -22,7 +22,9 @@ function EventList() {
     max_price: ''
   });
   const [currentPage, setCurrentPage] = useState(1);
-  const [totalPages, setTotalPages] = useState(1);
+  // cursors[i] is the cursor that loads page i + 1; page 1 has none
+  const [cursors, setCursors] = useState(['']);
+  const [hasMore, setHasMore] = useState(false);
   
   useEffect(() => {
     fetchEvents();
@@ -34,10 +36,13 @@ function EventList() {
     
     try {
       const params = new URLSearchParams({
-        page: currentPage.toString(),
-        per_page: '10'
+        limit: '10'
       });
       
+      if (cursors[currentPage - 1]) {
+        params.append('cursor', cursors[currentPage - 1]);
+      }
+      
       if (searchQuery.trim()) {
         params.append('query', searchQuery.trim());
       }
@@ -72,7 +77,9 @@ function EventList() {
       
       const response = await api.get(`/events?${params.toString()}`);
       setEvents(response.data.events || []);
-      setTotalPages(response.data.total_pages || 1);
+      const nextCursor = response.data.pagination?.next_cursor || '';
+      setHasMore(Boolean(nextCursor));
+      setCursors(prev => [...prev.slice(0, currentPage), nextCursor]);
       setLoading(false);
     } catch (err) {
       console.error('Failed to fetch events:', err);
@@ -83,11 +90,13 @@ function EventList() {
   
   const handleSearchChange = (e) => {
     setSearchQuery(e.target.value);
+    setCursors(['']);
     setCurrentPage(1);
   };
   
   const handleFilterChange = (field, value) => {
     setFilters(prev => ({ ...prev, [field]: value }));
+    setCursors(['']);
     setCurrentPage(1);
   };
   
@@ -102,6 +111,7 @@ function EventList() {
       min_price: '',
       max_price: ''
     });
+    setCursors(['']);
     setCurrentPage(1);
   };
   
@@ -352,7 +362,7 @@ function EventList() {
         </div>
       )}
       {"handle pagination with large quantity of events "}
-      {totalPages > 1 && (
+      {(currentPage > 1 || hasMore) && (
         <div className="pagination">
           <button
             onClick={() => handlePageChange(currentPage - 1)}
@@ -363,31 +373,14 @@ function EventList() {
           </button>
           
           <div className="page-numbers">
-            {Array.from({ length: totalPages }, (_, i) => i + 1)
-              .filter(page => {
-                return page === 1 || 
-                       page === totalPages || 
-                       Math.abs(page - currentPage) <= 1;
-              })
-              .map((page, index, array) => {
-                const showEllipsis = index > 0 && page - array[index - 1] > 1;
-                return (
-                  <div key={page}>
-                    {showEllipsis && <span className="pagination-ellipsis">...</span>}
-                    <button
-                      onClick={() => handlePageChange(page)}
-                      className={`pagination-number ${currentPage === page ? 'active' : ''}`}
-                    >
-                      {page}
-                    </button>
-                  </div>
-                );
-              })}
+            <button className="pagination-number active">
+              {currentPage}
+            </button>
           </div>
           
           <button
             onClick={() => handlePageChange(currentPage + 1)}
-            disabled={currentPage === totalPages}
+            disabled={!hasMore}
             className="pagination-button"
           >
             Next →
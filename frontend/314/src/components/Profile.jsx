@@ -61,7 +61,7 @@ function Profile() {
     const fetchData = async () => {
       try {
         const regResponse = await api.get('/registrations');
-        setRegistrations(regResponse.data || []);
+        setRegistrations(regResponse.data.registrations || []);
         setLoading(prev => ({ ...prev, registrations: false }));
       } catch (err) {
         console.error('Failed to fetch registrations:', err);
@@ -93,7 +93,7 @@ function Profile() {
       
       try {
         const feedbackResponse = await api.get('/feedback');
-        setFeedbacks(feedbackResponse.data || []);
+        setFeedbacks(feedbackResponse.data.feedbacks || []);
         setLoading(prev => ({ ...prev, feedback: false }));
       } catch (err) {
         console.error('Failed to fetch feedback:', err);
@@ -23,8 +23,8 @@ function RegistrationList() {
     const fetchRegistrations = async () => {
       try {
         const response = await api.get('/registrations');
-        setRegistrations(response.data);
-        setFilteredRegistrations(response.data);
+        setRegistrations(response.data.registrations || []);
+        setFilteredRegistrations(response.data.registrations || []);
         setLoading(false);
       } catch (err) {
         console.error('Failed to fetch registrations:', err);
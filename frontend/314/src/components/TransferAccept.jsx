@@ -0,0 +1,173 @@
+import { useState, useEffect } from 'react';
+import { useSearchParams, useNavigate, Link } from 'react-router-dom';
+import { useAuth } from '../AuthContext';
+import api from '../api';
+import './TransferAccept.css';
+
+// landing page for the link in a ticket transfer email
+function TransferAccept() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const navigate = useNavigate();
+  const { user, isAuthenticated, refreshUser } = useAuth();
+
+  const [transfer, setTransfer] = useState(null);
+  const [formData, setFormData] = useState({ first_name: '', last_name: '', password: '' });
+  const [loading, setLoading] = useState(true);
+  const [submitting, setSubmitting] = useState(false);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    if (!token) {
+      setError('This transfer link is missing its token.');
+      setLoading(false);
+      return;
+    }
+
+    const fetchTransfer = async () => {
+      try {
+        const response = await api.get(`/transfers/${encodeURIComponent(token)}`);
+        setTransfer(response.data);
+      } catch (err) {
+        console.error('Failed to fetch transfer:', err);
+        setError(err.response?.data?.error || 'Failed to load this transfer. Please try again later.');
+      }
+      setLoading(false);
+    };
+
+    fetchTransfer();
+  }, [token]);
+
+  const handleChange = (e) => {
+    setFormData({ ...formData, [e.target.name]: e.target.value });
+  };
+
+  const handleAccept = async (e) => {
+    e.preventDefault();
+    setSubmitting(true);
+    setError('');
+
+    try {
+      const body = isAuthenticated ? { token } : { token, ...formData };
+      const response = await api.post('/transfers/accept', body);
+
+      // a new account comes back logged in
+      if (response.data.token) {
+        localStorage.setItem('token', response.data.token);
+        api.defaults.headers.common['Authorization'] = `Bearer ${response.data.token}`;
+        const profile = await refreshUser();
+        localStorage.setItem('user', JSON.stringify(profile));
+      }
+
+      navigate(`/registrations/${response.data.registration.id}`);
+    } catch (err) {
+      console.error('Failed to accept transfer:', err);
+      setError(err.response?.data?.error || 'Failed to accept the ticket. Please try again later.');
+      setSubmitting(false);
+    }
+  };
+
+  const formatDate = (dateString) => {
+    if (!dateString) return 'TBA';
+    const date = new Date(dateString);
+    return date.toLocaleDateString() + ' at ' + date.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+  };
+
+  if (loading) {
+    return (
+      <div className="transfer-wrapper">
+        <div className="transfer-container">
+          <div className="loading-state">
+            <div className="loading-spinner"></div>
+            <p>Loading transfer details...</p>
+          </div>
+        </div>
+      </div>
+    );
+  }
+
+  if (!transfer) {
+    return (
+      <div className="transfer-wrapper">
+        <div className="transfer-container">
+          <div className="error-state">
+            <span className="error-icon">&#9888;&#65039;</span>
+            {error}
+          </div>
+        </div>
+      </div>
+    );
+  }
+
+  const wrongAccount = isAuthenticated && user.email?.toLowerCase() !== transfer.to_email;
+
+  return (
+    <div className="transfer-wrapper">
+      <div className="transfer-container">
+        <div className="transfer-header">
+          <h1 className="transfer-title">You've been sent a ticket</h1>
+          <p className="transfer-subtitle">
+            {transfer.from_name || 'Someone'} sent a {transfer.ticket_type} ticket to {transfer.to_email}
+          </p>
+        </div>
+
+        <section className="transfer-card">
+          <div className="detail-label">Event</div>
+          <div className="detail-value">{transfer.event.title}</div>
+          <div className="detail-label">Date & Time</div>
+          <div className="detail-value">{formatDate(transfer.event.start_datetime)}</div>
+          <div className="detail-label">Location</div>
+          <div className="detail-value">{transfer.event.venue}</div>
+          <div className="detail-label">Offer Expires</div>
+          <div className="detail-value">{formatDate(transfer.expires_at)}</div>
+        </section>
+
+        {error && (
+          <div className="error-state">
+            <span className="error-icon">&#9888;&#65039;</span>
+            {error}
+          </div>
+        )}
+
+        {transfer.status !== 'pending' ? (
+          <p className="transfer-message">This transfer is {transfer.status} and can no longer be accepted.</p>
+        ) : wrongAccount ? (
+          <p className="transfer-message">
+            This ticket was sent to {transfer.to_email}. Log in with that account to accept it.
+          </p>
+        ) : isAuthenticated ? (
+          <form onSubmit={handleAccept} className="transfer-form">
+            <button type="submit" className="action-button primary" disabled={submitting}>
+              {submitting ? 'Accepting...' : 'Accept Ticket'}
+            </button>
+          </form>
+        ) : transfer.has_account ? (
+          <p className="transfer-message">
+            <Link to="/login">Log in</Link> as {transfer.to_email}, then open the link in your email again to accept this ticket.
+          </p>
+        ) : (
+          <form onSubmit={handleAccept} className="transfer-form">
+            <p className="transfer-message">Create your account to accept this ticket.</p>
+            <div className="form-group">
+              <label htmlFor="first_name">First Name</label>
+              <input type="text" id="first_name" name="first_name" value={formData.first_name} onChange={handleChange} required />
+            </div>
+            <div className="form-group">
+              <label htmlFor="last_name">Last Name</label>
+              <input type="text" id="last_name" name="last_name" value={formData.last_name} onChange={handleChange} required />
+            </div>
+            <div className="form-group">
+              <label htmlFor="password">Password</label>
+              <input type="password" id="password" name="password" value={formData.password} onChange={handleChange} minLength={6} required />
+            </div>
+            <button type="submit" className="action-button primary" disabled={submitting}>
+              {submitting ? 'Accepting...' : 'Create Account & Accept Ticket'}
+            </button>
+          </form>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default TransferAccept;
@@ -0,0 +1,68 @@
+import { useState, useEffect } from 'react';
+import { useSearchParams, useNavigate } from 'react-router-dom';
+import { useAuth } from '../AuthContext';
+import api from '../api';
+import './TransferAccept.css';
+
+// landing page for the link in a waitlist offer email
+function WaitlistClaim() {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const navigate = useNavigate();
+  const { isAuthenticated } = useAuth();
+
+  const [submitting, setSubmitting] = useState(false);
+  const [error, setError] = useState(token ? '' : 'This claim link is missing its token.');
+
+  useEffect(() => {
+    if (!isAuthenticated) {
+      navigate('/login');
+    }
+  }, [isAuthenticated, navigate]);
+
+  const handleClaim = async () => {
+    setSubmitting(true);
+    setError('');
+
+    try {
+      const response = await api.post('/waitlist/claim', { token });
+      // the claimed ticket is held until it's paid for
+      navigate(`/registrations/${response.data.registration.id}`);
+    } catch (err) {
+      console.error('Failed to claim ticket:', err);
+      setError(err.response?.data?.error || 'Failed to claim the ticket. Please try again later.');
+      setSubmitting(false);
+    }
+  };
+
+  return (
+    <div className="transfer-wrapper">
+      <div className="transfer-container">
+        <div className="transfer-header">
+          <h1 className="transfer-title">A ticket is available</h1>
+          <p className="transfer-subtitle">
+            A ticket you were waiting for has been kept for you. Claim it before the offer expires,
+            then complete your payment to confirm it.
+          </p>
+        </div>
+
+        {error && (
+          <div className="error-state">
+            <span className="error-icon">&#9888;&#65039;</span>
+            {error}
+          </div>
+        )}
+
+        {token && (
+          <div className="transfer-form">
+            <button onClick={handleClaim} className="action-button primary" disabled={submitting}>
+              {submitting ? 'Claiming...' : 'Claim Ticket'}
+            </button>
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default WaitlistClaim;